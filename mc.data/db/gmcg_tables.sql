@@ -1,8 +1,13 @@
 --CREATE DATABASE GoMonteCarloGo;
 --DROP TABLE simulation_run_history_component;
 --DROP TABLE simulation_run_history;
+--DROP TABLE view_set_view;
+--DROP TABLE view_set;
 --DROP TABLE scenario_configuration_component;
 --DROP TABLE scenario_configuration;
+--DROP TABLE assumption_set_correlation;
+--DROP TABLE assumption_set_asset;
+--DROP TABLE assumption_set;
 --DROP TABLE av_time_series_data;
 --DROP TABLE av_time_series_metadata;
 
@@ -51,16 +56,107 @@ CREATE TABLE IF NOT EXISTS av_time_series_data (
 
 CREATE INDEX IF NOT EXISTS idx_time_series_source_timestamp ON av_time_series_data(source_id, timestamp DESC);
 
+-- create table to store capital market assumption sets, a set is never updated, publishing under the same name adds the next version
+CREATE TABLE IF NOT EXISTS assumption_set (
+    id SERIAL PRIMARY KEY,
+    "name" VARCHAR(100) NOT NULL,
+    "version" INTEGER NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT uq_assumption_set_name_version UNIQUE ("name", "version")
+);
+
+-- create table to store the per asset assumptions of a set, null keeps the historical estimate
+CREATE TABLE IF NOT EXISTS assumption_set_asset (
+    id SERIAL PRIMARY KEY,
+    set_id INTEGER NOT NULL,
+    asset_id INTEGER NOT NULL,
+    expected_return NUMERIC(10, 6) DEFAULT NULL, -- annualized
+    volatility NUMERIC(10, 6) DEFAULT NULL, -- annualized
+
+    CONSTRAINT uq_assumption_set_asset UNIQUE (set_id, asset_id),
+
+    CONSTRAINT fk_assumption_set_asset_set FOREIGN KEY (set_id)
+        REFERENCES assumption_set(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_assumption_set_asset_metadata FOREIGN KEY (asset_id)
+        REFERENCES av_time_series_metadata(id)
+);
+
+-- create table to store the assumed correlations of a set, one row per pair with the lower asset id first
+CREATE TABLE IF NOT EXISTS assumption_set_correlation (
+    id SERIAL PRIMARY KEY,
+    set_id INTEGER NOT NULL,
+    asset_id_a INTEGER NOT NULL,
+    asset_id_b INTEGER NOT NULL,
+    correlation NUMERIC(8, 6) NOT NULL,
+
+    CONSTRAINT uq_assumption_set_correlation UNIQUE (set_id, asset_id_a, asset_id_b),
+    CONSTRAINT ck_assumption_set_correlation_pair CHECK (asset_id_a < asset_id_b),
+
+    CONSTRAINT fk_assumption_set_correlation_set FOREIGN KEY (set_id)
+        REFERENCES assumption_set(id)
+        ON DELETE CASCADE
+);
+
 -- create table to store scenario meta data
 CREATE TABLE IF NOT EXISTS scenario_configuration (
     id SERIAL PRIMARY KEY,
     "name" VARCHAR(100) NOT NULL,
     floated_weight BOOLEAN NOT NULL DEFAULT FALSE,
+    rebalance_policy VARCHAR(50) NOT NULL DEFAULT 'continuous', -- continuous, buyAndHold, calendar, threshold
+    rebalance_frequency INTEGER NOT NULL DEFAULT 0, -- periods between calendar rebalances
+    rebalance_threshold NUMERIC(8, 6) NOT NULL DEFAULT 0, -- absolute weight drift that triggers a threshold rebalance
+    assumption_set_id INTEGER DEFAULT NULL, -- capital market assumptions used by default when the scenario runs, null uses history alone
     created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
-    deleted_at TIMESTAMPTZ DEFAULT NULL
+    deleted_at TIMESTAMPTZ DEFAULT NULL,
+
+    CONSTRAINT fk_scenario_configuration_assumption_set FOREIGN KEY (assumption_set_id)
+        REFERENCES assumption_set(id)
+);
+
+-- create table to store black-litterman view sets, a set belongs to a scenario and is never updated, publishing under the same name adds the next version
+CREATE TABLE IF NOT EXISTS view_set (
+    id SERIAL PRIMARY KEY,
+    scenario_id INTEGER NOT NULL,
+    "name" VARCHAR(100) NOT NULL,
+    "version" INTEGER NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT uq_view_set_scenario_name_version UNIQUE (scenario_id, "name", "version"),
+
+    CONSTRAINT fk_view_set_scenario FOREIGN KEY (scenario_id)
+        REFERENCES scenario_configuration(id)
+        ON DELETE CASCADE
 );
 
+-- create table to store the views of a set, an absolute view when versus_asset_id is null, otherwise asset_id outperforms versus_asset_id
+CREATE TABLE IF NOT EXISTS view_set_view (
+    id SERIAL PRIMARY KEY,
+    set_id INTEGER NOT NULL,
+    asset_id INTEGER NOT NULL,
+    versus_asset_id INTEGER DEFAULT NULL,
+    expected_return NUMERIC(10, 6) NOT NULL, -- annualized, the outperformance for a relative view
+    confidence NUMERIC(8, 6) NOT NULL, -- 0 to 1, 1 is certain
+
+    CONSTRAINT fk_view_set_view_set FOREIGN KEY (set_id)
+        REFERENCES view_set(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_view_set_view_metadata FOREIGN KEY (asset_id)
+        REFERENCES av_time_series_metadata(id),
+
+    CONSTRAINT fk_view_set_view_versus_metadata FOREIGN KEY (versus_asset_id)
+        REFERENCES av_time_series_metadata(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_view_set_view_set_id
+    ON view_set_view(set_id);
+
 -- create table to store scenario components
 -- column name configuration_id matches models and queries (db tag and SQL)
 CREATE TABLE IF NOT EXISTS scenario_configuration_component (
@@ -88,13 +184,41 @@ CREATE TABLE IF NOT EXISTS simulation_run_history (
     scenario_id INTEGER NOT NULL, -- id that will match off on which scenario is being ran
     "name" VARCHAR(100) NOT NULL,
     floated_weight BOOLEAN NOT NULL,
+    rebalance_policy VARCHAR(50) NOT NULL DEFAULT 'continuous',
+    rebalance_frequency INTEGER NOT NULL DEFAULT 0,
+    rebalance_threshold NUMERIC(8, 6) NOT NULL DEFAULT 0,
     distribution_type VARCHAR(50) NOT NULL DEFAULT '',
     simulation_unit_of_time VARCHAR(50) NOT NULL DEFAULT '',
     simulation_duration INTEGER NOT NULL DEFAULT 0,
+    volatility_model VARCHAR(50) NOT NULL DEFAULT '',
     max_lookback DATE NOT NULL DEFAULT '1970-01-01', -- cutoff date for time series query (reference_time - lookback duration), computed on insert
     iterations INTEGER NOT NULL DEFAULT 0,
     seed BIGINT NOT NULL DEFAULT 0,
     degrees_of_freedom INTEGER NOT NULL DEFAULT 0,
+    copula_degrees_of_freedom INTEGER NOT NULL DEFAULT 0, -- 0 when the t copula uses degrees_of_freedom
+    block_length INTEGER NOT NULL DEFAULT 0, -- mean block length for historical bootstrap runs
+    number_of_regimes INTEGER NOT NULL DEFAULT 1, -- 1 when regime switching is not used
+    jumps BOOLEAN NOT NULL DEFAULT FALSE, -- true when a jump diffusion was layered on the returns
+    shocks INTEGER NOT NULL DEFAULT 0, -- number of deterministic shocks overlaid on every path
+    initial_portfolio_value NUMERIC(20, 2) NOT NULL DEFAULT 100, -- starting capital, 100 for index level runs
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    antithetic BOOLEAN NOT NULL DEFAULT FALSE,
+    control_variate BOOLEAN NOT NULL DEFAULT FALSE,
+    random_source VARCHAR(50) NOT NULL DEFAULT 'pseudoRandom',
+    covariance_estimator VARCHAR(50) NOT NULL DEFAULT 'sample',
+    mean_estimator VARCHAR(50) NOT NULL DEFAULT 'sample',
+    half_life INTEGER NOT NULL DEFAULT 0, -- periods, 0 unless an ewma estimator was used
+    assumption_set_id INTEGER NOT NULL DEFAULT 0, -- 0 when no stored set was used (inline assumptions or history alone)
+    assumption_weight NUMERIC(8, 6) NOT NULL DEFAULT 0, -- blend weight on the assumptions, 0 when history alone was used
+    view_set_id INTEGER NOT NULL DEFAULT 0, -- 0 when no stored view set was used (inline views or no black-litterman)
+    views INTEGER NOT NULL DEFAULT 0, -- number of black-litterman views
+    tau NUMERIC(8, 6) NOT NULL DEFAULT 0, -- black-litterman uncertainty of the equilibrium returns, 0 when black-litterman was not used
+    benchmark_asset_id INTEGER NOT NULL DEFAULT 0, -- 0 unless the run was against a single asset benchmark
+    benchmark_scenario_id INTEGER NOT NULL DEFAULT 0, -- 0 unless the run was against another scenario
+    risk_free_rate NUMERIC(8, 6) NOT NULL DEFAULT 0, -- annualized, for the sharpe, sortino and omega ratios
+    target_var95_standard_error NUMERIC(12, 8) NOT NULL DEFAULT 0, -- 0 when the run did not converge adaptively
+    max_iterations INTEGER NOT NULL DEFAULT 0,
+    paths_run INTEGER NOT NULL DEFAULT 0, -- set when the run succeeds, more than iterations when converging adaptively
     error_message TEXT DEFAULT NULL,
     start_time_utc TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
     end_time_utc TIMESTAMPTZ DEFAULT NULL
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// AssumptionSet is a versioned set of capital market assumptions, the per asset assumptions and the assumed correlations
+type AssumptionSet struct {
+	AssumptionSetConfiguration
+	Assets       []AssumptionSetAsset
+	Correlations []AssumptionSetCorrelation
+}
+
+// AssumptionSetConfiguration is one published version of a named set, sets are never updated so runs can point at them
+type AssumptionSetConfiguration struct {
+	Id          int32     `db:"id"`
+	Name        string    `db:"name"`
+	Version     int       `db:"version"` // starts at 1, every set published under the same name gets the next version
+	Description string    `db:"description"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// AssumptionSetAsset is the forward looking expected return and volatility of an asset, nil keeps the historical estimate
+type AssumptionSetAsset struct {
+	SetId          int32    `db:"set_id"`
+	AssetId        int32    `db:"asset_id"`
+	ExpectedReturn *float64 `db:"expected_return"` // annualized
+	Volatility     *float64 `db:"volatility"`      // annualized
+}
+
+// AssumptionSetCorrelation is the assumed correlation of a pair of assets, the lower asset id is always a
+type AssumptionSetCorrelation struct {
+	SetId       int32   `db:"set_id"`
+	AssetIdA    int32   `db:"asset_id_a"`
+	AssetIdB    int32   `db:"asset_id_b"`
+	Correlation float64 `db:"correlation"`
+}
@@ -13,11 +13,15 @@ type Scenario struct {
 // ScenarioConfiguration is the configuration for a scenario.
 // Will keep configuation data for a scenario, probably need to add more here down the road
 type ScenarioConfiguration struct {
-	Id            int32     `db:"id"`
-	Name          string    `db:"name"`
-	FloatedWeight bool      `db:"floated_weight"`
-	CreatedAt     time.Time `db:"created_at"`
-	UpdatedAt     time.Time `db:"updated_at"`
+	Id                 int32     `db:"id"`
+	Name               string    `db:"name"`
+	FloatedWeight      bool      `db:"floated_weight"`
+	RebalancePolicy    string    `db:"rebalance_policy"`    // continuous, buy and hold, calendar, threshold
+	RebalanceFrequency int       `db:"rebalance_frequency"` // periods between calendar rebalances
+	RebalanceThreshold float64   `db:"rebalance_threshold"` // absolute weight drift that triggers a threshold rebalance
+	AssumptionSetId    *int32    `db:"assumption_set_id"`   // capital market assumptions used by default, nil uses history alone
+	CreatedAt          time.Time `db:"created_at"`
+	UpdatedAt          time.Time `db:"updated_at"`
 }
 
 // ScenarioConfigurationComponent is a component of a scenario configuration, like ticker and associated weight
@@ -36,20 +40,48 @@ type SimulationRun struct {
 // SimulationRunHistory is the history of a simulation run (when a scenario is executed), will keep the run id, scenario id, error message, start time, and end time
 // If I ever get to a point where I expand to users, will track user ids here as well, or any other relevant info.
 type SimulationRunHistory struct {
-	Id                   int32     `db:"id" json:"id"`
-	ScenarioId           int32     `db:"scenario_id"` // foreign key to scenario configuration
-	Name                 string    `db:"name" json:"name"`
-	FloatedWeight        bool      `db:"floated_weight" json:"floatedWeight"`
-	DistributionType     string    `db:"distribution_type" json:"distributionType"`
-	SimulationUnitOfTime string    `db:"simulation_unit_of_time" json:"simulationUnitOfTime"`
-	SimulationDuration   int       `db:"simulation_duration" json:"simulationDuration"` // will be in units of simulation_unit_of_time
-	MaxLookback          time.Time `db:"max_lookback" json:"maxLookback"`               // cutoff date for time series query (reference_time - lookback duration), computed on insert
-	Iterations           int       `db:"iterations" json:"iterations"`
-	Seed                 int64     `db:"seed" json:"seed"`
-	DegreesOfFreedom     int       `db:"degrees_of_freedom" json:"degreesOfFreedom"`
-	ErrorMessage         string    `db:"error_message" json:"errorMessage"`
-	StartTimeUtc         time.Time `db:"start_time_utc" json:"startTimeUtc"`
-	EndTimeUtc           time.Time `db:"end_time_utc" json:"endTimeUtc"`
+	Id                       int32     `db:"id" json:"id"`
+	ScenarioId               int32     `db:"scenario_id"` // foreign key to scenario configuration
+	Name                     string    `db:"name" json:"name"`
+	FloatedWeight            bool      `db:"floated_weight" json:"floatedWeight"`
+	RebalancePolicy          string    `db:"rebalance_policy" json:"rebalancePolicy"`       // copied from the scenario at time of run
+	RebalanceFrequency       int       `db:"rebalance_frequency" json:"rebalanceFrequency"` // copied from the scenario at time of run
+	RebalanceThreshold       float64   `db:"rebalance_threshold" json:"rebalanceThreshold"` // copied from the scenario at time of run
+	DistributionType         string    `db:"distribution_type" json:"distributionType"`
+	SimulationUnitOfTime     string    `db:"simulation_unit_of_time" json:"simulationUnitOfTime"`
+	SimulationDuration       int       `db:"simulation_duration" json:"simulationDuration"` // will be in units of simulation_unit_of_time
+	VolatilityModel          string    `db:"volatility_model" json:"volatilityModel"`
+	MaxLookback              time.Time `db:"max_lookback" json:"maxLookback"` // cutoff date for time series query (reference_time - lookback duration), computed on insert
+	Iterations               int       `db:"iterations" json:"iterations"`
+	Seed                     int64     `db:"seed" json:"seed"`
+	DegreesOfFreedom         int       `db:"degrees_of_freedom" json:"degreesOfFreedom"`
+	CopulaDegreesOfFreedom   int       `db:"copula_degrees_of_freedom" json:"copulaDegreesOfFreedom"` // 0 when the copula uses degrees of freedom
+	BlockLength              int       `db:"block_length" json:"blockLength"`                         // mean block length for historical bootstrap
+	NumberOfRegimes          int       `db:"number_of_regimes" json:"numberOfRegimes"`                // 1 when regime switching is not used
+	Jumps                    bool      `db:"jumps" json:"jumps"`                                      // true when a jump diffusion was layered on the returns
+	Shocks                   int       `db:"shocks" json:"shocks"`                                    // number of deterministic shocks overlaid on every path
+	InitialPortfolioValue    float64   `db:"initial_portfolio_value" json:"initialPortfolioValue"`
+	Currency                 string    `db:"currency" json:"currency"`
+	Antithetic               bool      `db:"antithetic" json:"antithetic"`
+	ControlVariate           bool      `db:"control_variate" json:"controlVariate"`
+	RandomSource             string    `db:"random_source" json:"randomSource"`
+	CovarianceEstimator      string    `db:"covariance_estimator" json:"covarianceEstimator"`
+	MeanEstimator            string    `db:"mean_estimator" json:"meanEstimator"`
+	HalfLife                 int       `db:"half_life" json:"halfLife"`                                   // 0 unless an ewma estimator was used
+	AssumptionSetId          int32     `db:"assumption_set_id" json:"assumptionSetId"`                    // 0 when no stored set was used
+	AssumptionWeight         float64   `db:"assumption_weight" json:"assumptionWeight"`                   // 0 when history alone was used
+	ViewSetId                int32     `db:"view_set_id" json:"viewSetId"`                                // 0 when no stored view set was used
+	Views                    int       `db:"views" json:"views"`                                          // number of black-litterman views
+	Tau                      float64   `db:"tau" json:"tau"`                                              // 0 when black-litterman was not used
+	BenchmarkAssetId         int32     `db:"benchmark_asset_id" json:"benchmarkAssetId"`                  // 0 unless the benchmark was a single asset
+	BenchmarkScenarioId      int32     `db:"benchmark_scenario_id" json:"benchmarkScenarioId"`            // 0 unless the benchmark was another scenario
+	RiskFreeRate             float64   `db:"risk_free_rate" json:"riskFreeRate"`                          // annualized
+	TargetVaR95StandardError float64   `db:"target_var95_standard_error" json:"targetVaR95StandardError"` // 0 when the run did not converge adaptively
+	MaxIterations            int       `db:"max_iterations" json:"maxIterations"`
+	PathsRun                 int       `db:"paths_run" json:"pathsRun"` // set when the run succeeds
+	ErrorMessage             string    `db:"error_message" json:"errorMessage"`
+	StartTimeUtc             time.Time `db:"start_time_utc" json:"startTimeUtc"`
+	EndTimeUtc               time.Time `db:"end_time_utc" json:"endTimeUtc"`
 }
 
 // TODO: need to add asset details here, like symbol, name, etc.
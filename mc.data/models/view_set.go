@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// ViewSet is a versioned set of black-litterman views on the assets of a scenario
+type ViewSet struct {
+	ViewSetConfiguration
+	Views []ViewSetView
+}
+
+// ViewSetConfiguration is one published version of a named set, sets are never updated so runs can point at them
+type ViewSetConfiguration struct {
+	Id          int32     `db:"id"`
+	ScenarioId  int32     `db:"scenario_id"`
+	Name        string    `db:"name"`
+	Version     int       `db:"version"` // starts at 1, every set published under the same name for the scenario gets the next version
+	Description string    `db:"description"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// ViewSetView is an absolute view on an asset's return, or a relative one on how much it beats the versus asset
+type ViewSetView struct {
+	SetId          int32   `db:"set_id"`
+	AssetId        int32   `db:"asset_id"`
+	VersusAssetId  *int32  `db:"versus_asset_id"` // nil for an absolute view
+	ExpectedReturn float64 `db:"expected_return"` // annualized, the outperformance for a relative view
+	Confidence     float64 `db:"confidence"`      // 0 to 1, 1 is certain
+}
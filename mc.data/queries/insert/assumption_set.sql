@@ -0,0 +1,7 @@
+INSERT INTO assumption_set
+    ("name", "version", description)
+SELECT
+    @name, COALESCE(MAX("version"), 0) + 1, @description
+FROM assumption_set
+WHERE "name" = @name
+RETURNING id, "version", created_at
\ No newline at end of file
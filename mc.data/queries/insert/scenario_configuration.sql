@@ -1,5 +1,5 @@
 INSERT INTO scenario_configuration
-    ("name", floated_weight)
+    ("name", floated_weight, rebalance_policy, rebalance_frequency, rebalance_threshold, assumption_set_id)
 VALUES
-    (@name, @floated_weight)
+    (@name, @floated_weight, @rebalance_policy, @rebalance_frequency, @rebalance_threshold, @assumption_set_id)
 RETURNING id, created_at, updated_at
\ No newline at end of file
@@ -3,25 +3,79 @@ WITH inserted_run AS (
         (scenario_id, 
         "name", 
         floated_weight, 
+        rebalance_policy, 
+        rebalance_frequency, 
+        rebalance_threshold, 
         distribution_type, 
         simulation_unit_of_time,
         simulation_duration, 
+        volatility_model, 
         max_lookback, 
         iterations, 
         seed, 
         degrees_of_freedom, 
+        copula_degrees_of_freedom, 
+        block_length, 
+        number_of_regimes, 
+        jumps, 
+        shocks, 
+        initial_portfolio_value, 
+        currency, 
+        antithetic, 
+        control_variate, 
+        random_source, 
+        covariance_estimator, 
+        mean_estimator, 
+        half_life, 
+        assumption_set_id, 
+        assumption_weight, 
+        view_set_id, 
+        views, 
+        tau, 
+        benchmark_asset_id, 
+        benchmark_scenario_id, 
+        risk_free_rate, 
+        target_var95_standard_error, 
+        max_iterations, 
         start_time_utc)
     SELECT 
         sc.id, 
         sc."name", 
         sc.floated_weight,
+        sc.rebalance_policy,
+        sc.rebalance_frequency,
+        sc.rebalance_threshold,
         @distribution_type, 
         @simulation_unit_of_time, 
         @simulation_duration,
+        @volatility_model,
         @max_lookback::date, 
         @iterations, 
         @seed, 
         @degrees_of_freedom, 
+        @copula_degrees_of_freedom, 
+        @block_length, 
+        @number_of_regimes, 
+        @jumps, 
+        @shocks, 
+        @initial_portfolio_value, 
+        @currency, 
+        @antithetic, 
+        @control_variate, 
+        @random_source, 
+        @covariance_estimator, 
+        @mean_estimator, 
+        @half_life, 
+        @assumption_set_id, 
+        @assumption_weight, 
+        @view_set_id, 
+        @views, 
+        @tau, 
+        @benchmark_asset_id, 
+        @benchmark_scenario_id, 
+        @risk_free_rate, 
+        @target_var95_standard_error, 
+        @max_iterations, 
         CURRENT_TIMESTAMP
     FROM scenario_configuration sc
     WHERE sc.id = @scenario_id
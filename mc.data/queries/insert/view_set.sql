@@ -0,0 +1,7 @@
+INSERT INTO view_set
+    (scenario_id, "name", "version", description)
+SELECT
+    @scenario_id, @name, COALESCE(MAX("version"), 0) + 1, @description
+FROM view_set
+WHERE scenario_id = @scenario_id AND "name" = @name
+RETURNING id, "version", created_at
\ No newline at end of file
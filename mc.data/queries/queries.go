@@ -17,13 +17,19 @@ type DeleteQueries struct {
 }
 
 type InsertQueries struct {
+	AssumptionSet         string
 	Metadata              string
 	ScenarioConfiguration string
 	SimulationRunHistory  string
+	ViewSet               string
 }
 
 type SelectQueries struct {
+	AllAssumptionSets                      string
 	AllMetaData                            string
+	AssumptionSetAssetsBySetId             string
+	AssumptionSetById                      string
+	AssumptionSetCorrelationsBySetId       string
 	AllScenarioConfigurationComponents     string
 	AllScenarioConfigurations              string
 	MetaDataBySymbol                       string
@@ -34,6 +40,9 @@ type SelectQueries struct {
 	SimulationRunHistoryComponentsByRunIds string
 	TimeSeriesData                         string
 	TimeSeriesReturns                      string
+	ViewSetById                            string
+	ViewSetViewsBySetId                    string
+	ViewSetsByScenarioId                   string
 }
 
 type UpdateQueries struct {
@@ -55,12 +64,18 @@ var QueryHelper = QueryHelperStruct{
 		ScenarioConfigurationComponentByConfigurationId: "delete/scenario_configuration_component_by_configuration_id.sql",
 	},
 	Insert: InsertQueries{
+		AssumptionSet:         "insert/assumption_set.sql",
 		Metadata:              "insert/metadata.sql",
 		ScenarioConfiguration: "insert/scenario_configuration.sql",
 		SimulationRunHistory:  "insert/simulation_run_history.sql",
+		ViewSet:               "insert/view_set.sql",
 	},
 	Select: SelectQueries{
+		AllAssumptionSets:                      "select/all_assumption_sets.sql",
 		AllMetaData:                            "select/all_meta_data.sql",
+		AssumptionSetAssetsBySetId:             "select/assumption_set_assets_by_set_id.sql",
+		AssumptionSetById:                      "select/assumption_set_by_id.sql",
+		AssumptionSetCorrelationsBySetId:       "select/assumption_set_correlations_by_set_id.sql",
 		AllScenarioConfigurationComponents:     "select/all_scenario_configuration_components.sql",
 		AllScenarioConfigurations:              "select/all_scenario_configurations.sql",
 		MetaDataBySymbol:                       "select/meta_data_by_symbol.sql",
@@ -71,6 +86,9 @@ var QueryHelper = QueryHelperStruct{
 		SimulationRunHistoryComponentsByRunIds: "select/simulation_run_history_components_by_run_ids.sql",
 		TimeSeriesData:                         "select/time_series_data.sql",
 		TimeSeriesReturns:                      "select/time_series_returns.sql",
+		ViewSetById:                            "select/view_set_by_id.sql",
+		ViewSetViewsBySetId:                    "select/view_set_views_by_set_id.sql",
+		ViewSetsByScenarioId:                   "select/view_sets_by_scenario_id.sql",
 	},
 	Update: UpdateQueries{
 		LastRefreshedDate:     "update/last_refreshed_date.sql",
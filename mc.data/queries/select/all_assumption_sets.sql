@@ -0,0 +1,8 @@
+SELECT
+    id,
+    "name",
+    "version",
+    description,
+    created_at
+FROM assumption_set
+ORDER BY "name", "version" DESC
\ No newline at end of file
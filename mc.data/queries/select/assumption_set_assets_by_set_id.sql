@@ -0,0 +1,7 @@
+SELECT
+    set_id,
+    asset_id,
+    expected_return,
+    volatility
+FROM assumption_set_asset
+WHERE set_id = @id
\ No newline at end of file
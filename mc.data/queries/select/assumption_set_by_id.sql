@@ -0,0 +1,8 @@
+SELECT
+    id,
+    "name",
+    "version",
+    description,
+    created_at
+FROM assumption_set
+WHERE id = @id
\ No newline at end of file
@@ -0,0 +1,7 @@
+SELECT
+    set_id,
+    asset_id_a,
+    asset_id_b,
+    correlation
+FROM assumption_set_correlation
+WHERE set_id = @id
\ No newline at end of file
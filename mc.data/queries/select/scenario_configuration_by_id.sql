@@ -2,6 +2,10 @@ SELECT
     id,
     "name",
     floated_weight,
+    rebalance_policy,
+    rebalance_frequency,
+    rebalance_threshold,
+    assumption_set_id,
     created_at,
     updated_at
 FROM scenario_configuration
@@ -15,4 +15,4 @@ SELECT
     LN(adjusted_close / prev_close) AS log_return
 FROM price_data
 WHERE prev_close IS NOT NULL
-ORDER BY source_id, timestamp DESC
\ No newline at end of file
+ORDER BY source_id, timestamp
\ No newline at end of file
@@ -0,0 +1,9 @@
+SELECT
+    id,
+    scenario_id,
+    "name",
+    "version",
+    description,
+    created_at
+FROM view_set
+WHERE id = @id
\ No newline at end of file
@@ -0,0 +1,9 @@
+SELECT
+    set_id,
+    asset_id,
+    versus_asset_id,
+    expected_return,
+    confidence
+FROM view_set_view
+WHERE set_id = @id
+ORDER BY id
\ No newline at end of file
@@ -0,0 +1,10 @@
+SELECT
+    id,
+    scenario_id,
+    "name",
+    "version",
+    description,
+    created_at
+FROM view_set
+WHERE scenario_id = @scenario_id
+ORDER BY "name", "version" DESC
\ No newline at end of file
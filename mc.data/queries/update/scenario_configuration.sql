@@ -3,6 +3,10 @@ UPDATE
 SET 
     "name" = @name,
     floated_weight = @floated_weight,
+    rebalance_policy = @rebalance_policy,
+    rebalance_frequency = @rebalance_frequency,
+    rebalance_threshold = @rebalance_threshold,
+    assumption_set_id = @assumption_set_id,
     updated_at = CURRENT_TIMESTAMP
 WHERE 
     id = @id
@@ -11,5 +15,9 @@ RETURNING
     id,
     "name",
     floated_weight,
+    rebalance_policy,
+    rebalance_frequency,
+    rebalance_threshold,
+    assumption_set_id,
     created_at,
     updated_at
\ No newline at end of file
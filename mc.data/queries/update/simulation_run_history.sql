@@ -2,6 +2,7 @@ UPDATE
     simulation_run_history
 SET 
     error_message = @error_message,
+    paths_run = @paths_run,
     end_time_utc = CURRENT_TIMESTAMP
 WHERE 
     id = @id
\ No newline at end of file
@@ -0,0 +1,142 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	m "mc.data/models"
+	q "mc.data/queries"
+)
+
+// GetAssumptionSets returns every version of every set without the assumptions themselves, newest version first
+func (pg *Postgres) GetAssumptionSets(ctx context.Context) ([]*m.AssumptionSetConfiguration, error) {
+	sql := q.Get(q.QueryHelper.Select.AllAssumptionSets)
+	args := pgx.NamedArgs{}
+	sets, err := Query[m.AssumptionSetConfiguration](ctx, pg, sql, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get assumption sets: %w", err)
+	}
+
+	return sets, nil
+}
+
+func (pg *Postgres) GetAssumptionSetByID(ctx context.Context, id int32) (*m.AssumptionSet, error) {
+	sql := q.Get(q.QueryHelper.Select.AssumptionSetById)
+	args := pgx.NamedArgs{"id": id}
+	sets, err := Query[m.AssumptionSetConfiguration](ctx, pg, sql, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get assumption set by id (%d): %w", id, err)
+	}
+
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("assumption set id not found (%d)", id)
+	}
+
+	sql = q.Get(q.QueryHelper.Select.AssumptionSetAssetsBySetId)
+	assets, err := Query[m.AssumptionSetAsset](ctx, pg, sql, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get assumption set assets by id (%d): %w", id, err)
+	}
+
+	sql = q.Get(q.QueryHelper.Select.AssumptionSetCorrelationsBySetId)
+	correlations, err := Query[m.AssumptionSetCorrelation](ctx, pg, sql, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get assumption set correlations by id (%d): %w", id, err)
+	}
+
+	set := &m.AssumptionSet{
+		AssumptionSetConfiguration: *sets[0],
+		Assets:                     make([]m.AssumptionSetAsset, 0, len(assets)),
+		Correlations:               make([]m.AssumptionSetCorrelation, 0, len(correlations)),
+	}
+
+	for _, v := range assets {
+		set.Assets = append(set.Assets, *v)
+	}
+	for _, v := range correlations {
+		set.Correlations = append(set.Correlations, *v)
+	}
+
+	return set, nil
+}
+
+// InsertNewAssumptionSetTx publishes the set as the next version of its name
+func (pg *Postgres) InsertNewAssumptionSetTx(ctx context.Context, set m.AssumptionSet, tx pgx.Tx) (*m.AssumptionSet, error) {
+	if set.Name == "" {
+		return nil, fmt.Errorf("assumption set name is required")
+	}
+	if len(set.Assets) == 0 && len(set.Correlations) == 0 {
+		return nil, fmt.Errorf("assumption set must include at least one asset or correlation")
+	}
+
+	config := m.AssumptionSetConfiguration{
+		Name:        set.Name,
+		Description: set.Description,
+	}
+
+	sql := q.Get(q.QueryHelper.Insert.AssumptionSet)
+	args := pgx.NamedArgs{
+		"name":        set.Name,
+		"description": set.Description,
+	}
+	if err := tx.QueryRow(ctx, sql, args).Scan(
+		&config.Id,
+		&config.Version,
+		&config.CreatedAt); err != nil {
+		return nil, fmt.Errorf("error inserting assumption set: %w", err)
+	}
+
+	assetRows := make([][]any, len(set.Assets))
+	assets := make([]m.AssumptionSetAsset, len(set.Assets))
+	for i, a := range set.Assets {
+		assetRows[i] = []any{config.Id, a.AssetId, a.ExpectedReturn, a.Volatility}
+		assets[i] = a
+		assets[i].SetId = config.Id
+	}
+
+	table_name := pgx.Identifier{"assumption_set_asset"}
+	columns := []string{"set_id", "asset_id", "expected_return", "volatility"}
+	if _, err := tx.CopyFrom(ctx, table_name, columns, pgx.CopyFromRows(assetRows)); err != nil {
+		return nil, fmt.Errorf("error inserting assumption set assets (%d): %w", config.Id, err)
+	}
+
+	correlationRows := make([][]any, len(set.Correlations))
+	correlations := make([]m.AssumptionSetCorrelation, len(set.Correlations))
+	for i, c := range set.Correlations {
+		correlationRows[i] = []any{config.Id, c.AssetIdA, c.AssetIdB, c.Correlation}
+		correlations[i] = c
+		correlations[i].SetId = config.Id
+	}
+
+	table_name = pgx.Identifier{"assumption_set_correlation"}
+	columns = []string{"set_id", "asset_id_a", "asset_id_b", "correlation"}
+	if _, err := tx.CopyFrom(ctx, table_name, columns, pgx.CopyFromRows(correlationRows)); err != nil {
+		return nil, fmt.Errorf("error inserting assumption set correlations (%d): %w", config.Id, err)
+	}
+
+	return &m.AssumptionSet{
+		AssumptionSetConfiguration: config,
+		Assets:                     assets,
+		Correlations:               correlations,
+	}, nil
+}
+
+func (pg *Postgres) InsertNewAssumptionSet(ctx context.Context, set m.AssumptionSet) (*m.AssumptionSet, error) {
+	tx, err := pg.GetTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	inserted, err := pg.InsertNewAssumptionSetTx(ctx, set, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing assumption set insert: %w", err)
+	}
+
+	return inserted, nil
+}
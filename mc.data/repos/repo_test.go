@@ -169,8 +169,10 @@ func Test_ScenarioRepo_CanCRUD(t *testing.T) {
 	scenarioName := fmt.Sprintf("Test Scenario %d", suffix)
 	newScenario := m.Scenario{
 		ScenarioConfiguration: m.ScenarioConfiguration{
-			Name:          scenarioName,
-			FloatedWeight: false,
+			Name:               scenarioName,
+			FloatedWeight:      false,
+			RebalancePolicy:    "calendar",
+			RebalanceFrequency: 13,
 		},
 		Components: []m.ScenarioConfigurationComponent{
 			{ConfigurationId: 0, AssetId: assetA.Id, Weight: 0.6},
@@ -198,6 +200,9 @@ func Test_ScenarioRepo_CanCRUD(t *testing.T) {
 	if fetched.Name != scenarioName {
 		t.Fatalf("scenario name mismatch, expected %s, got %s", scenarioName, fetched.Name)
 	}
+	if fetched.RebalancePolicy != "calendar" || fetched.RebalanceFrequency != 13 {
+		t.Fatalf("rebalance policy mismatch, expected calendar every 13, got %s every %d", fetched.RebalancePolicy, fetched.RebalanceFrequency)
+	}
 	if len(fetched.Components) != len(newScenario.Components) {
 		t.Fatalf("expected %d components, got %d", len(newScenario.Components), len(fetched.Components))
 	}
@@ -248,6 +253,160 @@ func Test_ScenarioRepo_CanCRUD(t *testing.T) {
 	}
 }
 
+func Test_AssumptionSetRepo_CanInsertAndGet(t *testing.T) {
+	ctx := context.Background()
+	pg := getConnection(t, ctx)
+
+	suffix := time.Now().UnixNano()
+	assetA := m.TimeSeriesMetadata{
+		Symbol:        fmt.Sprintf("_TEST_CMA_A_%d", suffix),
+		LastRefreshed: time.Date(2025, time.October, 31, 0, 0, 0, 0, time.UTC),
+	}
+	assetB := m.TimeSeriesMetadata{
+		Symbol:        fmt.Sprintf("_TEST_CMA_B_%d", suffix),
+		LastRefreshed: time.Date(2025, time.October, 31, 0, 0, 0, 0, time.UTC),
+	}
+
+	if err := pg.InsertNewMetaData(ctx, &assetA, nil); err != nil {
+		t.Fatalf("error inserting metadata A: %s", err)
+	}
+	if err := pg.InsertNewMetaData(ctx, &assetB, nil); err != nil {
+		t.Fatalf("error inserting metadata B: %s", err)
+	}
+
+	setName := fmt.Sprintf("Test Assumptions %d", suffix)
+	defer pg.deleteTestTimeSeriesData(t, ctx, assetA.Id)
+	defer pg.deleteTestTimeSeriesData(t, ctx, assetB.Id)
+	defer pg.deleteTestAssumptionSetData(t, ctx, setName)
+
+	expectedReturn, volatility := 0.065, 0.16
+	newSet := m.AssumptionSet{
+		AssumptionSetConfiguration: m.AssumptionSetConfiguration{Name: setName, Description: "annual cma"},
+		Assets: []m.AssumptionSetAsset{
+			{AssetId: assetA.Id, ExpectedReturn: &expectedReturn, Volatility: &volatility},
+			{AssetId: assetB.Id, ExpectedReturn: &expectedReturn},
+		},
+		Correlations: []m.AssumptionSetCorrelation{
+			{AssetIdA: min(assetA.Id, assetB.Id), AssetIdB: max(assetA.Id, assetB.Id), Correlation: 0.3},
+		},
+	}
+
+	first, err := pg.InsertNewAssumptionSet(ctx, newSet)
+	if err != nil {
+		t.Fatalf("error inserting assumption set: %s", err)
+	}
+	second, err := pg.InsertNewAssumptionSet(ctx, newSet)
+	if err != nil {
+		t.Fatalf("error inserting second version of assumption set: %s", err)
+	}
+	if first.Version != 1 || second.Version != 2 {
+		t.Fatalf("expected versions 1 and 2, got %d and %d", first.Version, second.Version)
+	}
+
+	fetched, err := pg.GetAssumptionSetByID(ctx, second.Id)
+	if err != nil {
+		t.Fatalf("error fetching assumption set: %s", err)
+	}
+	if fetched.Name != setName || len(fetched.Assets) != 2 || len(fetched.Correlations) != 1 {
+		t.Fatalf("assumption set mismatch, got %+v", fetched)
+	}
+
+	assetLookup := make(map[int32]m.AssumptionSetAsset)
+	for _, a := range fetched.Assets {
+		assetLookup[a.AssetId] = a
+	}
+	if v := assetLookup[assetA.Id].Volatility; v == nil || *v != volatility {
+		t.Fatalf("volatility mismatch for asset A, expected %.2f, got %v", volatility, v)
+	}
+	if v := assetLookup[assetB.Id].Volatility; v != nil {
+		t.Fatalf("expected no volatility for asset B, got %.2f", *v)
+	}
+	if fetched.Correlations[0].Correlation != 0.3 {
+		t.Fatalf("correlation mismatch, expected 0.3, got %.2f", fetched.Correlations[0].Correlation)
+	}
+}
+
+func Test_ViewSetRepo_CanInsertAndGet(t *testing.T) {
+	ctx := context.Background()
+	pg := getConnection(t, ctx)
+
+	suffix := time.Now().UnixNano()
+	assetA := m.TimeSeriesMetadata{
+		Symbol:        fmt.Sprintf("_TEST_BL_A_%d", suffix),
+		LastRefreshed: time.Date(2025, time.October, 31, 0, 0, 0, 0, time.UTC),
+	}
+	assetB := m.TimeSeriesMetadata{
+		Symbol:        fmt.Sprintf("_TEST_BL_B_%d", suffix),
+		LastRefreshed: time.Date(2025, time.October, 31, 0, 0, 0, 0, time.UTC),
+	}
+
+	if err := pg.InsertNewMetaData(ctx, &assetA, nil); err != nil {
+		t.Fatalf("error inserting metadata A: %s", err)
+	}
+	if err := pg.InsertNewMetaData(ctx, &assetB, nil); err != nil {
+		t.Fatalf("error inserting metadata B: %s", err)
+	}
+
+	defer pg.deleteTestTimeSeriesData(t, ctx, assetA.Id)
+	defer pg.deleteTestTimeSeriesData(t, ctx, assetB.Id)
+
+	scenario, err := pg.InsertNewScenario(ctx, m.Scenario{
+		ScenarioConfiguration: m.ScenarioConfiguration{Name: fmt.Sprintf("Test Views Scenario %d", suffix)},
+		Components: []m.ScenarioConfigurationComponent{
+			{AssetId: assetA.Id, Weight: 0.6},
+			{AssetId: assetB.Id, Weight: 0.4},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error inserting scenario: %s", err)
+	}
+
+	// postgres cascade will delete every version of the view set with the scenario
+	defer pg.deleteTestScenarioData(t, ctx, scenario.Id)
+
+	newSet := m.ViewSet{
+		ViewSetConfiguration: m.ViewSetConfiguration{ScenarioId: scenario.Id, Name: "house views", Description: "q1"},
+		Views: []m.ViewSetView{
+			{AssetId: assetA.Id, ExpectedReturn: 0.07, Confidence: 0.5},
+			{AssetId: assetA.Id, VersusAssetId: &assetB.Id, ExpectedReturn: 0.02, Confidence: 0.25},
+		},
+	}
+
+	first, err := pg.InsertNewViewSet(ctx, newSet)
+	if err != nil {
+		t.Fatalf("error inserting view set: %s", err)
+	}
+	second, err := pg.InsertNewViewSet(ctx, newSet)
+	if err != nil {
+		t.Fatalf("error inserting second version of view set: %s", err)
+	}
+	if first.Version != 1 || second.Version != 2 {
+		t.Fatalf("expected versions 1 and 2, got %d and %d", first.Version, second.Version)
+	}
+
+	sets, err := pg.GetViewSetsByScenarioID(ctx, scenario.Id)
+	if err != nil {
+		t.Fatalf("error fetching view sets: %s", err)
+	}
+	if len(sets) != 2 || sets[0].Id != second.Id {
+		t.Fatalf("expected both versions newest first, got %+v", sets)
+	}
+
+	fetched, err := pg.GetViewSetByID(ctx, second.Id)
+	if err != nil {
+		t.Fatalf("error fetching view set: %s", err)
+	}
+	if fetched.ScenarioId != scenario.Id || len(fetched.Views) != 2 {
+		t.Fatalf("view set mismatch, got %+v", fetched)
+	}
+	if fetched.Views[0].VersusAssetId != nil || fetched.Views[0].ExpectedReturn != 0.07 {
+		t.Fatalf("expected an absolute view of 0.07 first, got %+v", fetched.Views[0])
+	}
+	if v := fetched.Views[1].VersusAssetId; v == nil || *v != assetB.Id || fetched.Views[1].Confidence != 0.25 {
+		t.Fatalf("relative view mismatch, got %+v", fetched.Views[1])
+	}
+}
+
 func compareTimeSeriesData(t *testing.T, expected, actual *m.TimeSeriesData) {
 	t.Helper()
 	if expected.Timestamp.Before(actual.Timestamp) {
@@ -300,3 +459,12 @@ func (pg *Postgres) deleteTestScenarioData(t *testing.T, ctx context.Context, id
 		t.Errorf("cleanup scenario_configuration failed: %s", err)
 	}
 }
+
+func (pg *Postgres) deleteTestAssumptionSetData(t *testing.T, ctx context.Context, name string) {
+	t.Helper()
+	// postgres cascade will delete the assets and correlations of every version of the set
+	_, err := pg.db.Exec(ctx, "DELETE FROM assumption_set WHERE name = @name", pgx.NamedArgs{"name": name})
+	if err != nil {
+		t.Errorf("cleanup assumption_set failed: %s", err)
+	}
+}
@@ -13,14 +13,38 @@ import (
 func (pg *Postgres) InsertSimulationRunHistory(ctx context.Context, scenarioId int32, simulationRunHistory dm.SimulationRunHistory) (int32, error) {
 	sql := q.Get(q.QueryHelper.Insert.SimulationRunHistory)
 	args := pgx.NamedArgs{
-		"scenario_id":             scenarioId,
-		"max_lookback":            simulationRunHistory.MaxLookback,
-		"distribution_type":       simulationRunHistory.DistributionType,
-		"simulation_unit_of_time": simulationRunHistory.SimulationUnitOfTime,
-		"simulation_duration":     simulationRunHistory.SimulationDuration,
-		"iterations":              simulationRunHistory.Iterations,
-		"seed":                    simulationRunHistory.Seed,
-		"degrees_of_freedom":      simulationRunHistory.DegreesOfFreedom,
+		"scenario_id":                 scenarioId,
+		"max_lookback":                simulationRunHistory.MaxLookback,
+		"distribution_type":           simulationRunHistory.DistributionType,
+		"simulation_unit_of_time":     simulationRunHistory.SimulationUnitOfTime,
+		"simulation_duration":         simulationRunHistory.SimulationDuration,
+		"volatility_model":            simulationRunHistory.VolatilityModel,
+		"iterations":                  simulationRunHistory.Iterations,
+		"seed":                        simulationRunHistory.Seed,
+		"degrees_of_freedom":          simulationRunHistory.DegreesOfFreedom,
+		"copula_degrees_of_freedom":   simulationRunHistory.CopulaDegreesOfFreedom,
+		"block_length":                simulationRunHistory.BlockLength,
+		"number_of_regimes":           simulationRunHistory.NumberOfRegimes,
+		"jumps":                       simulationRunHistory.Jumps,
+		"shocks":                      simulationRunHistory.Shocks,
+		"initial_portfolio_value":     simulationRunHistory.InitialPortfolioValue,
+		"currency":                    simulationRunHistory.Currency,
+		"antithetic":                  simulationRunHistory.Antithetic,
+		"control_variate":             simulationRunHistory.ControlVariate,
+		"random_source":               simulationRunHistory.RandomSource,
+		"covariance_estimator":        simulationRunHistory.CovarianceEstimator,
+		"mean_estimator":              simulationRunHistory.MeanEstimator,
+		"half_life":                   simulationRunHistory.HalfLife,
+		"assumption_set_id":           simulationRunHistory.AssumptionSetId,
+		"assumption_weight":           simulationRunHistory.AssumptionWeight,
+		"view_set_id":                 simulationRunHistory.ViewSetId,
+		"views":                       simulationRunHistory.Views,
+		"tau":                         simulationRunHistory.Tau,
+		"benchmark_asset_id":          simulationRunHistory.BenchmarkAssetId,
+		"benchmark_scenario_id":       simulationRunHistory.BenchmarkScenarioId,
+		"risk_free_rate":              simulationRunHistory.RiskFreeRate,
+		"target_var95_standard_error": simulationRunHistory.TargetVaR95StandardError,
+		"max_iterations":              simulationRunHistory.MaxIterations,
 	}
 
 	var run_id int32
@@ -40,13 +64,15 @@ func (pg *Postgres) UpdateSimulationRunAsFailure(ctx context.Context, run_id int
 	return pg.updateSimulationRun(ctx, pgx.NamedArgs{
 		"id":            run_id,
 		"error_message": clean_error_message,
+		"paths_run":     0,
 	})
 }
 
-func (pg *Postgres) UpdateSimulationRunAsSuccess(ctx context.Context, run_id int32) error {
+func (pg *Postgres) UpdateSimulationRunAsSuccess(ctx context.Context, run_id int32, paths_run int) error {
 	return pg.updateSimulationRun(ctx, pgx.NamedArgs{
 		"id":            run_id,
 		"error_message": nil,
+		"paths_run":     paths_run,
 	})
 }
 
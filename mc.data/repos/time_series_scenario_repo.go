@@ -84,12 +84,23 @@ func (pg *Postgres) InsertNewScenarioTx(ctx context.Context, scenario m.Scenario
 	}
 
 	config := m.ScenarioConfiguration{
-		Name:          scenario.Name,
-		FloatedWeight: scenario.FloatedWeight,
+		Name:               scenario.Name,
+		FloatedWeight:      scenario.FloatedWeight,
+		RebalancePolicy:    scenario.RebalancePolicy,
+		RebalanceFrequency: scenario.RebalanceFrequency,
+		RebalanceThreshold: scenario.RebalanceThreshold,
+		AssumptionSetId:    scenario.AssumptionSetId,
 	}
 
 	sql := q.Get(q.QueryHelper.Insert.ScenarioConfiguration)
-	args := pgx.NamedArgs{"name": scenario.Name, "floated_weight": scenario.FloatedWeight}
+	args := pgx.NamedArgs{
+		"name":                scenario.Name,
+		"floated_weight":      scenario.FloatedWeight,
+		"rebalance_policy":    scenario.RebalancePolicy,
+		"rebalance_frequency": scenario.RebalanceFrequency,
+		"rebalance_threshold": scenario.RebalanceThreshold,
+		"assumption_set_id":   scenario.AssumptionSetId,
+	}
 	if err := tx.QueryRow(ctx, sql, args).Scan(
 		&config.Id,
 		&config.CreatedAt,
@@ -152,9 +163,13 @@ func (pg *Postgres) UpdateExistingScenarioTx(ctx context.Context, scenarioID int
 	// update scenario configuration
 	sql := q.Get(q.QueryHelper.Update.ScenarioConfiguration)
 	args := pgx.NamedArgs{
-		"id":             scenarioID,
-		"name":           scenario.Name,
-		"floated_weight": scenario.FloatedWeight,
+		"id":                  scenarioID,
+		"name":                scenario.Name,
+		"floated_weight":      scenario.FloatedWeight,
+		"rebalance_policy":    scenario.RebalancePolicy,
+		"rebalance_frequency": scenario.RebalanceFrequency,
+		"rebalance_threshold": scenario.RebalanceThreshold,
+		"assumption_set_id":   scenario.AssumptionSetId,
 	}
 
 	var config m.ScenarioConfiguration
@@ -162,6 +177,10 @@ func (pg *Postgres) UpdateExistingScenarioTx(ctx context.Context, scenarioID int
 		&config.Id,
 		&config.Name,
 		&config.FloatedWeight,
+		&config.RebalancePolicy,
+		&config.RebalanceFrequency,
+		&config.RebalanceThreshold,
+		&config.AssumptionSetId,
 		&config.CreatedAt,
 		&config.UpdatedAt); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
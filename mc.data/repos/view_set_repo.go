@@ -0,0 +1,122 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	m "mc.data/models"
+	q "mc.data/queries"
+)
+
+// GetViewSetsByScenarioID returns every version of every set of the scenario without the views themselves, newest version first
+func (pg *Postgres) GetViewSetsByScenarioID(ctx context.Context, scenarioId int32) ([]*m.ViewSetConfiguration, error) {
+	sql := q.Get(q.QueryHelper.Select.ViewSetsByScenarioId)
+	args := pgx.NamedArgs{"scenario_id": scenarioId}
+	sets, err := Query[m.ViewSetConfiguration](ctx, pg, sql, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get view sets by scenario id (%d): %w", scenarioId, err)
+	}
+
+	return sets, nil
+}
+
+func (pg *Postgres) GetViewSetByID(ctx context.Context, id int32) (*m.ViewSet, error) {
+	sql := q.Get(q.QueryHelper.Select.ViewSetById)
+	args := pgx.NamedArgs{"id": id}
+	sets, err := Query[m.ViewSetConfiguration](ctx, pg, sql, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get view set by id (%d): %w", id, err)
+	}
+
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("view set id not found (%d)", id)
+	}
+
+	sql = q.Get(q.QueryHelper.Select.ViewSetViewsBySetId)
+	views, err := Query[m.ViewSetView](ctx, pg, sql, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get view set views by id (%d): %w", id, err)
+	}
+
+	set := &m.ViewSet{
+		ViewSetConfiguration: *sets[0],
+		Views:                make([]m.ViewSetView, 0, len(views)),
+	}
+
+	for _, v := range views {
+		set.Views = append(set.Views, *v)
+	}
+
+	return set, nil
+}
+
+// InsertNewViewSetTx publishes the set as the next version of its name for the scenario
+func (pg *Postgres) InsertNewViewSetTx(ctx context.Context, set m.ViewSet, tx pgx.Tx) (*m.ViewSet, error) {
+	if set.ScenarioId == 0 {
+		return nil, fmt.Errorf("view set scenario id is required")
+	}
+	if set.Name == "" {
+		return nil, fmt.Errorf("view set name is required")
+	}
+	if len(set.Views) == 0 {
+		return nil, fmt.Errorf("view set must include at least one view")
+	}
+
+	config := m.ViewSetConfiguration{
+		ScenarioId:  set.ScenarioId,
+		Name:        set.Name,
+		Description: set.Description,
+	}
+
+	sql := q.Get(q.QueryHelper.Insert.ViewSet)
+	args := pgx.NamedArgs{
+		"scenario_id": set.ScenarioId,
+		"name":        set.Name,
+		"description": set.Description,
+	}
+	if err := tx.QueryRow(ctx, sql, args).Scan(
+		&config.Id,
+		&config.Version,
+		&config.CreatedAt); err != nil {
+		return nil, fmt.Errorf("error inserting view set: %w", err)
+	}
+
+	rows := make([][]any, len(set.Views))
+	views := make([]m.ViewSetView, len(set.Views))
+	for i, v := range set.Views {
+		rows[i] = []any{config.Id, v.AssetId, v.VersusAssetId, v.ExpectedReturn, v.Confidence}
+		views[i] = v
+		views[i].SetId = config.Id
+	}
+
+	table_name := pgx.Identifier{"view_set_view"}
+	columns := []string{"set_id", "asset_id", "versus_asset_id", "expected_return", "confidence"}
+	if _, err := tx.CopyFrom(ctx, table_name, columns, pgx.CopyFromRows(rows)); err != nil {
+		return nil, fmt.Errorf("error inserting view set views (%d): %w", config.Id, err)
+	}
+
+	return &m.ViewSet{
+		ViewSetConfiguration: config,
+		Views:                views,
+	}, nil
+}
+
+func (pg *Postgres) InsertNewViewSet(ctx context.Context, set m.ViewSet) (*m.ViewSet, error) {
+	tx, err := pg.GetTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	inserted, err := pg.InsertNewViewSetTx(ctx, set, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing view set insert: %w", err)
+	}
+
+	return inserted, nil
+}
@@ -0,0 +1,248 @@
+package core
+
+import (
+	"math"
+
+	ex "mc.data/extensions"
+	sm "mc.service/models"
+)
+
+const SamplePathReservoirSize = 1_000 // paths kept whole for the sample paths, every other path is only kept in the sketches
+
+// SimulationAggregate streams the paths of a run into sketches so memory does not grow with the number of paths.
+// every job fills its own aggregate, they are merged in job order so the result does not depend on which worker ran which job.
+type SimulationAggregate struct {
+	paths        int
+	initialValue float64
+	seed         uint64
+	antithetic   bool
+
+	batches []*riskAggregate // interleaved by antithetic pair index, merged for the run's risk metrics, their spread is the standard error
+
+	steps             []*stepAggregate // per period of the path
+	reservoir         *pathReservoir
+	maxDrawdownPath   *SimulationResult
+	maxVolatilityPath *SimulationResult
+
+	// monte carlo error of the mean, an observation is a path or the average of an antithetic pair (with its control value)
+	finalValues  welford
+	observations comoments
+	pending      *SimulationResult // first path of a pair waiting for its antithetic path
+
+	rebalances   float64
+	survivors    int
+	finalWeights []float64 // summed over paths that did not run out
+	regimeTime   []float64 // summed fraction of time spent in each regime
+
+	goals       []*goalAggregate // one per goal of the request
+	active      *activeAggregate // nil without a benchmark
+	performance []*tDigest       // one per performance metric, a path only lands in the metrics it defines
+}
+
+// riskAggregate sketches what the risk metrics need from a set of paths
+type riskAggregate struct {
+	paths               int
+	losses              int
+	totalReturns        *tDigest
+	finalValues         *tDigest
+	maxDrawdowns        *tDigest
+	depletionPeriods    *tDigest
+	survivorFinalValues *tDigest
+}
+
+type stepAggregate struct {
+	moments welford
+	values  *tDigest
+}
+
+func newSimulationAggregate(statisticalResources *StatisticalResources, settings sm.SimulationRequestSettings) *SimulationAggregate {
+	a := &SimulationAggregate{
+		initialValue: settings.GetInitialPortfolioValue(),
+		seed:         uint64(settings.Seed),
+		antithetic:   settings.Antithetic,
+		batches:      make([]*riskAggregate, varBatches),
+		steps:        make([]*stepAggregate, settings.SimulationDuration+1),
+		reservoir:    &pathReservoir{size: SamplePathReservoirSize},
+		finalWeights: make([]float64, len(statisticalResources.AssetWeight)),
+		goals:        newGoalAggregates(settings.Goals, settings.SimulationDuration),
+		performance:  newPerformanceAggregates(),
+	}
+
+	for b := range a.batches {
+		a.batches[b] = newRiskAggregate()
+	}
+
+	for t := range a.steps {
+		a.steps[t] = &stepAggregate{values: newTDigest()}
+	}
+
+	if statisticalResources.RegimeModel != nil {
+		a.regimeTime = make([]float64, len(statisticalResources.RegimeModel.Regimes))
+	}
+
+	if statisticalResources.Benchmark != nil {
+		a.active = newActiveAggregate()
+	}
+
+	return a
+}
+
+func newRiskAggregate() *riskAggregate {
+	return &riskAggregate{
+		totalReturns:        newTDigest(),
+		finalValues:         newTDigest(),
+		maxDrawdowns:        newTDigest(),
+		depletionPeriods:    newTDigest(),
+		survivorFinalValues: newTDigest(),
+	}
+}
+
+// aggregateResults streams paths that are already in memory, sim is the index of the path in results
+func aggregateResults(results []*SimulationResult, statisticalResources *StatisticalResources, settings sm.SimulationRequestSettings) *SimulationAggregate {
+	a := newSimulationAggregate(statisticalResources, settings)
+	for sim, r := range results {
+		a.Add(sim, r)
+	}
+	return a
+}
+
+// Add streams the sim'th path of the run, the path is only kept if it lands in the reservoir or is an extreme path
+func (a *SimulationAggregate) Add(sim int, r *SimulationResult) {
+	a.paths++
+	a.batches[(sim/2)%varBatches].Add(r) // whole pairs land in the same batch
+
+	for t, v := range r.PathValues {
+		a.steps[t].moments.Add(v)
+		a.steps[t].values.Add(v)
+	}
+
+	a.reservoir.Add(splitMix64(a.seed^splitMix64(uint64(sim))), r)
+	if a.maxDrawdownPath == nil || r.MaxDrawdown > a.maxDrawdownPath.MaxDrawdown {
+		a.maxDrawdownPath = r
+	}
+	if a.maxVolatilityPath == nil || r.AnnualizedVolatility > a.maxVolatilityPath.AnnualizedVolatility {
+		a.maxVolatilityPath = r
+	}
+
+	a.finalValues.Add(r.FinalValue)
+	if !a.antithetic {
+		a.observations.Add(r.FinalValue, r.ControlValue)
+	} else if sim%2 == 0 {
+		a.pending = r
+	} else if a.pending != nil {
+		a.observations.Add((a.pending.FinalValue+r.FinalValue)/2, (a.pending.ControlValue+r.ControlValue)/2)
+		a.pending = nil
+	}
+
+	a.rebalances += float64(r.Rebalances)
+	if r.DepletionPeriod == 0 { // paths that ran out of money have no weights
+		a.survivors++
+		for i, w := range r.FinalWeights {
+			a.finalWeights[i] += w
+		}
+	}
+
+	if a.regimeTime != nil {
+		totalPeriods := ex.Sum(r.RegimePeriods)
+		for k, periods := range r.RegimePeriods {
+			a.regimeTime[k] += float64(periods) / float64(totalPeriods)
+		}
+	}
+
+	for _, g := range a.goals {
+		g.Add(r.PathValues)
+	}
+
+	if a.active != nil {
+		a.active.Add(r.Active)
+	}
+
+	for i, v := range r.Performance {
+		if !math.IsNaN(v) {
+			a.performance[i].Add(v)
+		}
+	}
+}
+
+// Merge adds every path of other, jobs are merged in order so ties between extreme paths go to the earlier path
+func (a *SimulationAggregate) Merge(other *SimulationAggregate) {
+	a.paths += other.paths
+	for b, batch := range other.batches {
+		a.batches[b].Merge(batch)
+	}
+
+	for t, step := range other.steps {
+		a.steps[t].moments.Merge(step.moments)
+		a.steps[t].values.Merge(step.values)
+	}
+
+	a.reservoir.Merge(other.reservoir)
+	if other.maxDrawdownPath != nil && (a.maxDrawdownPath == nil || other.maxDrawdownPath.MaxDrawdown > a.maxDrawdownPath.MaxDrawdown) {
+		a.maxDrawdownPath = other.maxDrawdownPath
+	}
+	if other.maxVolatilityPath != nil && (a.maxVolatilityPath == nil || other.maxVolatilityPath.AnnualizedVolatility > a.maxVolatilityPath.AnnualizedVolatility) {
+		a.maxVolatilityPath = other.maxVolatilityPath
+	}
+
+	// a pair never splits across jobs, so there is nothing pending to carry over
+	a.finalValues.Merge(other.finalValues)
+	a.observations.Merge(other.observations)
+
+	a.rebalances += other.rebalances
+	a.survivors += other.survivors
+	for i, w := range other.finalWeights {
+		a.finalWeights[i] += w
+	}
+	for k, time := range other.regimeTime {
+		a.regimeTime[k] += time
+	}
+	for i, g := range other.goals {
+		a.goals[i].Merge(g)
+	}
+	if other.active != nil {
+		a.active.Merge(other.active)
+	}
+	for i, sketch := range other.performance {
+		a.performance[i].Merge(sketch)
+	}
+}
+
+// Paths is the number of paths streamed into the aggregate
+func (a *SimulationAggregate) Paths() int {
+	return a.paths
+}
+
+// risk merges the batches into the sketches for every path
+func (a *SimulationAggregate) risk() *riskAggregate {
+	res := newRiskAggregate()
+	for _, batch := range a.batches {
+		res.Merge(batch)
+	}
+	return res
+}
+
+func (r *riskAggregate) Add(result *SimulationResult) {
+	r.paths++
+	if result.TotalReturn < 0 {
+		r.losses++
+	}
+
+	r.totalReturns.Add(result.TotalReturn)
+	r.finalValues.Add(result.FinalValue)
+	r.maxDrawdowns.Add(result.MaxDrawdown)
+	if result.DepletionPeriod > 0 {
+		r.depletionPeriods.Add(float64(result.DepletionPeriod))
+	} else {
+		r.survivorFinalValues.Add(result.FinalValue)
+	}
+}
+
+func (r *riskAggregate) Merge(other *riskAggregate) {
+	r.paths += other.paths
+	r.losses += other.losses
+	r.totalReturns.Merge(other.totalReturns)
+	r.finalValues.Merge(other.finalValues)
+	r.maxDrawdowns.Merge(other.maxDrawdowns)
+	r.depletionPeriods.Merge(other.depletionPeriods)
+	r.survivorFinalValues.Merge(other.survivorFinalValues)
+}
@@ -0,0 +1,169 @@
+package core
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"gonum.org/v1/gonum/stat"
+
+	sm "mc.service/models"
+)
+
+// TestSketches checks the sketches against exact values, including after merging pieces together
+func TestSketches(t *testing.T) {
+	rng := rand.New(rand.NewPCG(42, 1))
+	values := make([]float64, 100_000)
+	for i := range values {
+		values[i] = rng.NormFloat64()
+	}
+
+	whole, pieces := newTDigest(), newTDigest()
+	var moments, merged welford
+	for _, v := range values {
+		whole.Add(v)
+		moments.Add(v)
+	}
+	for p := range 4 {
+		piece := newTDigest()
+		var pieceMoments welford
+		for _, v := range values[p*25_000 : (p+1)*25_000] {
+			piece.Add(v)
+			pieceMoments.Add(v)
+		}
+		pieces.Merge(piece)
+		merged.Merge(pieceMoments)
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	for _, q := range []float64{0.01, 0.05, 0.5, 0.95} {
+		exact := stat.Quantile(q, stat.Empirical, sorted, nil)
+		for name, digest := range map[string]*tDigest{"whole": whole, "merged": pieces} {
+			if got := digest.Quantile(q); math.Abs(got-exact) > 0.01 {
+				t.Errorf("%s: expected quantile %.2f close to %.4f, got %.4f", name, q, exact, got)
+			}
+		}
+	}
+
+	exactTail := stat.Mean(sorted[:1_000], nil)
+	if got := pieces.TailMean(0.01); math.Abs(got-exactTail) > 0.01 {
+		t.Errorf("Expected the 1%% tail mean close to %.4f, got %.4f", exactTail, got)
+	}
+
+	mean, variance := stat.MeanVariance(values, nil)
+	if math.Abs(merged.mean-mean) > 1e-12 || math.Abs(merged.Variance()-variance) > 1e-9 || math.Abs(moments.Variance()-variance) > 1e-9 {
+		t.Errorf("Expected mean %.6f and variance %.6f, got %.6f and %.6f", mean, variance, merged.mean, merged.Variance())
+	}
+
+	var pairs, left, right comoments
+	for i, v := range values[:1_000] {
+		pairs.Add(v, 2*v+values[i+1])
+		if i < 400 {
+			left.Add(v, 2*v+values[i+1])
+		} else {
+			right.Add(v, 2*v+values[i+1])
+		}
+	}
+	left.Merge(right)
+	if math.Abs(left.Covariance()-pairs.Covariance()) > 1e-9 || math.Abs(pairs.Covariance()-2) > 0.3 {
+		t.Errorf("Expected merged covariance %.4f to match %.4f (about 2)", left.Covariance(), pairs.Covariance())
+	}
+
+	// the reservoir keeps the same paths no matter how they were split up
+	all, first, second := &pathReservoir{size: 10}, &pathReservoir{size: 10}, &pathReservoir{size: 10}
+	for sim := range 1_000 {
+		r := &SimulationResult{PathMetrics: PathMetrics{FinalValue: float64(sim)}}
+		all.Add(splitMix64(uint64(sim)), r)
+		if sim%3 == 0 {
+			first.Add(splitMix64(uint64(sim)), r)
+		} else {
+			second.Add(splitMix64(uint64(sim)), r)
+		}
+	}
+	first.Merge(second)
+
+	sample, mergedSample := all.Results(), first.Results()
+	if len(sample) != 10 {
+		t.Fatalf("Expected the reservoir to hold 10 paths, got %d", len(sample))
+	}
+	for i := range sample {
+		if sample[i] != mergedSample[i] {
+			t.Fatalf("Expected the merged reservoir to hold the same paths, got %v and %v", sample[i].FinalValue, mergedSample[i].FinalValue)
+		}
+	}
+}
+
+// TestRunAdaptiveMonteCarloSimulation_Streaming compares a streamed run against the same paths held in memory
+func TestRunAdaptiveMonteCarloSimulation_Streaming(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*100)
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Monthly,
+		SimulationDuration:   60,
+		Iterations:           2*BatchSize + 500,
+		Seed:                 42,
+		RandomSource:         sm.Sobol, // every path is the same however the jobs land, so both runs see the same paths
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	sc := &ServiceContext{Context: context.Background()}
+	res, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	streamed, _, err := sc.RunAdaptiveMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunAdaptiveMonteCarloSimulation: %v", err)
+	}
+	again, _, err := sc.RunAdaptiveMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunAdaptiveMonteCarloSimulation: %v", err)
+	}
+
+	if streamed.Paths() != settings.Iterations || len(streamed.reservoir.entries) != SamplePathReservoirSize {
+		t.Fatalf("Expected %d paths with %d kept whole, got %d and %d", settings.Iterations, SamplePathReservoirSize, streamed.Paths(), len(streamed.reservoir.entries))
+	}
+
+	response := buildSimulationResponse(streamed)
+	if again := buildSimulationResponse(again); again.RiskMetrics != response.RiskMetrics {
+		t.Errorf("Expected the same risk metrics from the same paths, got %+v and %+v", response.RiskMetrics, again.RiskMetrics)
+	}
+
+	totalReturns := make([]float64, len(res))
+	lastValues := make([]float64, len(res))
+	for i, r := range res {
+		totalReturns[i] = r.TotalReturn
+		lastValues[i] = r.FinalValue
+	}
+	slices.Sort(totalReturns)
+	slices.Sort(lastValues)
+
+	metrics := response.RiskMetrics
+	exactVaR95 := stat.Quantile(0.05, stat.Empirical, totalReturns, nil)
+	t.Logf("VaR95 streamed: %.5f, exact: %.5f", metrics.VaR95, exactVaR95)
+	if math.Abs(metrics.VaR95-exactVaR95) > 0.002 {
+		t.Errorf("Expected streamed var95 close to the exact %.5f, got %.5f", exactVaR95, metrics.VaR95)
+	}
+	if math.Abs(metrics.MeanFinalValue-stat.Mean(lastValues, nil)) > 1e-9 {
+		t.Errorf("Expected the exact mean final value %.6f, got %.6f", stat.Mean(lastValues, nil), metrics.MeanFinalValue)
+	}
+
+	last := len(response.Summary.P50) - 1
+	if exactMedian := stat.Quantile(0.5, stat.Empirical, lastValues, nil); math.Abs(response.Summary.P50[last]-exactMedian) > 0.01*exactMedian {
+		t.Errorf("Expected the final step median close to %.4f, got %.4f", exactMedian, response.Summary.P50[last])
+	}
+
+	// the extreme paths are exact, they are tracked over every path
+	maxDrawdown := slices.MaxFunc(res, func(a, b *SimulationResult) int { return int(math.Copysign(1, a.MaxDrawdown-b.MaxDrawdown)) })
+	if streamed.maxDrawdownPath.MaxDrawdown != maxDrawdown.MaxDrawdown {
+		t.Errorf("Expected the maximum drawdown path to have drawdown %.4f, got %.4f", maxDrawdown.MaxDrawdown, streamed.maxDrawdownPath.MaxDrawdown)
+	}
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"fmt"
+	"math"
+
+	dm "mc.data/models"
+	sm "mc.service/models"
+)
+
+// validateCapitalMarketAssumptions checks the values themselves, assets the scenario does not hold are fine
+func validateCapitalMarketAssumptions(assumptions sm.CapitalMarketAssumptions) error {
+	seen := make(map[int32]bool, len(assumptions.Assets))
+	for _, a := range assumptions.Assets {
+		if a.AssetId == 0 {
+			return fmt.Errorf("assetId must be provided for every assumption")
+		}
+		if seen[a.AssetId] {
+			return fmt.Errorf("duplicate assumption for assetId %d", a.AssetId)
+		}
+		seen[a.AssetId] = true
+
+		if a.ExpectedReturn != nil && *a.ExpectedReturn <= -1 {
+			return fmt.Errorf("expected return for assetId %d must be greater than -100%%, got %.4f", a.AssetId, *a.ExpectedReturn)
+		}
+		if a.Volatility != nil && *a.Volatility <= 0 {
+			return fmt.Errorf("volatility for assetId %d must be positive, got %.4f", a.AssetId, *a.Volatility)
+		}
+	}
+
+	pairs := make(map[[2]int32]bool, len(assumptions.Correlations))
+	for _, c := range assumptions.Correlations {
+		if c.AssetIdA == 0 || c.AssetIdB == 0 {
+			return fmt.Errorf("both assetIds must be provided for every correlation")
+		}
+		if c.AssetIdA == c.AssetIdB {
+			return fmt.Errorf("correlation of assetId %d with itself is always 1", c.AssetIdA)
+		}
+		if c.Correlation < -1 || c.Correlation > 1 {
+			return fmt.Errorf("correlation of assetIds %d and %d must be between -1 and 1, got %.4f", c.AssetIdA, c.AssetIdB, c.Correlation)
+		}
+
+		pair := [2]int32{min(c.AssetIdA, c.AssetIdB), max(c.AssetIdA, c.AssetIdB)}
+		if pairs[pair] {
+			return fmt.Errorf("duplicate correlation for assetIds %d and %d", pair[0], pair[1])
+		}
+		pairs[pair] = true
+	}
+
+	return nil
+}
+
+// validateAssumptionSettings checks the assumptions can be used with the rest of the run
+func validateAssumptionSettings(settings sm.SimulationRequestSettings) error {
+	if settings.AssumptionWeight < 0 || settings.AssumptionWeight > 1 {
+		return fmt.Errorf("assumption weight must be between 0 and 1, got %.4f", settings.AssumptionWeight)
+	}
+
+	if settings.Assumptions == nil {
+		return nil
+	}
+
+	if err := validateCapitalMarketAssumptions(*settings.Assumptions); err != nil {
+		return err
+	}
+
+	// these draw their returns from parameters fitted on history, assumptions would be silently ignored
+	if settings.DistType == sm.HistoricalBootstrap {
+		return fmt.Errorf("capital market assumptions are not supported with historical bootstrap, the bootstrap draws historical returns as is")
+	}
+	if settings.Regimes != nil {
+		return fmt.Errorf("capital market assumptions are not supported with regime switching, every regime has its own fitted parameters")
+	}
+	for _, a := range settings.Assumptions.Assets {
+		if a.Volatility == nil {
+			continue
+		}
+		if settings.VolatilityModel == sm.Garch {
+			return fmt.Errorf("volatility assumptions are not supported with garch, the volatility comes from the fitted model")
+		}
+		if settings.Jumps != nil && settings.Jumps.Estimate {
+			return fmt.Errorf("volatility assumptions are not supported with estimated jumps, the diffusion volatility comes from the history")
+		}
+	}
+
+	return nil
+}
+
+// resolveCapitalMarketAssumptions fills in the assumptions of a run, inline assumptions first, then the requested set, then the scenario's set
+func (sc *ServiceContext) resolveCapitalMarketAssumptions(scenario *dm.Scenario, settings sm.SimulationRequestSettings) (sm.SimulationRequestSettings, error) {
+	if settings.Assumptions != nil {
+		settings.AssumptionSetId = 0 // inline assumptions are not a stored set
+		return settings, nil
+	}
+
+	if settings.AssumptionSetId == 0 && scenario.AssumptionSetId != nil {
+		settings.AssumptionSetId = *scenario.AssumptionSetId
+	}
+
+	if settings.AssumptionSetId == 0 {
+		return settings, nil
+	}
+
+	set, err := sc.PostgresConnection.GetAssumptionSetByID(sc.Context, settings.AssumptionSetId)
+	if err != nil {
+		return settings, err
+	}
+
+	assumptions := sm.MapAssumptionSetToCapitalMarketAssumptions(set)
+	settings.Assumptions = &assumptions
+	return settings, nil
+}
+
+// applyCapitalMarketAssumptions blends the assumptions into the historical mu, sigma and correlations of the assets the scenario holds,
+// weight 1 replaces history. the correlations may no longer be positive definite, which the correlation repair takes care of.
+func applyCapitalMarketAssumptions(statisticalResources *StatisticalResources, seriesReturns []*SeriesReturns, settings sm.SimulationRequestSettings) []sm.AssumptionSummary {
+	weight := settings.GetAssumptionWeight()
+	blend := func(historical, assumed float64) float64 {
+		return weight*assumed + (1-weight)*historical
+	}
+
+	index := make(map[int32]int, len(seriesReturns))
+	res := make([]sm.AssumptionSummary, len(seriesReturns))
+	for i, r := range seriesReturns {
+		index[r.AssetId] = i
+		res[i] = sm.AssumptionSummary{
+			AssetId:         r.AssetId,
+			HistoricalMu:    statisticalResources.Mu[i],
+			HistoricalSigma: statisticalResources.Sigma[i],
+		}
+	}
+
+	for _, a := range settings.Assumptions.Assets {
+		i, ok := index[a.AssetId]
+		if !ok {
+			continue
+		}
+
+		if a.ExpectedReturn != nil {
+			statisticalResources.Mu[i] = blend(statisticalResources.Mu[i], *a.ExpectedReturn)
+		}
+		if a.Volatility != nil {
+			statisticalResources.Sigma[i] = blend(statisticalResources.Sigma[i], *a.Volatility)
+		}
+	}
+
+	for _, c := range settings.Assumptions.Correlations {
+		i, okA := index[c.AssetIdA]
+		j, okB := index[c.AssetIdB]
+		if !okA || !okB {
+			continue
+		}
+
+		statisticalResources.CorrMatrix.SetSym(i, j, blend(statisticalResources.CorrMatrix.At(i, j), c.Correlation))
+	}
+
+	for i := range res {
+		res[i].Mu = statisticalResources.Mu[i]
+		res[i].Sigma = statisticalResources.Sigma[i]
+	}
+
+	return res
+}
+
+// getPeriodStandardDeviations converts the annualized sigma back to the units of each asset's return history
+func getPeriodStandardDeviations(statisticalResources *StatisticalResources, seriesReturns []*SeriesReturns) []float64 {
+	res := make([]float64, len(seriesReturns))
+	for i, r := range seriesReturns {
+		res[i] = statisticalResources.Sigma[i] / math.Sqrt(float64(r.AnnualizationFactor))
+	}
+	return res
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	sm "mc.service/models"
+)
+
+// getAssumptionSeriesReturns is the mock returns with asset ids from 1, since 0 is not a valid asset id in the assumptions
+func getAssumptionSeriesReturns(t *testing.T) []*SeriesReturns {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily)
+	for i, r := range seriesReturns {
+		r.AssetId = int32(i + 1)
+	}
+	return seriesReturns
+}
+
+func TestGetStatisticalResources_CapitalMarketAssumptions(t *testing.T) {
+	seriesReturns := getAssumptionSeriesReturns(t)
+	settings := sm.SimulationRequestSettings{DistType: sm.StandardNormal}
+	historical, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	mu, sigma := 0.05, 0.30
+	settings.Assumptions = &sm.CapitalMarketAssumptions{
+		Assets: []sm.AssetAssumption{
+			{AssetId: 1, ExpectedReturn: &mu},
+			{AssetId: 2, Volatility: &sigma},
+			{AssetId: 99, ExpectedReturn: &mu}, // not in the scenario
+		},
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	// no weight replaces history
+	if sr.Mu[0] != mu || sr.Sigma[0] != historical.Sigma[0] {
+		t.Errorf("asset 1: expected mu %.4f and the historical sigma, got %.4f and %.4f", mu, sr.Mu[0], sr.Sigma[0])
+	}
+	if sr.Sigma[1] != sigma || sr.Mu[1] != historical.Mu[1] {
+		t.Errorf("asset 2: expected sigma %.4f and the historical mu, got %.4f and %.4f", sigma, sr.Sigma[1], sr.Mu[1])
+	}
+	if sr.Mu[2] != historical.Mu[2] || sr.Sigma[2] != historical.Sigma[2] {
+		t.Errorf("asset 3: expected history to be untouched, got mu %.4f and sigma %.4f", sr.Mu[2], sr.Sigma[2])
+	}
+
+	// the covariance follows the new volatility, the correlations stay
+	if expected := sigma * sigma / sm.Daily; math.Abs(sr.CovMatrix.At(1, 1)-expected) > 1e-15 {
+		t.Errorf("Expected variance %.6e, got %.6e", expected, sr.CovMatrix.At(1, 1))
+	}
+	historicalCorr := historical.CovMatrix.At(0, 1) / math.Sqrt(historical.CovMatrix.At(0, 0)*historical.CovMatrix.At(1, 1))
+	corr := sr.CovMatrix.At(0, 1) / math.Sqrt(sr.CovMatrix.At(0, 0)*sr.CovMatrix.At(1, 1))
+	if math.Abs(corr-historicalCorr) > 1e-12 {
+		t.Errorf("Expected correlation %.6f, got %.6f", historicalCorr, corr)
+	}
+
+	if len(sr.Assumptions) != 3 || sr.Assumptions[0].HistoricalMu != historical.Mu[0] || sr.Assumptions[0].Mu != mu {
+		t.Errorf("Expected a summary of every scenario asset, got %+v", sr.Assumptions)
+	}
+
+	// half the weight lands half way
+	settings.AssumptionWeight = 0.5
+	sr, err = GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+	if expected := (mu + historical.Mu[0]) / 2; math.Abs(sr.Mu[0]-expected) > 1e-12 {
+		t.Errorf("Expected a blended mu of %.6f, got %.6f", expected, sr.Mu[0])
+	}
+	if expected := (sigma + historical.Sigma[1]) / 2; math.Abs(sr.Sigma[1]-expected) > 1e-12 {
+		t.Errorf("Expected a blended sigma of %.6f, got %.6f", expected, sr.Sigma[1])
+	}
+}
+
+func TestGetStatisticalResources_AssumedCorrelations(t *testing.T) {
+	seriesReturns := getAssumptionSeriesReturns(t)
+
+	// strongly positive between 1 and 2 and between 2 and 3 but strongly negative between 1 and 3 is not a valid correlation matrix
+	settings := sm.SimulationRequestSettings{
+		DistType: sm.StandardNormal,
+		Assumptions: &sm.CapitalMarketAssumptions{
+			Correlations: []sm.AssumedCorrelation{
+				{AssetIdA: 2, AssetIdB: 1, Correlation: 0.9},
+				{AssetIdA: 2, AssetIdB: 3, Correlation: 0.9},
+				{AssetIdA: 1, AssetIdB: 3, Correlation: -0.9},
+			},
+		},
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+	if !sr.CorrelationRepair.Repaired {
+		t.Errorf("Expected the assumed correlations to be repaired, got %+v", sr.CorrelationRepair)
+	}
+
+	// the repair keeps the volatilities
+	historical, _ := GetStatisticalResources(seriesReturns, sm.SimulationRequestSettings{DistType: sm.StandardNormal})
+	for i := range seriesReturns {
+		if math.Abs(sr.CovMatrix.At(i, i)-historical.CovMatrix.At(i, i)) > 1e-15 {
+			t.Errorf("asset %d: expected variance %.6e, got %.6e", i, historical.CovMatrix.At(i, i), sr.CovMatrix.At(i, i))
+		}
+	}
+
+	// a valid set of correlations is used as is
+	settings.Assumptions.Correlations = []sm.AssumedCorrelation{{AssetIdA: 1, AssetIdB: 3, Correlation: 0.1}}
+	sr, err = GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+	if corr := sr.CovMatrix.At(0, 2) / math.Sqrt(sr.CovMatrix.At(0, 0)*sr.CovMatrix.At(2, 2)); math.Abs(corr-0.1) > 1e-12 {
+		t.Errorf("Expected correlation 0.1, got %.6f", corr)
+	}
+}
+
+func TestValidateAssumptionSettings(t *testing.T) {
+	volatility := 0.2
+	assumptions := &sm.CapitalMarketAssumptions{Assets: []sm.AssetAssumption{{AssetId: 1, Volatility: &volatility}}}
+
+	cases := map[string]sm.SimulationRequestSettings{
+		"weight above 1":   {Assumptions: assumptions, AssumptionWeight: 1.5},
+		"bootstrap":        {Assumptions: assumptions, DistType: sm.HistoricalBootstrap},
+		"regimes":          {Assumptions: assumptions, Regimes: &sm.RegimeSettings{NumberOfRegimes: 2}},
+		"garch volatility": {Assumptions: assumptions, VolatilityModel: sm.Garch},
+		"estimated jumps":  {Assumptions: assumptions, Jumps: &sm.JumpSettings{Estimate: true}},
+		"correlation above 1": {Assumptions: &sm.CapitalMarketAssumptions{
+			Correlations: []sm.AssumedCorrelation{{AssetIdA: 1, AssetIdB: 2, Correlation: 1.2}},
+		}},
+		"duplicate pair": {Assumptions: &sm.CapitalMarketAssumptions{
+			Correlations: []sm.AssumedCorrelation{{AssetIdA: 1, AssetIdB: 2, Correlation: 0.2}, {AssetIdA: 2, AssetIdB: 1, Correlation: 0.3}},
+		}},
+	}
+
+	for name, settings := range cases {
+		if err := validateAssumptionSettings(settings); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if err := validateAssumptionSettings(sm.SimulationRequestSettings{Assumptions: assumptions, AssumptionWeight: 0.5}); err != nil {
+		t.Errorf("Expected valid assumptions to pass: %v", err)
+	}
+
+	// given jump parameters leave the assumed volatility as the diffusion volatility, only estimating them replaces it
+	market := sm.JumpParameters{Intensity: 1, Mean: -0.05, Volatility: 0.02}
+	if err := validateAssumptionSettings(sm.SimulationRequestSettings{Assumptions: assumptions, Jumps: &sm.JumpSettings{MarketJump: &market}}); err != nil {
+		t.Errorf("Expected volatility assumptions with given jumps to pass: %v", err)
+	}
+}
@@ -0,0 +1,494 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+
+	dm "mc.data/models"
+	sm "mc.service/models"
+)
+
+// BenchmarkResources is the benchmark simulated next to the portfolio, the weights line up with the series returns and then the
+// benchmark's own assets. its own assets are estimated and drawn apart from the portfolio, so the portfolio is estimated and
+// simulated exactly as it would be without a benchmark.
+type BenchmarkResources struct {
+	Weights   []float64
+	Rebalance RebalancePolicy
+
+	// the assets the scenario does not hold, empty when the benchmark only holds scenario assets.
+	// these use their sample mu and sigma with constant volatility, and only take the market wide jumps and shocks
+	AssetIds          []int32
+	Mu                []float64         // annualized
+	Sigma             []float64         // annualized
+	CholeskyCross     *mat.Dense        // loads the benchmark shocks on the portfolio's independent normals of the period
+	CholeskyOwn       *mat.TriDense     // the rest of the benchmark shocks, drawn from the benchmark's own stream
+	HistoricalReturns [][]float64       // rows line up with the portfolio's (historical bootstrap)
+	Shocks            map[int][]float64 // deterministic log returns keyed by the 0 based period, nil without shocks
+}
+
+// benchmark is a resolved benchmark request, the weights are by asset id
+type benchmark struct {
+	weights   map[int32]float64
+	rebalance RebalancePolicy
+}
+
+// ActiveMetrics are a path's performance against the benchmark, from the log returns of both before any cash flow
+type ActiveMetrics struct {
+	ActiveReturn     float64 // total log return over the benchmark, negative when the benchmark did better
+	TrackingError    float64 // annualized
+	InformationRatio float64
+	ExcessReturn     float64 // annualized, geometric
+	RelativeDrawdown float64
+}
+
+// activeReturns tracks the active log returns of a path as it runs
+type activeReturns struct {
+	periods     int
+	sum         float64
+	sumSquares  float64
+	peak        float64 // highest cumulative active log return so far, the path starts level at 0
+	maxDrawdown float64
+}
+
+// activeAggregate sketches the active metrics of every path
+type activeAggregate struct {
+	underperforming   int
+	trackingErrors    *tDigest
+	informationRatios *tDigest
+	excessReturns     *tDigest
+	relativeDrawdowns *tDigest
+}
+
+func validateBenchmarkSettings(scenario *dm.Scenario, settings sm.BenchmarkSettings) error {
+	if settings.AssetId < 0 || settings.ScenarioId < 0 {
+		return fmt.Errorf("benchmark asset id and scenario id must not be negative")
+	}
+
+	if (settings.AssetId == 0) == (settings.ScenarioId == 0) {
+		return fmt.Errorf("benchmark must be either an asset or a scenario")
+	}
+
+	if settings.ScenarioId == scenario.Id {
+		return fmt.Errorf("scenario %s can not be its own benchmark", scenario.Name)
+	}
+
+	return nil
+}
+
+// getBenchmark resolves the benchmark of a run, nil when the run has none
+func (sc *ServiceContext) getBenchmark(scenario *dm.Scenario, settings sm.SimulationRequestSettings) (*benchmark, error) {
+	if settings.Benchmark == nil {
+		return nil, nil
+	}
+
+	if err := validateBenchmarkSettings(scenario, *settings.Benchmark); err != nil {
+		return nil, err
+	}
+
+	res, err := sc.resolveBenchmark(*settings.Benchmark)
+	if err != nil {
+		return nil, err
+	}
+
+	// the benchmark's own assets are drawn off the portfolio's normals, which only have one correlation when there is one regime
+	if settings.Regimes != nil && getBenchmarkAssetsScenario(scenario, res) != nil {
+		return nil, fmt.Errorf("a benchmark holding assets outside the scenario is not supported with regime switching")
+	}
+
+	return res, nil
+}
+
+func (sc *ServiceContext) resolveBenchmark(settings sm.BenchmarkSettings) (*benchmark, error) {
+	// a single asset never needs rebalancing, so the zero value policy is fine
+	if settings.AssetId != 0 {
+		return &benchmark{weights: map[int32]float64{settings.AssetId: 1}}, nil
+	}
+
+	benchmarkScenario, err := sc.PostgresConnection.GetScenarioByID(sc.Context, settings.ScenarioId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting benchmark scenario: %v", err)
+	}
+
+	res := &benchmark{weights: make(map[int32]float64, len(benchmarkScenario.Components))}
+	total := 0.0
+	for _, c := range benchmarkScenario.Components {
+		res.weights[c.AssetId] += c.Weight
+		total += c.Weight
+	}
+	if math.Abs(total-1) > 1e-6 {
+		return nil, fmt.Errorf("benchmark scenario %s weights must sum to 1.0, got %.6f", benchmarkScenario.Name, total)
+	}
+
+	if res.rebalance, err = getRebalancePolicy(benchmarkScenario.ScenarioConfiguration); err != nil {
+		return nil, fmt.Errorf("benchmark scenario %s: %w", benchmarkScenario.Name, err)
+	}
+
+	return res, nil
+}
+
+// getBenchmarkAssetsScenario holds the benchmark assets the scenario does not hold, nil when it holds them all.
+// they are fetched and estimated on their own, adding them to the scenario would move the portfolio's own estimates.
+func getBenchmarkAssetsScenario(scenario *dm.Scenario, b *benchmark) *dm.Scenario {
+	held := make(map[int32]bool, len(scenario.Components))
+	for _, c := range scenario.Components {
+		held[c.AssetId] = true
+	}
+
+	var components []dm.ScenarioConfigurationComponent
+	for id := range b.weights {
+		if !held[id] {
+			components = append(components, dm.ScenarioConfigurationComponent{ConfigurationId: scenario.Id, AssetId: id})
+		}
+	}
+	if components == nil {
+		return nil
+	}
+
+	// sorted like the series returns, so the benchmark draws the same way every run
+	slices.SortFunc(components, func(a, b dm.ScenarioConfigurationComponent) int {
+		return int(a.AssetId - b.AssetId)
+	})
+
+	res := *scenario
+	res.Components = components
+	return &res
+}
+
+// getBenchmarkResources lines the benchmark weights up with the series returns and then the benchmark returns, which are the
+// benchmark assets the scenario does not hold. every benchmark asset needs returns.
+func getBenchmarkResources(seriesReturns, benchmarkReturns []*SeriesReturns, statisticalResources *StatisticalResources, b *benchmark, settings sm.SimulationRequestSettings) (*BenchmarkResources, error) {
+	res := &BenchmarkResources{
+		Weights:   make([]float64, len(seriesReturns)+len(benchmarkReturns)),
+		Rebalance: b.rebalance,
+	}
+
+	found := 0
+	for i, r := range slices.Concat(seriesReturns, benchmarkReturns) {
+		if w, ok := b.weights[r.AssetId]; ok {
+			res.Weights[i] = w
+			found++
+		}
+	}
+
+	if found != len(b.weights) {
+		return nil, fmt.Errorf("only %d of the %d benchmark assets have returns", found, len(b.weights))
+	}
+
+	if len(benchmarkReturns) == 0 {
+		return res, nil
+	}
+
+	var err error
+	if settings.DistType == sm.HistoricalBootstrap {
+		if res.HistoricalReturns, err = getBenchmarkHistoricalRows(seriesReturns, benchmarkReturns, settings); err != nil {
+			return nil, err
+		}
+	} else {
+		if err = res.estimate(seriesReturns, benchmarkReturns, statisticalResources); err != nil {
+			return nil, err
+		}
+	}
+
+	res.AssetIds = make([]int32, len(benchmarkReturns))
+	for i, r := range benchmarkReturns {
+		res.AssetIds[i] = r.AssetId
+	}
+
+	if len(settings.Shocks) > 0 {
+		shocks, err := getShockOverlay(slices.Concat(seriesReturns, benchmarkReturns), settings)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Shocks = make(map[int][]float64, len(shocks))
+		for period, overlay := range shocks {
+			res.Shocks[period] = overlay[len(seriesReturns):]
+		}
+	}
+
+	return res, nil
+}
+
+// estimate fits the benchmark's own assets, the sample mu and sigma of each and its correlations with the portfolio assets
+// and each other over the dates both have returns for
+func (res *BenchmarkResources) estimate(seriesReturns, benchmarkReturns []*SeriesReturns, statisticalResources *StatisticalResources) error {
+	nPortfolio, nBenchmark := len(seriesReturns), len(benchmarkReturns)
+	res.Mu = make([]float64, nBenchmark)
+	res.Sigma = make([]float64, nBenchmark)
+	cross := mat.NewDense(nBenchmark, nPortfolio, nil)
+	own := mat.NewSymDense(nBenchmark, nil)
+
+	for i, r := range benchmarkReturns {
+		mean, std := stat.MeanStdDev(r.Returns, nil)
+		res.Mu[i] = mean * float64(r.AnnualizationFactor)
+		res.Sigma[i] = std * math.Sqrt(float64(r.AnnualizationFactor))
+
+		for j, p := range seriesReturns {
+			corr, err := getOverlappingCorrelation(r, p)
+			if err != nil {
+				return err
+			}
+			cross.Set(i, j, corr)
+		}
+
+		own.SetSym(i, i, 1)
+		for j := range i {
+			corr, err := getOverlappingCorrelation(r, benchmarkReturns[j])
+			if err != nil {
+				return err
+			}
+			own.SetSym(i, j, corr)
+		}
+	}
+
+	var err error
+	res.CholeskyCross, res.CholeskyOwn, err = getBenchmarkCholesky(statisticalResources.CholeskyCorrL, cross, own)
+	return err
+}
+
+// getOverlappingCorrelation is the sample correlation of two return series over the dates both have
+func getOverlappingCorrelation(a, b *SeriesReturns) (float64, error) {
+	byDate := make(map[int64]float64, len(b.Dates))
+	for i, d := range b.Dates {
+		byDate[d.Unix()] = b.Returns[i]
+	}
+
+	var x, y []float64
+	for i, d := range a.Dates {
+		if r, ok := byDate[d.Unix()]; ok {
+			x = append(x, a.Returns[i])
+			y = append(y, r)
+		}
+	}
+
+	if len(x) < 3 {
+		return 0, fmt.Errorf("assets %d and %d only have %d returns on the same dates", a.AssetId, b.AssetId, len(x))
+	}
+
+	return stat.Correlation(x, y, nil), nil
+}
+
+// getBenchmarkCholesky extends the portfolio's correlation cholesky with the benchmark rows, the cross block loads the benchmark
+// shocks on the portfolio's independent normals and the own block draws what is left. the portfolio block is whatever the portfolio
+// is simulated with, so cross correlations that do not fit it are shrunk toward 0 until they do.
+func getBenchmarkCholesky(portfolioL *mat.TriDense, cross *mat.Dense, own *mat.SymDense) (*mat.Dense, *mat.TriDense, error) {
+	if _, err := GetCholeskyDecomposition(own); err != nil {
+		own = clipEigenvalues(own)
+	}
+
+	for step := 10; step >= 0; step-- {
+		shrink := float64(step) / 10
+
+		var shrunk mat.Dense
+		shrunk.Scale(shrink, cross)
+
+		// the portfolio correlation is L L^T, so the cross block C = B L^T, which gives B = (L^-1 C^T)^T
+		var loadingT mat.Dense
+		if err := loadingT.Solve(portfolioL, shrunk.T()); err != nil {
+			return nil, nil, fmt.Errorf("failed to load the benchmark on the portfolio: %w", err)
+		}
+		loading := mat.DenseCopyOf(loadingT.T())
+
+		var explained mat.SymDense
+		explained.SymOuterK(1, loading)
+		residual := subtractSym(own, &explained)
+
+		if L, err := GetCholeskyDecomposition(residual); err == nil {
+			if step < 10 {
+				log.Printf("benchmark correlations with the portfolio shrunk to %.0f%% to fit the portfolio correlation", shrink*100)
+			}
+			return loading, L, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("benchmark correlation matrix is not positive definite")
+}
+
+// getBenchmarkHistoricalRows lines the benchmark's own returns up with the portfolio's bootstrap rows by date
+func getBenchmarkHistoricalRows(seriesReturns, benchmarkReturns []*SeriesReturns, settings sm.SimulationRequestSettings) ([][]float64, error) {
+	dates := seriesReturns[0].Dates
+	rows := make([][]float64, len(dates))
+	for i := range rows {
+		rows[i] = make([]float64, len(benchmarkReturns))
+	}
+
+	for j, r := range benchmarkReturns {
+		if r.AnnualizationFactor != settings.SimulationUnitOfTime {
+			return nil, fmt.Errorf("historical bootstrap requires the simulation unit of time (%s) to match the return history (%s)",
+				sm.ConvertFrequencyToString(settings.SimulationUnitOfTime), sm.ConvertFrequencyToString(r.AnnualizationFactor))
+		}
+
+		byDate := make(map[int64]float64, len(r.Dates))
+		for i, d := range r.Dates {
+			byDate[d.Unix()] = r.Returns[i]
+		}
+
+		for i, d := range dates {
+			ret, ok := byDate[d.Unix()]
+			if !ok {
+				return nil, fmt.Errorf("benchmark asset %d has no return on %s for the historical bootstrap", r.AssetId, d.Format("2006-01-02"))
+			}
+			rows[i][j] = ret
+		}
+	}
+
+	return rows, nil
+}
+
+// GetBenchmarkReturns is the period's returns for the benchmark, the portfolio's returns followed by the benchmark's own assets.
+// called after GetCorrelatedReturns, the own assets load on that period's normals and draw the rest from their own stream,
+// so the portfolio draws the very same numbers with or without a benchmark.
+func (wr *WorkerResource) GetBenchmarkReturns(period int, portfolioReturns []float64, simulationUnitOfTime int) []float64 {
+	b := wr.Benchmark
+	if len(b.AssetIds) == 0 {
+		return portfolioReturns
+	}
+
+	wr.benchmarkReturns = append(wr.benchmarkReturns[:0], portfolioReturns...)
+	if b.HistoricalReturns != nil {
+		wr.benchmarkReturns = append(wr.benchmarkReturns, b.HistoricalReturns[wr.bootstrapRow]...)
+	} else {
+		n := len(b.AssetIds)
+		own := make([]float64, n)
+		for i := range own {
+			own[i] = wr.benchmarkNormal.Rand()
+			if wr.antithetic {
+				own[i] = -own[i]
+			}
+		}
+
+		z := mat.NewVecDense(n, nil)
+		z.MulVec(b.CholeskyOwn, mat.NewVecDense(n, own))
+		loaded := mat.NewVecDense(n, nil)
+		loaded.MulVec(b.CholeskyCross, mat.NewVecDense(len(wr.normals), wr.normals))
+		z.AddVec(z, loaded)
+
+		for i := range n {
+			ret := CalculateLogNormalReturn(b.Mu[i], b.Sigma[i], wr.getBenchmarkShock(z.AtVec(i)), simulationUnitOfTime)
+			wr.benchmarkReturns = append(wr.benchmarkReturns, ret+wr.marketJump)
+		}
+	}
+
+	for i, shock := range b.Shocks[period] {
+		wr.benchmarkReturns[len(portfolioReturns)+i] += shock
+	}
+
+	return wr.benchmarkReturns
+}
+
+// getBenchmarkShock turns a correlated normal into a unit variance shock the same way the portfolio's dist type does
+func (wr *WorkerResource) getBenchmarkShock(z float64) float64 {
+	scale := studentTVarianceScale(wr.Df)
+	switch wr.DistType {
+	case sm.StudentT:
+		return wr.tDist.Quantile(clampUniform(wr.normalDist.CDF(z))) * scale
+	case sm.MultivariateStudentT:
+		return z / wr.mixing * scale
+	case sm.StudentTCopula:
+		return wr.tDist.Quantile(clampUniform(wr.copulaTDist.CDF(z/wr.mixing))) * scale
+	default:
+		return z
+	}
+}
+
+func (a *activeReturns) Reset() {
+	*a = activeReturns{}
+}
+
+// Add takes the log returns of the portfolio and the benchmark for a period
+func (a *activeReturns) Add(portfolioLogReturn, benchmarkLogReturn float64) {
+	active := portfolioLogReturn - benchmarkLogReturn
+	a.periods++
+	a.sum += active
+	a.sumSquares += active * active
+
+	a.peak = math.Max(a.peak, a.sum)
+	a.maxDrawdown = math.Max(a.maxDrawdown, 1-math.Exp(a.sum-a.peak))
+}
+
+func (a *activeReturns) Metrics(simulationUnitOfTime int) *ActiveMetrics {
+	res := &ActiveMetrics{
+		ActiveReturn:     a.sum,
+		RelativeDrawdown: a.maxDrawdown,
+	}
+	if a.periods == 0 {
+		return res
+	}
+
+	periodsPerYear := float64(simulationUnitOfTime)
+	n := float64(a.periods)
+	res.ExcessReturn = math.Exp(a.sum*periodsPerYear/n) - 1
+
+	if a.periods > 1 {
+		variance := (a.sumSquares - a.sum*a.sum/n) / (n - 1)
+		res.TrackingError = math.Sqrt(math.Max(variance, 0) * periodsPerYear)
+	}
+
+	// no tracking error means the portfolio is the benchmark, there is no ratio to speak of
+	if res.TrackingError > 0 {
+		res.InformationRatio = a.sum / n * periodsPerYear / res.TrackingError
+	}
+
+	return res
+}
+
+func newActiveAggregate() *activeAggregate {
+	return &activeAggregate{
+		trackingErrors:    newTDigest(),
+		informationRatios: newTDigest(),
+		excessReturns:     newTDigest(),
+		relativeDrawdowns: newTDigest(),
+	}
+}
+
+func (a *activeAggregate) Add(metrics *ActiveMetrics) {
+	if metrics.ActiveReturn < 0 {
+		a.underperforming++
+	}
+
+	a.trackingErrors.Add(metrics.TrackingError)
+	a.informationRatios.Add(metrics.InformationRatio)
+	a.excessReturns.Add(metrics.ExcessReturn)
+	a.relativeDrawdowns.Add(metrics.RelativeDrawdown)
+}
+
+func (a *activeAggregate) Merge(other *activeAggregate) {
+	a.underperforming += other.underperforming
+	a.trackingErrors.Merge(other.trackingErrors)
+	a.informationRatios.Merge(other.informationRatios)
+	a.excessReturns.Merge(other.excessReturns)
+	a.relativeDrawdowns.Merge(other.relativeDrawdowns)
+}
+
+// calculateBenchmarkSummary reports the active metrics of the run, the weights only list the assets the benchmark holds
+func calculateBenchmarkSummary(aggregate *SimulationAggregate, statisticalResources *StatisticalResources, seriesReturns []*SeriesReturns, settings sm.BenchmarkSettings) *sm.BenchmarkSummary {
+	res := &sm.BenchmarkSummary{
+		AssetId:                      settings.AssetId,
+		ScenarioId:                   settings.ScenarioId,
+		ProbabilityOfUnderperforming: float64(aggregate.active.underperforming) / float64(aggregate.paths),
+		TrackingError:                calculateMetricDistribution(aggregate.active.trackingErrors),
+		InformationRatio:             calculateMetricDistribution(aggregate.active.informationRatios),
+		ExcessReturn:                 calculateMetricDistribution(aggregate.active.excessReturns),
+		RelativeDrawdown:             calculateMetricDistribution(aggregate.active.relativeDrawdowns),
+	}
+
+	assetIds := make([]int32, 0, len(statisticalResources.Benchmark.Weights))
+	for _, r := range seriesReturns {
+		assetIds = append(assetIds, r.AssetId)
+	}
+	assetIds = append(assetIds, statisticalResources.Benchmark.AssetIds...)
+
+	for i, w := range statisticalResources.Benchmark.Weights {
+		if w > 0 {
+			res.AssetIds = append(res.AssetIds, assetIds[i])
+			res.Weights = append(res.Weights, w)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,263 @@
+package core
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+
+	dm "mc.data/models"
+	sm "mc.service/models"
+)
+
+func TestActiveReturns_Metrics(t *testing.T) {
+	var active activeReturns
+	portfolio := []float64{0.02, -0.01, 0.03, 0.00}
+	benchmark := []float64{0.01, 0.01, 0.01, 0.01}
+	for i := range portfolio {
+		active.Add(portfolio[i], benchmark[i])
+	}
+
+	// active returns are 0.01, -0.02, 0.02, -0.01
+	metrics := active.Metrics(sm.Weekly)
+	if math.Abs(metrics.ActiveReturn) > 1e-15 {
+		t.Errorf("Expected no total active return, got %.6f", metrics.ActiveReturn)
+	}
+
+	variance := (0.01*0.01 + 0.02*0.02 + 0.02*0.02 + 0.01*0.01) / 3
+	if expected := math.Sqrt(variance * sm.Weekly); math.Abs(metrics.TrackingError-expected) > 1e-12 {
+		t.Errorf("Expected tracking error %.6f, got %.6f", expected, metrics.TrackingError)
+	}
+	if math.Abs(metrics.InformationRatio) > 1e-12 || math.Abs(metrics.ExcessReturn) > 1e-12 {
+		t.Errorf("Expected no information ratio or excess return, got %.6f and %.6f", metrics.InformationRatio, metrics.ExcessReturn)
+	}
+
+	// the deepest fall is from +0.01 down to -0.01
+	if expected := 1 - math.Exp(-0.02); math.Abs(metrics.RelativeDrawdown-expected) > 1e-12 {
+		t.Errorf("Expected relative drawdown %.6f, got %.6f", expected, metrics.RelativeDrawdown)
+	}
+
+	active.Reset()
+	if metrics := active.Metrics(sm.Weekly); *metrics != (ActiveMetrics{}) {
+		t.Errorf("Expected a reset path to have no metrics, got %+v", metrics)
+	}
+}
+
+// TestRunMonteCarloSimulation_Benchmark checks the benchmark steps on the very same draws as the portfolio
+func TestRunMonteCarloSimulation_Benchmark(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*5)
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Weekly,
+		SimulationDuration:   52,
+		Iterations:           500,
+		Seed:                 42,
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+	sr.Rebalance = RebalancePolicy{Policy: sm.BuyAndHold}
+
+	// all in the first asset
+	sr.Benchmark, err = getBenchmarkResources(seriesReturns, nil, sr, &benchmark{weights: map[int32]float64{seriesReturns[0].AssetId: 1}}, settings)
+	if err != nil {
+		t.Fatalf("getBenchmarkResources: %v", err)
+	}
+
+	sc := &ServiceContext{Context: context.Background()}
+	paths, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	underperforming := 0
+	for sim, path := range paths {
+		expected := math.Log(1+path.TotalReturn) - math.Log(1+path.AssetReturns[0])
+		if math.Abs(path.Active.ActiveReturn-expected) > 1e-9 {
+			t.Fatalf("path %d: expected an active return of %.9f, got %.9f", sim, expected, path.Active.ActiveReturn)
+		}
+		if path.Active.ActiveReturn < 0 {
+			underperforming++
+		}
+	}
+
+	summary := calculateBenchmarkSummary(aggregateResults(paths, sr, settings), sr, seriesReturns, sm.BenchmarkSettings{AssetId: seriesReturns[0].AssetId})
+	if expected := float64(underperforming) / float64(len(paths)); math.Abs(summary.ProbabilityOfUnderperforming-expected) > 1e-12 {
+		t.Errorf("Expected probability of underperforming %.4f, got %.4f", expected, summary.ProbabilityOfUnderperforming)
+	}
+	if summary.TrackingError.P50 <= 0 || summary.RelativeDrawdown.P50 <= 0 {
+		t.Errorf("Expected active risk against a single asset, got %+v", summary)
+	}
+	if len(summary.AssetIds) != 1 || summary.Weights[0] != 1 {
+		t.Errorf("Expected the benchmark to hold only the first asset, got %v and %v", summary.AssetIds, summary.Weights)
+	}
+
+	// a benchmark with the portfolio's own weights and policy has no active risk at all
+	sr.Benchmark = &BenchmarkResources{Weights: sr.AssetWeight, Rebalance: sr.Rebalance}
+	paths, err = sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	summary = calculateBenchmarkSummary(aggregateResults(paths, sr, settings), sr, seriesReturns, sm.BenchmarkSettings{ScenarioId: 2})
+	if summary.TrackingError.P95 > 1e-12 || summary.RelativeDrawdown.P95 > 1e-12 || math.Abs(summary.ExcessReturn.P95) > 1e-12 {
+		t.Errorf("Expected no active risk against itself, got %+v", summary)
+	}
+}
+
+// TestRunMonteCarloSimulation_BenchmarkAssets checks a benchmark holding an asset outside the scenario leaves the portfolio as it was
+func TestRunMonteCarloSimulation_BenchmarkAssets(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*5)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, r := range seriesReturns {
+		for i := range r.Returns {
+			r.Dates = append(r.Dates, start.AddDate(0, 0, i))
+		}
+	}
+
+	// the portfolio holds the first two assets, the benchmark is all in the third
+	portfolioReturns, benchmarkReturns := seriesReturns[:2], seriesReturns[2:]
+	for _, r := range portfolioReturns {
+		r.Weight = 0.5
+	}
+	b := &benchmark{weights: map[int32]float64{benchmarkReturns[0].AssetId: 1}}
+
+	settings := map[string]sm.SimulationRequestSettings{
+		"normal":           {DistType: sm.StandardNormal, SimulationUnitOfTime: sm.Weekly},
+		"multivariate t":   {DistType: sm.MultivariateStudentT, DegreesOfFreedom: 5, SimulationUnitOfTime: sm.Weekly},
+		"historical rows":  {DistType: sm.HistoricalBootstrap, BlockLength: 5, SimulationUnitOfTime: sm.Daily},
+		"antithetic sobol": {DistType: sm.StandardNormal, SimulationUnitOfTime: sm.Weekly, RandomSource: sm.Sobol, Antithetic: true},
+	}
+
+	sc := &ServiceContext{Context: context.Background()}
+	for name, s := range settings {
+		s.SimulationDuration = 52
+		s.Iterations = 200
+		s.Seed = 42
+
+		sr, err := GetStatisticalResources(portfolioReturns, s)
+		if err != nil {
+			t.Fatalf("%s: GetStatisticalResources: %v", name, err)
+		}
+
+		without, err := sc.RunMonteCarloSimulation(sr, s)
+		if err != nil {
+			t.Fatalf("%s: RunMonteCarloSimulation: %v", name, err)
+		}
+
+		withResources := *sr
+		if withResources.Benchmark, err = getBenchmarkResources(portfolioReturns, benchmarkReturns, sr, b, s); err != nil {
+			t.Fatalf("%s: getBenchmarkResources: %v", name, err)
+		}
+		with, err := sc.RunMonteCarloSimulation(&withResources, s)
+		if err != nil {
+			t.Fatalf("%s: RunMonteCarloSimulation: %v", name, err)
+		}
+
+		for sim := range without {
+			if with[sim].FinalValue != without[sim].FinalValue || with[sim].AnnualizedVolatility != without[sim].AnnualizedVolatility {
+				t.Fatalf("%s: path %d moved with the benchmark, %.6f and %.6f", name, sim, with[sim].FinalValue, without[sim].FinalValue)
+			}
+			if with[sim].Active.TrackingError <= 0 {
+				t.Fatalf("%s: path %d has no active risk against another asset", name, sim)
+			}
+		}
+
+		expected := buildSimulationResponse(aggregateResults(without, sr, s)).RiskMetrics
+		if got := buildSimulationResponse(aggregateResults(with, &withResources, s)).RiskMetrics; got != expected {
+			t.Errorf("%s: expected the same risk metrics with and without the benchmark, got %+v and %+v", name, got, expected)
+		}
+
+		summary := calculateBenchmarkSummary(aggregateResults(with, &withResources, s), &withResources, portfolioReturns, sm.BenchmarkSettings{AssetId: benchmarkReturns[0].AssetId})
+		if len(summary.AssetIds) != 1 || summary.AssetIds[0] != benchmarkReturns[0].AssetId || summary.Weights[0] != 1 {
+			t.Errorf("%s: expected the benchmark to hold only the third asset, got %v and %v", name, summary.AssetIds, summary.Weights)
+		}
+	}
+}
+
+func TestGetBenchmarkCholesky(t *testing.T) {
+	portfolioCorr := mat.NewSymDense(2, []float64{1, 0.5, 0.5, 1})
+	portfolioL, err := GetCholeskyDecomposition(portfolioCorr)
+	if err != nil {
+		t.Fatalf("GetCholeskyDecomposition: %v", err)
+	}
+
+	// the extended cholesky has to give back the correlations it was built from
+	cross := mat.NewDense(2, 2, []float64{0.6, 0.3, 0.2, -0.1})
+	own := mat.NewSymDense(2, []float64{1, 0.4, 0.4, 1})
+	loading, ownL, err := getBenchmarkCholesky(portfolioL, cross, own)
+	if err != nil {
+		t.Fatalf("getBenchmarkCholesky: %v", err)
+	}
+
+	var gotCross, gotOwn, residual mat.Dense
+	gotCross.Mul(loading, portfolioL.T())
+	gotOwn.Mul(loading, loading.T())
+	residual.Mul(ownL, ownL.T())
+	gotOwn.Add(&gotOwn, &residual)
+	if !mat.EqualApprox(&gotCross, cross, 1e-12) || !mat.EqualApprox(&gotOwn, own, 1e-12) {
+		t.Errorf("Expected the correlations back, got %v and %v", mat.Formatted(&gotCross), mat.Formatted(&gotOwn))
+	}
+
+	// perfectly correlated with both assets, which are not perfectly correlated with each other, can not fit and is shrunk
+	if _, _, err := getBenchmarkCholesky(portfolioL, mat.NewDense(1, 2, []float64{1, 1}), mat.NewSymDense(1, []float64{1})); err != nil {
+		t.Errorf("Expected the cross correlations to be shrunk to fit, got %v", err)
+	}
+}
+
+func TestGetBenchmarkAssetsScenario(t *testing.T) {
+	scenario := &dm.Scenario{
+		ScenarioConfiguration: dm.ScenarioConfiguration{Id: 1, Name: "portfolio"},
+		Components: []dm.ScenarioConfigurationComponent{
+			{AssetId: 10, Weight: 0.6},
+			{AssetId: 11, Weight: 0.4},
+		},
+	}
+
+	if res := getBenchmarkAssetsScenario(scenario, &benchmark{weights: map[int32]float64{11: 1}}); res != nil {
+		t.Errorf("Expected no benchmark assets when the scenario holds them all, got %+v", res.Components)
+	}
+
+	res := getBenchmarkAssetsScenario(scenario, &benchmark{weights: map[int32]float64{11: 0.4, 13: 0.3, 12: 0.3}})
+	if len(res.Components) != 2 || res.Components[0].AssetId != 12 || res.Components[1].AssetId != 13 {
+		t.Errorf("Expected only the assets outside the scenario in order, got %+v", res.Components)
+	}
+	if len(scenario.Components) != 2 {
+		t.Errorf("Expected the scenario itself to be unchanged, got %+v", scenario.Components)
+	}
+
+	seriesReturns := []*SeriesReturns{
+		{ScenarioConfigurationComponent: dm.ScenarioConfigurationComponent{AssetId: 10}},
+		{ScenarioConfigurationComponent: dm.ScenarioConfigurationComponent{AssetId: 11}},
+	}
+	if _, err := getBenchmarkResources(seriesReturns, nil, nil, &benchmark{weights: map[int32]float64{11: 0.5, 12: 0.5}}, sm.SimulationRequestSettings{}); err == nil {
+		t.Error("Expected an error when a benchmark asset has no returns")
+	}
+
+	invalid := map[string]sm.BenchmarkSettings{
+		"neither":  {},
+		"both":     {AssetId: 10, ScenarioId: 2},
+		"itself":   {ScenarioId: 1},
+		"negative": {AssetId: -1},
+	}
+	for name, settings := range invalid {
+		if err := validateBenchmarkSettings(scenario, settings); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// the benchmark's own assets only have one correlation to load on
+	sc := &ServiceContext{Context: context.Background()}
+	settings := sm.SimulationRequestSettings{Benchmark: &sm.BenchmarkSettings{AssetId: 12}, Regimes: &sm.RegimeSettings{}}
+	if _, err := sc.getBenchmark(scenario, settings); err == nil {
+		t.Error("Expected an error for a benchmark asset outside the scenario with regime switching")
+	}
+	settings.Benchmark.AssetId = 11
+	if _, err := sc.getBenchmark(scenario, settings); err != nil {
+		t.Errorf("Expected a scenario asset to be fine with regime switching, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package core
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+
+	dm "mc.data/models"
+	sm "mc.service/models"
+)
+
+// validateViews checks the views themselves, whether the scenario holds the assets is checked against the scenario
+func validateViews(views []sm.View) error {
+	for _, v := range views {
+		if v.AssetId == 0 {
+			return fmt.Errorf("assetId must be provided for every view")
+		}
+		if v.Confidence <= 0 || v.Confidence > 1 {
+			return fmt.Errorf("confidence of the view on assetId %d must be greater than 0 and at most 1, got %.4f", v.AssetId, v.Confidence)
+		}
+
+		if v.VersusAssetId == nil {
+			if v.ExpectedReturn <= -1 {
+				return fmt.Errorf("expected return of the view on assetId %d must be greater than -100%%, got %.4f", v.AssetId, v.ExpectedReturn)
+			}
+			continue
+		}
+
+		if *v.VersusAssetId == 0 {
+			return fmt.Errorf("versusAssetId of the view on assetId %d must be a valid asset id", v.AssetId)
+		}
+		if *v.VersusAssetId == v.AssetId {
+			return fmt.Errorf("view on assetId %d can not be relative to itself", v.AssetId)
+		}
+	}
+
+	return nil
+}
+
+// validateBlackLittermanSettings checks black-litterman can be used with the rest of the run
+func validateBlackLittermanSettings(settings sm.SimulationRequestSettings) error {
+	if settings.BlackLitterman == nil {
+		return nil
+	}
+
+	bl := settings.BlackLitterman
+	if bl.Tau < 0 || bl.Tau > 1 {
+		return fmt.Errorf("tau must be greater than 0 and at most 1, got %.4f", bl.Tau)
+	}
+	if bl.RiskAversion < 0 {
+		return fmt.Errorf("risk aversion must not be negative, got %.4f", bl.RiskAversion)
+	}
+	if err := validateViews(bl.Views); err != nil {
+		return err
+	}
+
+	// the equilibrium returns replace mu, so anything else that sets it would be silently ignored
+	if settings.DistType == sm.HistoricalBootstrap {
+		return fmt.Errorf("black-litterman is not supported with historical bootstrap, the bootstrap draws historical returns as is")
+	}
+	if settings.Regimes != nil {
+		return fmt.Errorf("black-litterman is not supported with regime switching, every regime has its own fitted parameters")
+	}
+	if settings.Jumps != nil && settings.Jumps.Estimate {
+		return fmt.Errorf("black-litterman is not supported with estimated jumps, the diffusion volatility would not match the posterior covariance")
+	}
+	if settings.Assumptions != nil {
+		for _, a := range settings.Assumptions.Assets {
+			if a.ExpectedReturn != nil {
+				return fmt.Errorf("expected return assumptions are not supported with black-litterman, the returns come from the equilibrium and the views")
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveViews fills in the views of a black-litterman run, inline views first, then the requested set of the scenario
+func (sc *ServiceContext) resolveViews(scenario *dm.Scenario, settings sm.SimulationRequestSettings) (sm.SimulationRequestSettings, error) {
+	if settings.BlackLitterman == nil {
+		return settings, nil
+	}
+
+	// copied so resolving does not change the caller's settings
+	bl := *settings.BlackLitterman
+	settings.BlackLitterman = &bl
+
+	if len(bl.Views) > 0 {
+		bl.ViewSetId = 0 // inline views are not a stored set
+		return settings, nil
+	}
+
+	if bl.ViewSetId == 0 {
+		return settings, nil
+	}
+
+	set, err := sc.PostgresConnection.GetViewSetByID(sc.Context, bl.ViewSetId)
+	if err != nil {
+		return settings, err
+	}
+
+	if set.ScenarioId != scenario.Id {
+		return settings, fmt.Errorf("view set %d belongs to another scenario", set.Id)
+	}
+
+	bl.Views = sm.MapViewSetToViews(set)
+	return settings, nil
+}
+
+// applyBlackLitterman replaces mu with the posterior of the equilibrium returns π = δΣw given the views, and sigma and the
+// correlations with the posterior covariance Σ + τΣ - τΣP'(PτΣP' + Ω)⁻¹PτΣ. Ω is diagonal with the variance of each view
+// under τΣ scaled by (1 - c) / c (idzorek's confidence), so a view at full confidence holds exactly. everything is annualized.
+func applyBlackLitterman(statisticalResources *StatisticalResources, seriesReturns []*SeriesReturns, settings sm.BlackLittermanSettings) (*sm.BlackLittermanSummary, error) {
+	n := len(seriesReturns)
+	tau, riskAversion := settings.GetTau(), settings.GetRiskAversion()
+
+	index := make(map[int32]int, n)
+	for i, r := range seriesReturns {
+		index[r.AssetId] = i
+	}
+
+	// the views as rows of P, a relative view is long the asset and short the versus asset
+	views := make([][]float64, len(settings.Views))
+	for k, v := range settings.Views {
+		i, ok := index[v.AssetId]
+		if !ok {
+			return nil, fmt.Errorf("view on assetId %d is not an asset of the scenario", v.AssetId)
+		}
+
+		views[k] = make([]float64, n)
+		views[k][i] = 1
+		if v.VersusAssetId != nil {
+			j, ok := index[*v.VersusAssetId]
+			if !ok {
+				return nil, fmt.Errorf("view on assetId %d is relative to assetId %d, which is not an asset of the scenario", v.AssetId, *v.VersusAssetId)
+			}
+			views[k][j] = -1
+		}
+	}
+
+	covMatrix := mat.NewSymDense(n, nil)
+	for i := range n {
+		for j := range i + 1 {
+			covMatrix.SetSym(i, j, statisticalResources.CorrMatrix.At(i, j)*statisticalResources.Sigma[i]*statisticalResources.Sigma[j])
+		}
+	}
+
+	var implied mat.VecDense
+	implied.MulVec(covMatrix, mat.NewVecDense(n, statisticalResources.AssetWeight))
+	implied.ScaleVec(riskAversion, &implied)
+
+	posteriorReturns := mat.VecDenseCopyOf(&implied)
+	posteriorCovariance := mat.NewSymDense(n, nil)
+	posteriorCovariance.ScaleSym(1+tau, covMatrix)
+
+	if len(views) > 0 {
+		p := mat.NewDense(len(views), n, nil)
+		q := mat.NewVecDense(len(views), nil)
+		for k, row := range views {
+			p.SetRow(k, row)
+			q.SetVec(k, settings.Views[k].ExpectedReturn)
+		}
+
+		// a = τΣP', m = Pa + Ω
+		var a mat.Dense
+		a.Mul(covMatrix, p.T())
+		a.Scale(tau, &a)
+
+		m := mat.NewSymDense(len(views), nil)
+		var pa mat.Dense
+		pa.Mul(p, &a)
+		for k := range views {
+			for l := range k + 1 {
+				m.SetSym(k, l, (pa.At(k, l)+pa.At(l, k))/2)
+			}
+			confidence := settings.Views[k].Confidence
+			m.SetSym(k, k, pa.At(k, k)/confidence) // the view's own variance plus (1 - c) / c of it
+		}
+
+		var chol mat.Cholesky
+		if ok := chol.Factorize(m); !ok {
+			return nil, fmt.Errorf("views can not all hold at once, full confidence views must not repeat or contradict each other")
+		}
+
+		// μ = π + a m⁻¹(q - Pπ)
+		var surprise, x, shift mat.VecDense
+		surprise.MulVec(p, &implied)
+		surprise.SubVec(q, &surprise)
+		if err := chol.SolveVecTo(&x, &surprise); err != nil {
+			return nil, fmt.Errorf("failed to solve for the posterior returns: %w", err)
+		}
+		shift.MulVec(&a, &x)
+		posteriorReturns.AddVec(posteriorReturns, &shift)
+
+		// Σ + τΣ - a m⁻¹a'
+		var y, reduction mat.Dense
+		if err := chol.SolveTo(&y, a.T()); err != nil {
+			return nil, fmt.Errorf("failed to solve for the posterior covariance: %w", err)
+		}
+		reduction.Mul(&a, &y)
+		for i := range n {
+			for j := range i + 1 {
+				posteriorCovariance.SetSym(i, j, posteriorCovariance.At(i, j)-(reduction.At(i, j)+reduction.At(j, i))/2)
+			}
+		}
+	}
+
+	res := &sm.BlackLittermanSummary{
+		ViewSetId:    settings.ViewSetId,
+		Tau:          tau,
+		RiskAversion: riskAversion,
+		Assets:       make([]sm.BlackLittermanAsset, n),
+		Views:        make([]sm.ViewSummary, len(views)),
+	}
+
+	for i, r := range seriesReturns {
+		res.Assets[i] = sm.BlackLittermanAsset{
+			AssetId:         r.AssetId,
+			Weight:          statisticalResources.AssetWeight[i],
+			ImpliedReturn:   implied.AtVec(i),
+			PosteriorReturn: posteriorReturns.AtVec(i),
+			PriorSigma:      statisticalResources.Sigma[i],
+			PosteriorSigma:  math.Sqrt(posteriorCovariance.At(i, i)),
+		}
+
+		statisticalResources.Mu[i] = res.Assets[i].PosteriorReturn
+		statisticalResources.Sigma[i] = res.Assets[i].PosteriorSigma
+	}
+
+	for k, row := range views {
+		res.Views[k] = sm.ViewSummary{View: settings.Views[k]}
+		for i, v := range row {
+			res.Views[k].ImpliedReturn += v * implied.AtVec(i)
+			res.Views[k].PosteriorReturn += v * posteriorReturns.AtVec(i)
+		}
+	}
+
+	statisticalResources.CorrMatrix = GetCorrelationMatrix(posteriorCovariance)
+	return res, nil
+}
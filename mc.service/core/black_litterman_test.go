@@ -0,0 +1,132 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	sm "mc.service/models"
+)
+
+func TestGetStatisticalResources_BlackLittermanEquilibrium(t *testing.T) {
+	seriesReturns := getAssumptionSeriesReturns(t)
+	settings := sm.SimulationRequestSettings{DistType: sm.StandardNormal}
+	historical, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	// no views runs on the equilibrium returns δΣw, with the covariance widened by τ
+	settings.BlackLitterman = &sm.BlackLittermanSettings{}
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	for i := range seriesReturns {
+		implied := 0.0
+		for j, r := range seriesReturns {
+			implied += sm.DefaultRiskAversion * historical.CovMatrix.At(i, j) * sm.Daily * r.Weight
+		}
+		if math.Abs(sr.Mu[i]-implied) > 1e-12 {
+			t.Errorf("asset %d: expected the equilibrium return %.6f, got %.6f", i, implied, sr.Mu[i])
+		}
+		if expected := historical.Sigma[i] * math.Sqrt(1+sm.DefaultTau); math.Abs(sr.Sigma[i]-expected) > 1e-12 {
+			t.Errorf("asset %d: expected sigma %.6f, got %.6f", i, expected, sr.Sigma[i])
+		}
+		if expected := sr.Sigma[i] * sr.Sigma[i] / sm.Daily; math.Abs(sr.CovMatrix.At(i, i)-expected) > 1e-15 {
+			t.Errorf("asset %d: expected the covariance to follow the posterior sigma, got %.6e", i, sr.CovMatrix.At(i, i))
+		}
+	}
+
+	if sr.BlackLitterman == nil || len(sr.BlackLitterman.Assets) != 3 || sr.BlackLitterman.Tau != sm.DefaultTau {
+		t.Errorf("Expected a summary of every scenario asset, got %+v", sr.BlackLitterman)
+	}
+}
+
+func TestGetStatisticalResources_BlackLittermanViews(t *testing.T) {
+	seriesReturns := getAssumptionSeriesReturns(t)
+	settings := sm.SimulationRequestSettings{
+		DistType:       sm.StandardNormal,
+		BlackLitterman: &sm.BlackLittermanSettings{},
+	}
+	equilibrium, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	// a view held with full confidence is exactly what the posterior expects
+	settings.BlackLitterman.Views = []sm.View{{AssetId: 1, ExpectedReturn: 0.12, Confidence: 1}}
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+	if math.Abs(sr.Mu[0]-0.12) > 1e-10 {
+		t.Errorf("Expected the posterior return to match the view, got %.6f", sr.Mu[0])
+	}
+	if v := sr.BlackLitterman.Views[0]; math.Abs(v.ImpliedReturn-equilibrium.Mu[0]) > 1e-12 || math.Abs(v.PosteriorReturn-0.12) > 1e-10 {
+		t.Errorf("Expected the view summary to go from %.6f to 0.12, got %+v", equilibrium.Mu[0], v)
+	}
+
+	// the view has no uncertainty, so asset 1 is back to its prior variance while the rest pick up the view's information
+	prior := sr.BlackLitterman.Assets[0].PriorSigma
+	if math.Abs(sr.Sigma[0]-prior) > 1e-12 {
+		t.Errorf("Expected sigma %.6f for the asset the view pins down, got %.6f", prior, sr.Sigma[0])
+	}
+	for i, a := range sr.BlackLitterman.Assets {
+		if a.PosteriorSigma < a.PriorSigma-1e-12 {
+			t.Errorf("asset %d: expected the posterior sigma to be at least the prior, got %.6f < %.6f", i, a.PosteriorSigma, a.PriorSigma)
+		}
+	}
+
+	// a relative view with some confidence moves the spread part of the way from the equilibrium to the view
+	versus := int32(2)
+	settings.BlackLitterman.Views = []sm.View{{AssetId: 1, VersusAssetId: &versus, ExpectedReturn: 0.05, Confidence: 0.5}}
+	sr, err = GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	implied := equilibrium.Mu[0] - equilibrium.Mu[1]
+	posterior := sr.Mu[0] - sr.Mu[1]
+	if posterior <= min(implied, 0.05) || posterior >= max(implied, 0.05) {
+		t.Errorf("Expected the spread between %.6f and 0.05, got %.6f", implied, posterior)
+	}
+	if math.Abs(sr.BlackLitterman.Views[0].PosteriorReturn-posterior) > 1e-12 {
+		t.Errorf("Expected the view summary to report the spread %.6f, got %.6f", posterior, sr.BlackLitterman.Views[0].PosteriorReturn)
+	}
+
+	// two full confidence views that disagree can not both hold
+	settings.BlackLitterman.Views = []sm.View{
+		{AssetId: 1, ExpectedReturn: 0.05, Confidence: 1},
+		{AssetId: 1, ExpectedReturn: 0.08, Confidence: 1},
+	}
+	if _, err := GetStatisticalResources(seriesReturns, settings); err == nil {
+		t.Error("Expected an error for contradicting full confidence views")
+	}
+}
+
+func TestValidateBlackLittermanSettings(t *testing.T) {
+	seriesReturns := getAssumptionSeriesReturns(t)
+	mu := 0.05
+	versus := int32(1)
+
+	invalid := map[string]sm.SimulationRequestSettings{
+		"tau above 1":            {BlackLitterman: &sm.BlackLittermanSettings{Tau: 1.5}},
+		"negative risk aversion": {BlackLitterman: &sm.BlackLittermanSettings{RiskAversion: -1}},
+		"no confidence":          {BlackLitterman: &sm.BlackLittermanSettings{Views: []sm.View{{AssetId: 1, ExpectedReturn: 0.05}}}},
+		"relative to itself":     {BlackLitterman: &sm.BlackLittermanSettings{Views: []sm.View{{AssetId: 1, VersusAssetId: &versus, Confidence: 0.5}}}},
+		"asset not held":         {BlackLitterman: &sm.BlackLittermanSettings{Views: []sm.View{{AssetId: 99, ExpectedReturn: 0.05, Confidence: 0.5}}}},
+		"historical bootstrap":   {DistType: sm.HistoricalBootstrap, BlackLitterman: &sm.BlackLittermanSettings{}},
+		"regime switching":       {Regimes: &sm.RegimeSettings{}, BlackLitterman: &sm.BlackLittermanSettings{}},
+		"estimated jumps":        {Jumps: &sm.JumpSettings{Estimate: true}, BlackLitterman: &sm.BlackLittermanSettings{}},
+		"expected return assumptions": {
+			Assumptions:    &sm.CapitalMarketAssumptions{Assets: []sm.AssetAssumption{{AssetId: 1, ExpectedReturn: &mu}}},
+			BlackLitterman: &sm.BlackLittermanSettings{},
+		},
+	}
+	for name, settings := range invalid {
+		if _, err := GetStatisticalResources(seriesReturns, settings); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
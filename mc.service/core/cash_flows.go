@@ -0,0 +1,95 @@
+package core
+
+import (
+	"fmt"
+	"math"
+
+	sm "mc.service/models"
+)
+
+func validateCashFlows(cashFlows []sm.CashFlow, simulationDuration int) error {
+	for i, cf := range cashFlows {
+		switch cf.Type {
+		case sm.FixedCashFlow, sm.InflationIndexedCashFlow:
+		case sm.PercentOfValueCashFlow:
+			if cf.Amount <= -1 {
+				return fmt.Errorf("cash flow %d: can not withdraw 100%% or more of the portfolio, got %.4f", i, cf.Amount)
+			}
+		default:
+			return fmt.Errorf("cash flow %d: unknown cash flow type %d", i, cf.Type)
+		}
+
+		if cf.Frequency < 0 || cf.StartPeriod < 0 || cf.EndPeriod < 0 {
+			return fmt.Errorf("cash flow %d: frequency, start and end period must not be negative", i)
+		}
+
+		if cf.EndPeriod != 0 && cf.EndPeriod < cf.StartPeriod {
+			return fmt.Errorf("cash flow %d: end period %d is before start period %d", i, cf.EndPeriod, cf.StartPeriod)
+		}
+
+		if cf.StartPeriod > simulationDuration {
+			return fmt.Errorf("cash flow %d: start period %d is after the end of the simulation (%d)", i, cf.StartPeriod, simulationDuration)
+		}
+	}
+
+	return nil
+}
+
+// getCashFlow sums every cash flow that lands at the end of the period (0 based), value is the portfolio value before the cash flows
+func getCashFlow(cashFlows []sm.CashFlow, period int, value float64, simulationUnitOfTime int) float64 {
+	total := 0.0
+	p := period + 1 // cash flow periods are 1 based, the end of the first period is period 1
+	for _, cf := range cashFlows {
+		start := max(cf.StartPeriod, 1)
+		if p < start || (cf.EndPeriod != 0 && p > cf.EndPeriod) {
+			continue
+		}
+
+		if frequency := max(cf.Frequency, 1); (p-start)%frequency != 0 {
+			continue
+		}
+
+		switch cf.Type {
+		case sm.FixedCashFlow:
+			total += cf.Amount
+		case sm.PercentOfValueCashFlow:
+			total += cf.Amount * value
+		case sm.InflationIndexedCashFlow:
+			years := float64(p) / float64(simulationUnitOfTime)
+			total += cf.Amount * math.Pow(1+cf.InflationRate, years)
+		}
+	}
+
+	return total
+}
+
+// ApplyCashFlow adds a contribution at the target weights or takes a withdrawal pro rata from the holdings and cash.
+// returns the new portfolio value, a withdrawal larger than the portfolio empties it and returns 0.
+func (p *Portfolio) ApplyCashFlow(amount float64) float64 {
+	value := p.Cash
+	for _, h := range p.Holdings {
+		value += h
+	}
+
+	if value+amount <= 0 {
+		clear(p.Holdings)
+		p.Cash = 0
+		return 0
+	}
+
+	if amount >= 0 {
+		p.Cash += amount
+		for i, w := range p.target {
+			p.Holdings[i] += w * amount
+			p.Cash -= w * amount
+		}
+	} else {
+		scale := (value + amount) / value
+		p.Cash *= scale
+		for i := range p.Holdings {
+			p.Holdings[i] *= scale
+		}
+	}
+
+	return value + amount
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	sm "mc.service/models"
+)
+
+// TestGetCashFlowSchedule checks when each cash flow type lands and how much it is
+func TestGetCashFlowSchedule(t *testing.T) {
+	cashFlows := []sm.CashFlow{
+		{Type: sm.FixedCashFlow, Amount: 10, Frequency: 4, StartPeriod: 2, EndPeriod: 10},
+		{Type: sm.PercentOfValueCashFlow, Amount: -0.01},
+		{Type: sm.InflationIndexedCashFlow, Amount: -5, Frequency: sm.Weekly, StartPeriod: sm.Weekly, InflationRate: 0.03},
+	}
+
+	// fixed lands on periods 2, 6 and 10
+	fixedPeriods := []int{}
+	for period := range 20 {
+		if getCashFlow(cashFlows[:1], period, 100, sm.Weekly) != 0 {
+			fixedPeriods = append(fixedPeriods, period+1)
+		}
+	}
+	if len(fixedPeriods) != 3 || fixedPeriods[0] != 2 || fixedPeriods[1] != 6 || fixedPeriods[2] != 10 {
+		t.Errorf("Expected fixed cash flows in periods [2 6 10], got %v", fixedPeriods)
+	}
+
+	if cf := getCashFlow(cashFlows[1:2], 0, 250, sm.Weekly); math.Abs(cf+2.5) > 1e-12 {
+		t.Errorf("Expected 1%% of 250 withdrawn, got %.4f", cf)
+	}
+
+	// inflation indexed lands at the end of each year, grown by a year of inflation per year
+	if cf := getCashFlow(cashFlows[2:], sm.Weekly-1, 100, sm.Weekly); math.Abs(cf+5*1.03) > 1e-9 {
+		t.Errorf("Expected first year withdrawal of %.4f, got %.4f", -5*1.03, cf)
+	}
+	if cf := getCashFlow(cashFlows[2:], 2*sm.Weekly-1, 100, sm.Weekly); math.Abs(cf+5*1.03*1.03) > 1e-9 {
+		t.Errorf("Expected second year withdrawal of %.4f, got %.4f", -5*1.03*1.03, cf)
+	}
+	if cf := getCashFlow(cashFlows[2:], sm.Weekly, 100, sm.Weekly); cf != 0 {
+		t.Errorf("Expected no withdrawal between years, got %.4f", cf)
+	}
+
+	portfolio := NewPortfolio([]float64{0.5, 0.5}, RebalancePolicy{Policy: sm.BuyAndHold})
+	portfolio.Reset(100)
+	portfolio.Holdings[0] = 80 // drifted, withdrawals come out pro rata
+	if value := portfolio.ApplyCashFlow(-65); value != 65 || math.Abs(portfolio.Holdings[0]-40) > 1e-12 {
+		t.Errorf("Expected pro rata withdrawal to leave 65 with 40 in the first asset, got %.4f and %v", value, portfolio.Holdings)
+	}
+	if value := portfolio.ApplyCashFlow(-100); value != 0 || portfolio.Holdings[0] != 0 {
+		t.Errorf("Expected an over sized withdrawal to empty the portfolio, got %.4f and %v", value, portfolio.Holdings)
+	}
+
+	if err := validateCashFlows([]sm.CashFlow{{Type: 7}}, 52); err == nil {
+		t.Error("Expected an error for an unknown cash flow type")
+	}
+	if err := validateCashFlows([]sm.CashFlow{{Type: sm.FixedCashFlow, StartPeriod: 10, EndPeriod: 5}}, 52); err == nil {
+		t.Error("Expected an error when the end period is before the start period")
+	}
+}
+
+// TestRunMonteCarloSimulation_Ruin withdraws enough that some paths run out and checks the ruin metrics line up with the paths
+func TestRunMonteCarloSimulation_Ruin(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*100)[:2]
+	for _, r := range seriesReturns {
+		r.Weight = 0.5
+	}
+
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Yearly,
+		SimulationDuration:   30,
+		Iterations:           2_000,
+		Seed:                 42,
+		CashFlows:            []sm.CashFlow{{Type: sm.InflationIndexedCashFlow, Amount: -5, InflationRate: 0.03}},
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	sc := &ServiceContext{Context: context.Background()}
+	res, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	depleted := 0
+	for i, r := range res {
+		if r.DepletionPeriod == 0 {
+			continue
+		}
+
+		depleted++
+		for _, v := range r.PathValues[r.DepletionPeriod:] {
+			if v != 0 {
+				t.Fatalf("result[%d]: expected path to stay at zero after depletion in period %d, got %v", i, r.DepletionPeriod, r.PathValues)
+			}
+		}
+		// the metrics are time weighted, it is the withdrawals that ran the path out, not the returns
+		if math.IsNaN(r.AnnualizedVolatility) || math.IsNaN(r.AnnualizedReturn) || math.IsInf(r.AnnualizedReturn, 0) || r.MaxDrawdown >= 1 {
+			t.Fatalf("result[%d]: expected depleted metrics to be well defined, got volatility %.4f, return %.4f", i, r.AnnualizedVolatility, r.AnnualizedReturn)
+		}
+	}
+
+	metrics := buildSimulationResponse(aggregateResults(res, sr, settings)).RiskMetrics
+	t.Logf("Probability of ruin: %.4f, median time to depletion: %.1f, survivor median: %.2f", metrics.ProbabilityOfRuin, metrics.MedianTimeToDepletion, metrics.SurvivorFinalValue.P50)
+
+	if depleted == 0 || depleted == len(res) {
+		t.Fatalf("Expected some but not all paths to run out, got %d of %d", depleted, len(res))
+	}
+	if math.Abs(metrics.ProbabilityOfRuin-float64(depleted)/float64(len(res))) > 1e-12 {
+		t.Errorf("Expected probability of ruin %.4f, got %.4f", float64(depleted)/float64(len(res)), metrics.ProbabilityOfRuin)
+	}
+	if metrics.MedianTimeToDepletion <= 0 || metrics.MedianTimeToDepletion > float64(settings.SimulationDuration) {
+		t.Errorf("Expected median time to depletion inside the simulation, got %.1f", metrics.MedianTimeToDepletion)
+	}
+	if metrics.SurvivorFinalValue.P5 <= 0 {
+		t.Errorf("Expected surviving paths to end above zero, got p5 %.4f", metrics.SurvivorFinalValue.P5)
+	}
+}
+
+// TestRunMonteCarloSimulation_CashFlowsAreNotReturns runs the same paths with and without contributions, a contribution
+// adds to the wealth but not to the time weighted return, volatility or drawdown of the path
+func TestRunMonteCarloSimulation_CashFlowsAreNotReturns(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*5)[:2]
+	for _, r := range seriesReturns {
+		r.Weight = 0.5
+	}
+
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Monthly,
+		SimulationDuration:   24,
+		Iterations:           200,
+		Seed:                 42,
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+	sr.Rebalance = RebalancePolicy{Policy: sm.ContinuousRebalance} // contributions go in at target, so the mix stays the same either way
+
+	sc := &ServiceContext{Context: context.Background()}
+	baseline, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	settings.CashFlows = []sm.CashFlow{{Type: sm.FixedCashFlow, Amount: 10}}
+	contributing, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	for i, r := range contributing {
+		b := baseline[i]
+		if r.FinalValue <= b.FinalValue {
+			t.Fatalf("path %d: expected the contributions to add to the final value, got %.2f and %.2f", i, r.FinalValue, b.FinalValue)
+		}
+		if math.Abs(r.AnnualizedReturn-b.AnnualizedReturn) > 1e-9 || math.Abs(r.AnnualizedVolatility-b.AnnualizedVolatility) > 1e-9 || math.Abs(r.MaxDrawdown-b.MaxDrawdown) > 1e-9 {
+			t.Fatalf("path %d: expected the same time weighted metrics, got %+v and %+v", i, r.PathMetrics, b.PathMetrics)
+		}
+	}
+}
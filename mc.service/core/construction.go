@@ -0,0 +1,304 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+
+	dm "mc.data/models"
+	sm "mc.service/models"
+)
+
+const (
+	maxRiskParitySweeps = 10_000
+	riskParityTolerance = 1e-12 // largest relative change of a weight in a sweep that counts as converged
+)
+
+// ConstructWeights builds risk based weights for a set of assets from the sample covariance of their returns,
+// and creates a scenario with them when the request has one
+func (sc *ServiceContext) ConstructWeights(request sm.ConstructWeightsRequest) (*sm.ConstructWeightsResponse, int, error) {
+	if err := validateConstructWeightsRequest(request); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	// the returns are looked up like a scenario's, the weights do not matter here
+	scenario := &dm.Scenario{Components: make([]dm.ScenarioConfigurationComponent, len(request.AssetIds))}
+	for i, id := range request.AssetIds {
+		scenario.Components[i] = dm.ScenarioConfigurationComponent{AssetId: id, Weight: 1 / float64(len(request.AssetIds))}
+	}
+
+	maxLookback := time.Time{}
+	if request.MaxLookback > 0 {
+		maxLookback = time.Now().Add(-request.MaxLookback)
+	}
+
+	seriesReturns, err := sc.getSeriesReturns(scenario, maxLookback)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if len(seriesReturns) != len(request.AssetIds) {
+		return nil, http.StatusBadRequest, fmt.Errorf("only %d of the %d assets have returns", len(seriesReturns), len(request.AssetIds))
+	}
+
+	returns := make([][]float64, len(seriesReturns))
+	res := &sm.ConstructWeightsResponse{
+		Method:   request.Method,
+		AssetIds: make([]int32, len(seriesReturns)),
+	}
+	for i, r := range seriesReturns {
+		returns[i] = r.Returns
+		res.AssetIds[i] = r.AssetId
+	}
+
+	covMatrix := GetCovarianceMatrix(returns)
+	for i := range seriesReturns {
+		if covMatrix.At(i, i) <= 0 {
+			return nil, http.StatusBadRequest, fmt.Errorf("asset id %d has no variance", seriesReturns[i].AssetId)
+		}
+	}
+
+	switch request.Method {
+	case sm.EqualRiskContribution:
+		res.Weights = getEqualRiskContributionWeights(covMatrix)
+	case sm.HierarchicalRiskParity:
+		var order []int
+		res.Weights, order = getHierarchicalRiskParityWeights(covMatrix, sm.GetLinkage(request.Linkage))
+		res.ClusterOrder = make([]int32, len(order))
+		for k, i := range order {
+			res.ClusterOrder[k] = res.AssetIds[i]
+		}
+	}
+
+	var variance float64
+	res.RiskContributions, variance = getRiskContributions(covMatrix, res.Weights)
+	res.Volatility = math.Sqrt(variance * float64(seriesReturns[0].AnnualizationFactor))
+	log.Printf("Constructed %s weights for assets %v: %v", request.Method, res.AssetIds, res.Weights)
+
+	if request.Scenario == nil {
+		return res, http.StatusOK, nil
+	}
+
+	scenarioRequest := *request.Scenario
+	scenarioRequest.Components = getScenarioComponents(res.AssetIds, res.Weights)
+	created, status, err := sc.InsertNewScenario(scenarioRequest)
+	if err != nil {
+		return nil, status, err
+	}
+
+	scenarioResponse := sm.MapScenarioToResponse(created)
+	res.Scenario = &scenarioResponse
+	return res, status, nil
+}
+
+func validateConstructWeightsRequest(request sm.ConstructWeightsRequest) error {
+	if len(request.AssetIds) < 2 {
+		return fmt.Errorf("at least 2 asset ids are required")
+	}
+
+	seen := make(map[int32]bool, len(request.AssetIds))
+	for _, id := range request.AssetIds {
+		if id == 0 {
+			return fmt.Errorf("assetId must be provided")
+		}
+		if seen[id] {
+			return fmt.Errorf("duplicate assetId %d", id)
+		}
+		seen[id] = true
+	}
+
+	switch request.Method {
+	case sm.EqualRiskContribution:
+	case sm.HierarchicalRiskParity:
+		if request.Linkage != "" && request.Linkage != sm.SingleLinkage && request.Linkage != sm.CompleteLinkage && request.Linkage != sm.AverageLinkage {
+			return fmt.Errorf("linkage must be %s, %s or %s, got %q", sm.SingleLinkage, sm.CompleteLinkage, sm.AverageLinkage, request.Linkage)
+		}
+	default:
+		return fmt.Errorf("method must be %s or %s, got %q", sm.EqualRiskContribution, sm.HierarchicalRiskParity, request.Method)
+	}
+
+	if request.MaxLookback < 0 {
+		return fmt.Errorf("max lookback must not be negative")
+	}
+
+	return nil
+}
+
+// getRiskContributions is each asset's share of the portfolio variance, w_i (Σw)_i / w'Σw, and the variance itself
+func getRiskContributions(covMatrix *mat.SymDense, weights []float64) ([]float64, float64) {
+	w := mat.NewVecDense(len(weights), weights)
+	var marginal mat.VecDense
+	marginal.MulVec(covMatrix, w)
+	variance := mat.Dot(w, &marginal)
+
+	res := make([]float64, len(weights))
+	for i := range weights {
+		res[i] = weights[i] * marginal.AtVec(i) / variance
+	}
+
+	return res, variance
+}
+
+// getEqualRiskContributionWeights minimizes ½ y'Σy - Σ log(y_i)/n by cyclical coordinate descent (griveau-billion, richard
+// and roncalli), every coordinate has a closed form. at the minimum y_i (Σy)_i = 1/n for every asset, so once y is scaled
+// to sum to 1 every asset contributes the same share of the variance.
+func getEqualRiskContributionWeights(covMatrix *mat.SymDense) []float64 {
+	n := covMatrix.SymmetricDim()
+	budget := 1 / float64(n)
+
+	// inverse volatility is the answer when nothing is correlated, a good place to start
+	y := make([]float64, n)
+	for i := range n {
+		y[i] = 1 / math.Sqrt(covMatrix.At(i, i))
+	}
+
+	for range maxRiskParitySweeps {
+		change := 0.0
+		for i := range n {
+			// the rest of the row of Σy, the coordinate solves Σ_ii y² + rest y - budget = 0
+			rest := 0.0
+			for j := range n {
+				if j != i {
+					rest += covMatrix.At(i, j) * y[j]
+				}
+			}
+
+			next := (-rest + math.Sqrt(rest*rest+4*covMatrix.At(i, i)*budget)) / (2 * covMatrix.At(i, i))
+			change = math.Max(change, math.Abs(next-y[i])/y[i])
+			y[i] = next
+		}
+
+		if change < riskParityTolerance {
+			break
+		}
+	}
+
+	sum := 0.0
+	for _, v := range y {
+		sum += v
+	}
+	for i := range y {
+		y[i] /= sum
+	}
+
+	return y
+}
+
+// getHierarchicalRiskParityWeights clusters the assets on correlation distance, orders them so the clusters sit together
+// and splits the weight down the order by recursive bisection, each half gets weight in inverse proportion to its variance
+func getHierarchicalRiskParityWeights(covMatrix *mat.SymDense, linkage string) ([]float64, []int) {
+	order := getClusterOrder(getCorrelationDistances(GetCorrelationMatrix(covMatrix)), linkage)
+
+	weights := make([]float64, len(order))
+	for _, i := range order {
+		weights[i] = 1
+	}
+
+	var bisect func(items []int)
+	bisect = func(items []int) {
+		if len(items) < 2 {
+			return
+		}
+
+		left, right := items[:len(items)/2], items[len(items)/2:]
+		leftVariance, rightVariance := getClusterVariance(covMatrix, left), getClusterVariance(covMatrix, right)
+		alpha := 1 - leftVariance/(leftVariance+rightVariance)
+		for _, i := range left {
+			weights[i] *= alpha
+		}
+		for _, i := range right {
+			weights[i] *= 1 - alpha
+		}
+
+		bisect(left)
+		bisect(right)
+	}
+	bisect(order)
+
+	return weights, order
+}
+
+// getCorrelationDistances is sqrt((1 - ρ) / 2), 0 for perfectly correlated assets and 1 for perfectly opposed ones
+func getCorrelationDistances(corrMatrix *mat.SymDense) *mat.SymDense {
+	n := corrMatrix.SymmetricDim()
+	res := mat.NewSymDense(n, nil)
+	for i := range n {
+		for j := range i {
+			res.SetSym(i, j, math.Sqrt(math.Max((1-corrMatrix.At(i, j))/2, 0)))
+		}
+	}
+	return res
+}
+
+// getClusterOrder merges the two closest clusters until one is left and returns the leaves of the tree left to right,
+// which puts the assets of every cluster next to each other (the quasi diagonalization)
+func getClusterOrder(distances *mat.SymDense, linkage string) []int {
+	n := distances.SymmetricDim()
+	clusters := make([][]int, n)
+	for i := range n {
+		clusters[i] = []int{i}
+	}
+
+	for len(clusters) > 1 {
+		a, b, closest := 0, 1, math.Inf(1)
+		for i := range clusters {
+			for j := i + 1; j < len(clusters); j++ {
+				if d := getLinkageDistance(distances, clusters[i], clusters[j], linkage); d < closest {
+					a, b, closest = i, j, d
+				}
+			}
+		}
+
+		clusters[a] = append(clusters[a], clusters[b]...)
+		clusters = slices.Delete(clusters, b, b+1)
+	}
+
+	return clusters[0]
+}
+
+func getLinkageDistance(distances *mat.SymDense, a, b []int, linkage string) float64 {
+	res := 0.0
+	if linkage == sm.SingleLinkage {
+		res = math.Inf(1)
+	}
+
+	for _, i := range a {
+		for _, j := range b {
+			d := distances.At(i, j)
+			switch linkage {
+			case sm.SingleLinkage:
+				res = math.Min(res, d)
+			case sm.CompleteLinkage:
+				res = math.Max(res, d)
+			case sm.AverageLinkage:
+				res += d / float64(len(a)*len(b))
+			}
+		}
+	}
+
+	return res
+}
+
+// getClusterVariance is the variance of the cluster held at inverse variance weights
+func getClusterVariance(covMatrix *mat.SymDense, items []int) float64 {
+	weights := make([]float64, len(items))
+	sum := 0.0
+	for k, i := range items {
+		weights[k] = 1 / covMatrix.At(i, i)
+		sum += weights[k]
+	}
+
+	res := 0.0
+	for k, i := range items {
+		for l, j := range items {
+			res += weights[k] / sum * weights[l] / sum * covMatrix.At(i, j)
+		}
+	}
+
+	return res
+}
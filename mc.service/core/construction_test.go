@@ -0,0 +1,115 @@
+package core
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+
+	sm "mc.service/models"
+)
+
+// getMockBlockCovariance is two blocks of assets correlated inside the block and not across, interleaved so 0 and 2 are
+// one block and 1 and 3 the other
+func getMockBlockCovariance() *mat.SymDense {
+	vols := []float64{0.01, 0.02, 0.03, 0.015}
+	block := []int{0, 1, 0, 1}
+	res := mat.NewSymDense(4, nil)
+	for i := range 4 {
+		for j := range i + 1 {
+			corr := 0.0
+			if i == j {
+				corr = 1
+			} else if block[i] == block[j] {
+				corr = 0.8
+			}
+			res.SetSym(i, j, corr*vols[i]*vols[j])
+		}
+	}
+	return res
+}
+
+func TestGetEqualRiskContributionWeights(t *testing.T) {
+	covMatrix := getMockBlockCovariance()
+	weights := getEqualRiskContributionWeights(covMatrix)
+
+	contributions, _ := getRiskContributions(covMatrix, weights)
+	sum := 0.0
+	for i, c := range contributions {
+		if math.Abs(c-0.25) > 1e-9 {
+			t.Errorf("asset %d: expected a quarter of the risk, got %.9f", i, c)
+		}
+		sum += weights[i]
+	}
+	if math.Abs(sum-1) > 1e-12 {
+		t.Errorf("Expected weights to sum to 1, got %.12f", sum)
+	}
+
+	// uncorrelated assets get inverse volatility weights
+	weights = getEqualRiskContributionWeights(mat.NewSymDense(2, []float64{0.01, 0, 0, 0.04}))
+	if math.Abs(weights[0]-2.0/3) > 1e-9 {
+		t.Errorf("Expected inverse volatility weights, got %v", weights)
+	}
+}
+
+func TestGetHierarchicalRiskParityWeights(t *testing.T) {
+	covMatrix := getMockBlockCovariance()
+
+	for _, linkage := range []string{sm.SingleLinkage, sm.CompleteLinkage, sm.AverageLinkage} {
+		weights, order := getHierarchicalRiskParityWeights(covMatrix, linkage)
+
+		// the blocks sit next to each other in the order
+		first := []int{order[0], order[1]}
+		slices.Sort(first)
+		if !slices.Equal(first, []int{0, 2}) && !slices.Equal(first, []int{1, 3}) {
+			t.Errorf("%s: expected the correlated assets next to each other, got order %v", linkage, order)
+		}
+
+		sum := 0.0
+		for i, w := range weights {
+			if w <= 0 {
+				t.Errorf("%s: asset %d: expected a positive weight, got %.6f", linkage, i, w)
+			}
+			sum += w
+		}
+		if math.Abs(sum-1) > 1e-12 {
+			t.Errorf("%s: expected weights to sum to 1, got %.12f", linkage, sum)
+		}
+	}
+
+	// without correlation the bisection gives inverse variance weights
+	variances := []float64{0.0001, 0.0004, 0.0009, 0.0016, 0.0025}
+	diagonal := mat.NewSymDense(5, nil)
+	total := 0.0
+	for i, v := range variances {
+		diagonal.SetSym(i, i, v)
+		total += 1 / v
+	}
+
+	weights, _ := getHierarchicalRiskParityWeights(diagonal, sm.SingleLinkage)
+	for i, v := range variances {
+		if math.Abs(weights[i]-1/v/total) > 1e-12 {
+			t.Errorf("asset %d: expected inverse variance weight %.6f, got %.6f", i, 1/v/total, weights[i])
+		}
+	}
+}
+
+func TestValidateConstructWeightsRequest(t *testing.T) {
+	if err := validateConstructWeightsRequest(sm.ConstructWeightsRequest{AssetIds: []int32{1, 2}, Method: sm.HierarchicalRiskParity}); err != nil {
+		t.Errorf("Expected a valid request: %v", err)
+	}
+
+	invalid := map[string]sm.ConstructWeightsRequest{
+		"one asset":        {AssetIds: []int32{1}, Method: sm.EqualRiskContribution},
+		"duplicate asset":  {AssetIds: []int32{1, 1}, Method: sm.EqualRiskContribution},
+		"missing asset id": {AssetIds: []int32{1, 0}, Method: sm.EqualRiskContribution},
+		"unknown method":   {AssetIds: []int32{1, 2}, Method: "meanVariance"},
+		"unknown linkage":  {AssetIds: []int32{1, 2}, Method: sm.HierarchicalRiskParity, Linkage: "ward"},
+	}
+	for name, req := range invalid {
+		if err := validateConstructWeightsRequest(req); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
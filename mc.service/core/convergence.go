@@ -0,0 +1,177 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"gonum.org/v1/gonum/stat"
+
+	sm "mc.service/models"
+)
+
+const (
+	confidenceZ       = 1.959963984540054 // two sided 95%
+	convergenceMargin = 1.1               // ask for a bit more than the square root rule says, so the next round usually finishes the run
+)
+
+// riskMetricFields lists every risk metric by its json name, so the confidence intervals do not need a case per field
+var riskMetricFields = []struct {
+	name  string
+	value func(sm.SimulationRiskMetrics) float64
+}{
+	{"var95", func(m sm.SimulationRiskMetrics) float64 { return m.VaR95 }},
+	{"var99", func(m sm.SimulationRiskMetrics) float64 { return m.VaR99 }},
+	{"cvar95", func(m sm.SimulationRiskMetrics) float64 { return m.CVaR95 }},
+	{"cvar99", func(m sm.SimulationRiskMetrics) float64 { return m.CVaR99 }},
+	{"var95Amount", func(m sm.SimulationRiskMetrics) float64 { return m.VaR95Amount }},
+	{"var99Amount", func(m sm.SimulationRiskMetrics) float64 { return m.VaR99Amount }},
+	{"cvar95Amount", func(m sm.SimulationRiskMetrics) float64 { return m.CVaR95Amount }},
+	{"cvar99Amount", func(m sm.SimulationRiskMetrics) float64 { return m.CVaR99Amount }},
+	{"probabilityOfLoss", func(m sm.SimulationRiskMetrics) float64 { return m.ProbabilityOfLoss }},
+	{"maxDrawdownP95", func(m sm.SimulationRiskMetrics) float64 { return m.MaxDrawdownP95 }},
+	{"meanFinalValue", func(m sm.SimulationRiskMetrics) float64 { return m.MeanFinalValue }},
+	{"medianFinalValue", func(m sm.SimulationRiskMetrics) float64 { return m.MedianFinalValue }},
+	{"probabilityOfRuin", func(m sm.SimulationRiskMetrics) float64 { return m.ProbabilityOfRuin }},
+	{"medianTimeToDepletion", func(m sm.SimulationRiskMetrics) float64 { return m.MedianTimeToDepletion }},
+	{"survivorFinalValue.mean", func(m sm.SimulationRiskMetrics) float64 { return m.SurvivorFinalValue.Mean }},
+	{"survivorFinalValue.p5", func(m sm.SimulationRiskMetrics) float64 { return m.SurvivorFinalValue.P5 }},
+	{"survivorFinalValue.p25", func(m sm.SimulationRiskMetrics) float64 { return m.SurvivorFinalValue.P25 }},
+	{"survivorFinalValue.p50", func(m sm.SimulationRiskMetrics) float64 { return m.SurvivorFinalValue.P50 }},
+	{"survivorFinalValue.p75", func(m sm.SimulationRiskMetrics) float64 { return m.SurvivorFinalValue.P75 }},
+	{"survivorFinalValue.p95", func(m sm.SimulationRiskMetrics) float64 { return m.SurvivorFinalValue.P95 }},
+}
+
+func validateConvergence(settings sm.SimulationRequestSettings) error {
+	convergence := settings.Convergence
+	if convergence == nil {
+		return nil
+	}
+
+	if convergence.TargetVaR95StandardError <= 0 {
+		return fmt.Errorf("target var95 standard error must be positive, got %.6f", convergence.TargetVaR95StandardError)
+	}
+
+	if settings.Iterations < 1 {
+		return fmt.Errorf("iterations must be positive when converging, it is the size of the first round")
+	}
+
+	if convergence.MaxIterations < settings.Iterations {
+		return fmt.Errorf("max iterations %d must be at least iterations %d", convergence.MaxIterations, settings.Iterations)
+	}
+
+	// later rounds have to start on an even path to keep antithetic pairs together
+	if settings.Antithetic && settings.Iterations%2 == 1 {
+		return fmt.Errorf("iterations must be even with antithetic paths when converging, got %d", settings.Iterations)
+	}
+
+	return nil
+}
+
+// RunAdaptiveMonteCarloSimulation streams Iterations paths into an aggregate, then with convergence settings keeps dispatching rounds of batches
+// until the var95 standard error is at the target or max iterations is reached. without convergence settings the summary is nil.
+func (sc *ServiceContext) RunAdaptiveMonteCarloSimulation(statisticalResources *StatisticalResources, simulationSettings sm.SimulationRequestSettings) (*SimulationAggregate, *sm.ConvergenceSummary, error) {
+	if err := validateConvergence(simulationSettings); err != nil {
+		return nil, nil, err
+	}
+
+	if err := validateSimulation(statisticalResources, simulationSettings); err != nil {
+		return nil, nil, err
+	}
+
+	logSimulation(simulationSettings)
+
+	aggregate := newSimulationAggregate(statisticalResources, simulationSettings)
+	convergence := simulationSettings.Convergence
+	if convergence == nil {
+		if err := sc.simulatePaths(statisticalResources, simulationSettings, 0, simulationSettings.Iterations, nil, aggregate); err != nil {
+			return nil, nil, err
+		}
+		return aggregate, nil, nil
+	}
+
+	log.Printf("\t Converging to a var95 standard error of %.4f%% within %v paths", convergence.TargetVaR95StandardError*100, convergence.MaxIterations)
+
+	summary := &sm.ConvergenceSummary{
+		TargetVaR95StandardError: convergence.TargetVaR95StandardError,
+		MaxIterations:            convergence.MaxIterations,
+	}
+
+	for next := simulationSettings.Iterations; next > 0; {
+		if err := sc.simulatePaths(statisticalResources, simulationSettings, aggregate.paths, next, nil, aggregate); err != nil {
+			return nil, nil, err
+		}
+		summary.Rounds++
+
+		standardErrors, _ := calculateStandardErrors(aggregate, statisticalResources, simulationSettings)
+		summary.VaR95StandardError = standardErrors.VaR95
+		summary.Converged = standardErrors.VaR95 <= convergence.TargetVaR95StandardError
+		if summary.Converged {
+			break
+		}
+
+		next = getNextRoundSize(aggregate.paths, standardErrors.VaR95, convergence.TargetVaR95StandardError, convergence.MaxIterations, simulationSettings.Antithetic)
+		log.Printf("\t Round %v: var95 standard error %.4f%% after %v paths, running %v more", summary.Rounds, standardErrors.VaR95*100, aggregate.paths, next)
+	}
+
+	log.Printf("\t Converged: %v, var95 standard error %.4f%% after %v paths", summary.Converged, summary.VaR95StandardError*100, aggregate.paths)
+	return aggregate, summary, nil
+}
+
+// getNextRoundSize guesses the paths still needed from the standard error shrinking with 1/sqrt(n),
+// rounded up to whole batches so the workers stay busy and capped at the budget.
+func getNextRoundSize(pathsRun int, standardError, target float64, maxIterations int, antithetic bool) int {
+	ratio := standardError / target
+	needed := math.Ceil(float64(pathsRun) * (ratio*ratio*convergenceMargin - 1))
+
+	next := max(int(math.Ceil(needed/BatchSize))*BatchSize, BatchSize)
+	next = min(next, maxIterations-pathsRun)
+	if antithetic {
+		next -= next % 2
+	}
+
+	return next
+}
+
+// calculateRiskMetricStandardErrors calculates the risk metrics for each batch of paths (whole antithetic pairs),
+// the spread of the batch metrics is the standard error of the full run's metric.
+// this treats means, quantiles and tails the same way without a formula for each.
+func calculateRiskMetricStandardErrors(aggregate *SimulationAggregate) map[string]float64 {
+	batchMetrics := make([]sm.SimulationRiskMetrics, len(aggregate.batches))
+	for b, batch := range aggregate.batches {
+		if batch.paths < 2 {
+			return nil
+		}
+		batchMetrics[b] = calculateRiskMetrics(batch, aggregate.initialValue)
+	}
+
+	res := make(map[string]float64, len(riskMetricFields))
+	batchValues := make([]float64, len(batchMetrics))
+	for _, field := range riskMetricFields {
+		for b, m := range batchMetrics {
+			batchValues[b] = field.value(m)
+		}
+		res[field.name] = stat.StdDev(batchValues, nil) / math.Sqrt(float64(len(batchValues)))
+	}
+
+	return res
+}
+
+// getConfidenceIntervals centers a normal 95% interval on each of the full run's risk metrics
+func getConfidenceIntervals(metrics sm.SimulationRiskMetrics, standardErrors map[string]float64) map[string]sm.ConfidenceInterval {
+	if standardErrors == nil {
+		return nil
+	}
+
+	res := make(map[string]sm.ConfidenceInterval, len(riskMetricFields))
+	for _, field := range riskMetricFields {
+		estimate, standardError := field.value(metrics), standardErrors[field.name]
+		res[field.name] = sm.ConfidenceInterval{
+			StandardError: standardError,
+			Lower:         estimate - confidenceZ*standardError,
+			Upper:         estimate + confidenceZ*standardError,
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	sm "mc.service/models"
+)
+
+// TestGetNextRoundSize checks the square root rule is rounded to whole batches and kept inside the budget
+func TestGetNextRoundSize(t *testing.T) {
+	// twice the target needs about four times the paths
+	if next := getNextRoundSize(BatchSize, 0.004, 0.002, 100*BatchSize, false); next != 4*BatchSize {
+		t.Errorf("Expected %d more paths, got %d", 4*BatchSize, next)
+	}
+
+	// nearly there still runs a whole batch
+	if next := getNextRoundSize(BatchSize, 0.00201, 0.002, 100*BatchSize, false); next != BatchSize {
+		t.Errorf("Expected one batch, got %d", next)
+	}
+
+	if next := getNextRoundSize(BatchSize, 0.004, 0.002, BatchSize+1_001, true); next != 1_000 {
+		t.Errorf("Expected the rest of the budget rounded down to whole pairs, got %d", next)
+	}
+}
+
+// TestRunAdaptiveMonteCarloSimulation runs until var95 is precise enough, then checks a budget that is too small stops the run
+func TestRunAdaptiveMonteCarloSimulation(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*100)
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Monthly,
+		SimulationDuration:   60,
+		Iterations:           2_000,
+		Seed:                 42,
+		Convergence:          &sm.ConvergenceSettings{TargetVaR95StandardError: 0.003, MaxIterations: 100_000},
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	sc := &ServiceContext{Context: context.Background()}
+	aggregate, summary, err := sc.RunAdaptiveMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunAdaptiveMonteCarloSimulation: %v", err)
+	}
+
+	t.Logf("Converged: %v after %d rounds and %d paths, var95 standard error: %.5f", summary.Converged, summary.Rounds, aggregate.Paths(), summary.VaR95StandardError)
+	if !summary.Converged || summary.VaR95StandardError > 0.003 {
+		t.Fatalf("Expected the run to converge, got %+v", summary)
+	}
+	if summary.Rounds < 2 || aggregate.Paths() <= settings.Iterations {
+		t.Errorf("Expected more than the first round to be needed, got %d rounds and %d paths", summary.Rounds, aggregate.Paths())
+	}
+
+	first, second := make([]*SimulationResult, 2), make([]*SimulationResult, 2)
+	if err := sc.simulatePaths(sr, settings, 0, 2, first, nil); err != nil {
+		t.Fatalf("simulatePaths: %v", err)
+	}
+	if err := sc.simulatePaths(sr, settings, 2, 2, second, nil); err != nil {
+		t.Fatalf("simulatePaths: %v", err)
+	}
+	if second[0].FinalValue == first[0].FinalValue {
+		t.Error("Expected the second round to draw new paths rather than replay the first")
+	}
+
+	standardErrors := calculateRiskMetricStandardErrors(aggregate)
+	metrics := buildSimulationResponse(aggregate).RiskMetrics
+	intervals := getConfidenceIntervals(metrics, standardErrors)
+	if len(intervals) != len(riskMetricFields) {
+		t.Errorf("Expected an interval for each of the %d risk metrics, got %d", len(riskMetricFields), len(intervals))
+	}
+
+	var95 := intervals["var95"]
+	t.Logf("VaR95: %.4f [%.4f, %.4f]", metrics.VaR95, var95.Lower, var95.Upper)
+	if var95.Lower >= metrics.VaR95 || var95.Upper <= metrics.VaR95 {
+		t.Errorf("Expected the var95 interval to contain the estimate %.4f, got %+v", metrics.VaR95, var95)
+	}
+	if var95.StandardError > 2*summary.VaR95StandardError || var95.StandardError < summary.VaR95StandardError/2 {
+		t.Errorf("Expected the batch standard error %.5f to be close to the converged standard error %.5f", var95.StandardError, summary.VaR95StandardError)
+	}
+
+	settings.Convergence = &sm.ConvergenceSettings{TargetVaR95StandardError: 0.0001, MaxIterations: 5_000}
+	aggregate, summary, err = sc.RunAdaptiveMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunAdaptiveMonteCarloSimulation: %v", err)
+	}
+	if summary.Converged || aggregate.Paths() != 5_000 {
+		t.Errorf("Expected the budget to run out at 5000 paths without converging, got %d paths and %+v", aggregate.Paths(), summary)
+	}
+
+	settings.Convergence.MaxIterations = 1_000
+	if _, _, err := sc.RunAdaptiveMonteCarloSimulation(sr, settings); err == nil {
+		t.Error("Expected an error when the budget is smaller than the first round")
+	}
+}
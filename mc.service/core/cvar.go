@@ -0,0 +1,370 @@
+package core
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/optimize/convex/lp"
+
+	sm "mc.service/models"
+)
+
+const (
+	DefaultCVaRConfidence = 0.95
+	DefaultCVaRScenarios  = 5_000
+	MaxCVaRScenarios      = 50_000 // every path is kept in memory while the scenarios are solved
+	maxCVaRPlanes         = 300
+	cvarTolerance         = 1e-7 // gap between the best cvar found and the lower bound of the master program, a thousandth of a basis point
+	simplexTolerance      = 1e-10
+)
+
+// RunCVaROptimization finds the weights with the lowest cvar over simulated paths (rockafellar-uryasev), so fat tails, jumps, regimes
+// and shocks the engine simulates are priced in where variance would miss them. the paths come from the engine's worker pool,
+// the weights are then run through a fresh simulation for their risk metrics.
+func (sc *ServiceContext) RunCVaROptimization(scenarioID int32, request sm.CVaROptimizationRequest) (*sm.CVaROptimizationResponse, int, error) {
+	if err := validateCVaROptimizationRequest(request); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	inputs, status, err := sc.getOptimizationInputs(scenarioID, request.Settings)
+	if err != nil {
+		return nil, status, err
+	}
+
+	lower, upper, err := getWeightBounds(inputs.seriesReturns, request.Bounds)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	// the linear program sees each asset's return over the whole duration, which is a buy and hold portfolio without cash flows,
+	// the scenarios and the evaluations are run that way too so the reported cvar is the one that was minimized
+	statisticalResources := inputs.statisticalResources
+	statisticalResources.Rebalance = RebalancePolicy{Policy: sm.BuyAndHold}
+
+	confidence := request.Confidence
+	if confidence == 0 {
+		confidence = DefaultCVaRConfidence
+	}
+
+	nScenarios := request.Scenarios
+	if nScenarios == 0 {
+		nScenarios = DefaultCVaRScenarios
+	}
+
+	// the scenarios draw from a seed of their own, so the risk metrics come from paths the optimizer never saw
+	settings := inputs.settings
+	settings.CashFlows = nil
+	settings.Seed = getEffectiveSeed(settings.Seed)
+	scenarioSettings := settings
+	scenarioSettings.Seed = getEffectiveSeed(int64(splitMix64(uint64(settings.Seed))))
+	scenarioSettings.Iterations = nScenarios
+	scenarioSettings.Convergence = nil
+
+	log.Printf("Simulating %v scenarios to minimize cvar%.0f for scenario %v", nScenarios, confidence*100, inputs.scenario.Name)
+	paths, err := sc.RunMonteCarloSimulation(statisticalResources, scenarioSettings)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	scenarios := make([][]float64, len(paths))
+	for s, path := range paths {
+		scenarios[s] = path.AssetReturns
+	}
+
+	res := &sm.CVaROptimizationResponse{
+		ScenarioId: inputs.scenario.Id,
+		AssetIds:   inputs.assetIds(),
+		Confidence: confidence,
+		Scenarios:  nScenarios,
+	}
+
+	if request.TargetReturn != nil {
+		years := float64(settings.SimulationDuration) / float64(settings.SimulationUnitOfTime)
+		target := math.Pow(1+*request.TargetReturn, years) - 1
+		res.HorizonTargetReturn = &target
+	}
+
+	weights, err := minimizeCVaR(scenarios, confidence, res.HorizonTargetReturn, lower, upper)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	if res.Optimal, err = sc.evaluateCVaRPortfolio(statisticalResources, settings, scenarios, confidence, weights); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	if res.Current, err = sc.evaluateCVaRPortfolio(statisticalResources, settings, scenarios, confidence, statisticalResources.AssetWeight); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	log.Printf("Minimized cvar%.0f of scenario %v from %.4f to %.4f", confidence*100, inputs.scenario.Name, res.Current.CVaR, res.Optimal.CVaR)
+
+	if request.Save {
+		if status, err := sc.saveOptimizedWeights(inputs, weights); err != nil {
+			return nil, status, err
+		}
+		res.Saved = true
+	}
+
+	return res, http.StatusOK, nil
+}
+
+func validateCVaROptimizationRequest(request sm.CVaROptimizationRequest) error {
+	if err := validateReportingSettings(request.Settings); err != nil {
+		return err
+	}
+
+	if request.Settings.Iterations < 1 {
+		return fmt.Errorf("iterations must be positive, they are the paths the optimal weights are evaluated on")
+	}
+
+	if request.Confidence != 0 && (request.Confidence < 0.5 || request.Confidence >= 1) {
+		return fmt.Errorf("confidence must be at least 0.5 and below 1, got %.4f", request.Confidence)
+	}
+
+	if request.Scenarios < 0 || request.Scenarios > MaxCVaRScenarios {
+		return fmt.Errorf("scenarios must be 0 for the default or between 1 and %d, got %d", MaxCVaRScenarios, request.Scenarios)
+	}
+
+	if request.TargetReturn != nil && *request.TargetReturn <= -1 {
+		return fmt.Errorf("target return must be greater than -100%%, got %.4f", *request.TargetReturn)
+	}
+
+	return nil
+}
+
+// minimizeCVaR solves the rockafellar-uryasev linear program over the scenarios of asset returns. the loss of a scenario is
+// -w'r, a buy and hold portfolio over the duration, and cvar is min over α of α + 1/((1-β)S) Σ max(loss - α, 0).
+// written out the program has a row per scenario, more than a dense simplex can take at thousands of paths, so it is solved
+// by cutting planes (künzi-bay and mayer): cvar is also the max over tail weightings π (0 <= π_s <= 1/((1-β)S), Σ π = 1) of
+// π'loss, every weighting is a plane under it. a small master program finds the lowest weights above the planes so far, the
+// tail weighting of those weights is the next plane. cvar is polyhedral so this ends at the optimum of the full program.
+func minimizeCVaR(scenarios [][]float64, confidence float64, target *float64, lower, upper []float64) ([]float64, error) {
+	meanReturns := make([]float64, len(lower))
+	for _, returns := range scenarios {
+		for i, r := range returns {
+			meanReturns[i] += r / float64(len(scenarios))
+		}
+	}
+
+	// any weights make a valid first plane, they do not have to reach the target
+	_, plane := getTailPlane(scenarios, confidence, getFeasibleWeights(lower, upper))
+	planes := [][]float64{plane}
+
+	var best []float64
+	bestLoss := math.Inf(1)
+	for range maxCVaRPlanes {
+		weights, lowerBound, err := solveCVaRMaster(planes, meanReturns, target, lower, upper)
+		if err != nil {
+			return nil, err
+		}
+
+		loss, plane := getTailPlane(scenarios, confidence, weights)
+		if loss < bestLoss {
+			best, bestLoss = weights, loss
+		}
+
+		if bestLoss-lowerBound <= cvarTolerance {
+			break
+		}
+		planes = append(planes, plane)
+	}
+
+	return best, nil
+}
+
+// getTailPlane is the cvar of the weights as a loss and the plane under cvar that touches it there, the scenario returns
+// averaged over the worst (1-β) of the scenarios. the plane is a return, the loss under it is minus the weights times it.
+func getTailPlane(scenarios [][]float64, confidence float64, weights []float64) (float64, []float64) {
+	losses := make([]float64, len(scenarios))
+	order := make([]int, len(scenarios))
+	for s, returns := range scenarios {
+		order[s] = s
+		for i, r := range returns {
+			losses[s] -= weights[i] * r
+		}
+	}
+	slices.SortFunc(order, func(a, b int) int { return cmp.Compare(losses[b], losses[a]) })
+
+	plane := make([]float64, len(weights))
+	loss, remaining := 0.0, 1.0
+	tailWeight := 1 / ((1 - confidence) * float64(len(scenarios)))
+	for _, s := range order {
+		if remaining <= 1e-12 {
+			break
+		}
+
+		pi := math.Min(tailWeight, remaining)
+		remaining -= pi
+		loss += pi * losses[s]
+		for i, r := range scenarios[s] {
+			plane[i] += pi * r
+		}
+	}
+
+	return loss, plane
+}
+
+// solveCVaRMaster finds the weights with the lowest loss t above every plane so far, t is a lower bound on the optimal cvar.
+// in standard form, every variable non negative:
+//
+//	min   t⁺ - t⁻
+//	s.t.  -plane_j'w - t⁺ + t⁻ + slack_j = 0   for every plane, so t >= -plane_j'w
+//	      Σ w = 1
+//	      w_i + slack = upper_i                 for bounded assets
+//	      w_i - slack = lower_i
+//	      r̄'w - slack = target                  with a target return
+func solveCVaRMaster(planes [][]float64, meanReturns []float64, target *float64, lower, upper []float64) ([]float64, float64, error) {
+	nPlanes, nAssets := len(planes), len(lower)
+
+	var upperRows, lowerRows []int
+	for i := range nAssets {
+		if upper[i] < 1 {
+			upperRows = append(upperRows, i)
+		}
+		if lower[i] > 0 {
+			lowerRows = append(lowerRows, i)
+		}
+	}
+
+	nRows := nPlanes + 1 + len(upperRows) + len(lowerRows)
+	nCols := nAssets + 2 + nPlanes + len(upperRows) + len(lowerRows)
+	if target != nil {
+		nRows++
+		nCols++
+	}
+
+	tPlus, tMinus := nAssets, nAssets+1
+	slack := nAssets + 2 // next free slack column
+
+	c := make([]float64, nCols)
+	c[tPlus], c[tMinus] = 1, -1
+
+	a := mat.NewDense(nRows, nCols, nil)
+	b := make([]float64, nRows)
+	for j, plane := range planes {
+		for i, r := range plane {
+			a.Set(j, i, -r)
+		}
+		a.Set(j, tPlus, -1)
+		a.Set(j, tMinus, 1)
+		a.Set(j, slack, 1)
+		slack++
+	}
+
+	row := nPlanes
+	for i := range nAssets {
+		a.Set(row, i, 1)
+	}
+	b[row] = 1
+	row++
+
+	for _, i := range upperRows {
+		a.Set(row, i, 1)
+		a.Set(row, slack, 1)
+		b[row] = upper[i]
+		row, slack = row+1, slack+1
+	}
+
+	for _, i := range lowerRows {
+		a.Set(row, i, 1)
+		a.Set(row, slack, -1)
+		b[row] = lower[i]
+		row, slack = row+1, slack+1
+	}
+
+	if target != nil {
+		for i, r := range meanReturns {
+			a.Set(row, i, r)
+		}
+		a.Set(row, slack, -1)
+		b[row] = *target
+	}
+
+	lowerBound, x, err := lp.Simplex(c, a, b, simplexTolerance, nil)
+	if err != nil {
+		if errors.Is(err, lp.ErrInfeasible) {
+			return nil, 0, fmt.Errorf("no weights within the bounds reach the target return over these scenarios")
+		}
+		return nil, 0, fmt.Errorf("error solving the cvar linear program: %v", err)
+	}
+
+	// the simplex can leave round off just outside the bounds
+	weights := make([]float64, nAssets)
+	for i := range nAssets {
+		weights[i] = math.Min(math.Max(x[i], lower[i]), upper[i])
+	}
+
+	return weights, lowerBound, nil
+}
+
+// getFeasibleWeights gives every asset its min then fills them up to their max in order until the weights sum to 1
+func getFeasibleWeights(lower, upper []float64) []float64 {
+	res := slices.Clone(lower)
+	remaining := 1.0
+	for _, l := range lower {
+		remaining -= l
+	}
+
+	for i := range res {
+		add := math.Min(remaining, upper[i]-lower[i])
+		res[i] += add
+		remaining -= add
+	}
+
+	return res
+}
+
+// getScenarioCVaR is var and cvar of the buy and hold returns over the scenarios, as returns so negative is a loss.
+// var is the loss at the confidence and cvar the rockafellar-uryasev objective at it, the same number the linear program minimizes.
+func getScenarioCVaR(scenarios [][]float64, confidence float64, weights []float64) (float64, float64, float64) {
+	losses := make([]float64, len(scenarios))
+	mean := 0.0
+	for s, returns := range scenarios {
+		for i, r := range returns {
+			losses[s] -= weights[i] * r
+		}
+		mean -= losses[s]
+	}
+	mean /= float64(len(scenarios))
+	slices.Sort(losses)
+
+	k := int(math.Ceil(confidence*float64(len(losses)))) - 1
+	valueAtRisk := losses[max(k, 0)]
+	tail := 0.0
+	for _, loss := range losses {
+		tail += math.Max(loss-valueAtRisk, 0)
+	}
+	cvar := valueAtRisk + tail/((1-confidence)*float64(len(losses)))
+
+	return mean, -valueAtRisk, -cvar
+}
+
+// evaluateCVaRPortfolio puts the weights on the scenarios and runs them through the simulation with the scenario's rebalance policy
+func (sc *ServiceContext) evaluateCVaRPortfolio(statisticalResources *StatisticalResources, settings sm.SimulationRequestSettings, scenarios [][]float64, confidence float64, weights []float64) (sm.CVaRPortfolio, error) {
+	res := sm.CVaRPortfolio{Weights: slices.Clone(weights)}
+	res.ExpectedReturn, res.VaR, res.CVaR = getScenarioCVaR(scenarios, confidence, weights)
+
+	// buy and hold without cash flows, what the scenarios are
+	weightedResources := *statisticalResources
+	weightedResources.AssetWeight = res.Weights
+	weightedResources.Rebalance = RebalancePolicy{Policy: sm.BuyAndHold}
+	settings.CashFlows = nil
+
+	aggregate, _, err := sc.RunAdaptiveMonteCarloSimulation(&weightedResources, settings)
+	if err != nil {
+		return res, err
+	}
+
+	_, meanFinalValue := calculateStandardErrors(aggregate, &weightedResources, settings)
+	res.RiskMetrics = calculateRiskMetrics(aggregate.risk(), aggregate.initialValue)
+	res.RiskMetrics.MeanFinalValue = meanFinalValue
+
+	return res, nil
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	sm "mc.service/models"
+)
+
+// getMockCVaRScenarios is a riskless asset returning 2% and a risky one returning 8% on average with a fat left tail
+func getMockCVaRScenarios() [][]float64 {
+	res := make([][]float64, 100)
+	for s := range res {
+		risky := 0.12
+		if s%10 == 0 {
+			risky = -0.28
+		}
+		res[s] = []float64{0.02, risky}
+	}
+	return res
+}
+
+func TestMinimizeCVaR(t *testing.T) {
+	scenarios := getMockCVaRScenarios()
+	lower, upper := []float64{0, 0}, []float64{1, 1}
+
+	// without a target everything goes to the riskless asset
+	weights, err := minimizeCVaR(scenarios, 0.95, nil, lower, upper)
+	if err != nil {
+		t.Fatalf("minimizeCVaR: %v", err)
+	}
+	if math.Abs(weights[0]-1) > 1e-6 {
+		t.Errorf("Expected all in the riskless asset, got %v", weights)
+	}
+
+	// cvar only grows with the risky asset, so it takes just enough to reach the target: 0.02 + 0.06 w = 0.05
+	target := 0.05
+	weights, err = minimizeCVaR(scenarios, 0.95, &target, lower, upper)
+	if err != nil {
+		t.Fatalf("minimizeCVaR: %v", err)
+	}
+	if math.Abs(weights[1]-0.5) > 1e-6 {
+		t.Errorf("Expected half in the risky asset, got %v", weights)
+	}
+
+	// the tail is the 10 crashes, at 95% cvar is the crash return
+	mean, valueAtRisk, cvar := getScenarioCVaR(scenarios, 0.95, []float64{0.5, 0.5})
+	if math.Abs(mean-0.05) > 1e-12 || math.Abs(valueAtRisk-(0.5*0.02-0.5*0.28)) > 1e-12 || math.Abs(cvar-valueAtRisk) > 1e-12 {
+		t.Errorf("Expected mean 0.05 and var = cvar = -0.13, got %.6f, %.6f and %.6f", mean, valueAtRisk, cvar)
+	}
+
+	// capping the risky asset below what the target needs has no solution
+	if _, err := minimizeCVaR(scenarios, 0.95, &target, lower, []float64{1, 0.4}); err == nil {
+		t.Error("Expected an error when the bounds can not reach the target")
+	}
+}
+
+// TestMinimizeCVaR_Optimal checks the cutting planes beat random weights on the rockafellar-uryasev objective
+func TestMinimizeCVaR_Optimal(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	scenarios := make([][]float64, 300)
+	for s := range scenarios {
+		market := rng.NormFloat64() * 0.15
+		scenarios[s] = []float64{
+			0.03 + 0.2*market + 0.05*rng.NormFloat64(),
+			0.07 + market + 0.1*rng.NormFloat64(),
+			0.05 + 0.5*market + 0.2*rng.ExpFloat64() - 0.2,
+		}
+	}
+
+	lower, upper := []float64{0.1, 0, 0}, []float64{1, 0.5, 1}
+	weights, err := minimizeCVaR(scenarios, 0.9, nil, lower, upper)
+	if err != nil {
+		t.Fatalf("minimizeCVaR: %v", err)
+	}
+
+	sum := 0.0
+	for i, w := range weights {
+		if w < lower[i] || w > upper[i] {
+			t.Errorf("asset %d: weight %.6f is out of bounds", i, w)
+		}
+		sum += w
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("Expected weights to sum to 1, got %.9f", sum)
+	}
+
+	// the tail plane touches cvar at the weights
+	_, _, optimal := getScenarioCVaR(scenarios, 0.9, weights)
+	if loss, _ := getTailPlane(scenarios, 0.9, weights); math.Abs(loss+optimal) > 1e-12 {
+		t.Errorf("Expected the tail plane loss %.9f to be the cvar loss %.9f", loss, -optimal)
+	}
+
+	for range 500 {
+		w := []float64{0.1 + 0.9*rng.Float64(), 0.5 * rng.Float64(), rng.Float64()}
+		total := w[0] + w[1] + w[2]
+		for i := range w {
+			w[i] /= total
+		}
+		if w[0] < 0.1 || w[1] > 0.5 {
+			continue
+		}
+
+		if _, _, cvar := getScenarioCVaR(scenarios, 0.9, w); cvar > optimal+1e-7 {
+			t.Fatalf("Expected no weights with a higher cvar (smaller loss) than %.6f, %v has %.6f", optimal, w, cvar)
+		}
+	}
+}
+
+// TestRunMonteCarloSimulation_AssetReturns checks a buy and hold path is the weighted sum of its asset returns, the loss the optimizer uses
+func TestRunMonteCarloSimulation_AssetReturns(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*5)
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Weekly,
+		SimulationDuration:   52,
+		Iterations:           200,
+		Seed:                 42,
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+	sr.Rebalance = RebalancePolicy{Policy: sm.BuyAndHold}
+
+	sc := &ServiceContext{Context: context.Background()}
+	paths, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	for sim, path := range paths {
+		expected := 0.0
+		for i, r := range path.AssetReturns {
+			expected += sr.AssetWeight[i] * r
+		}
+		if math.Abs(path.TotalReturn-expected) > 1e-9 {
+			t.Fatalf("path %d: expected a total return of %.9f from the asset returns, got %.9f", sim, expected, path.TotalReturn)
+		}
+	}
+}
+
+// TestEvaluateCVaRPortfolio_BuyAndHold checks the weights are evaluated like the scenarios, whatever the policy and cash flows
+func TestEvaluateCVaRPortfolio_BuyAndHold(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*5)
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Weekly,
+		SimulationDuration:   52,
+		Iterations:           500,
+		Seed:                 42,
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+	sr.Rebalance = RebalancePolicy{Policy: sm.BuyAndHold}
+
+	sc := &ServiceContext{Context: context.Background()}
+	paths, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+	scenarios := make([][]float64, len(paths))
+	for s, path := range paths {
+		scenarios[s] = path.AssetReturns
+	}
+
+	expected, err := sc.evaluateCVaRPortfolio(sr, settings, scenarios, 0.95, sr.AssetWeight)
+	if err != nil {
+		t.Fatalf("evaluateCVaRPortfolio: %v", err)
+	}
+
+	sr.Rebalance = RebalancePolicy{Policy: sm.ContinuousRebalance}
+	settings.CashFlows = []sm.CashFlow{{Type: sm.FixedCashFlow, Amount: -2}}
+	res, err := sc.evaluateCVaRPortfolio(sr, settings, scenarios, 0.95, sr.AssetWeight)
+	if err != nil {
+		t.Fatalf("evaluateCVaRPortfolio: %v", err)
+	}
+
+	if res.RiskMetrics != expected.RiskMetrics {
+		t.Errorf("Expected the same buy and hold risk metrics, got %+v and %+v", res.RiskMetrics, expected.RiskMetrics)
+	}
+}
@@ -105,6 +105,69 @@ func (sc *ServiceContext) UpdateScenario(scenarioId int32, request sm.ScenarioRe
 	return updated, http.StatusOK, nil
 }
 
+func (sc *ServiceContext) InsertNewAssumptionSet(request sm.AssumptionSetRequest) (*dm.AssumptionSet, int, error) {
+	if strings.TrimSpace(request.Name) == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("name is required")
+	}
+
+	if len(request.Assets) == 0 && len(request.Correlations) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("at least one asset assumption or correlation is required")
+	}
+
+	if err := validateCapitalMarketAssumptions(request.CapitalMarketAssumptions); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	mappedSet := sm.MapAssumptionSetRequestToDataModel(request)
+	created, err := sc.PostgresConnection.InsertNewAssumptionSet(sc.Context, mappedSet)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("error creating assumption set: %v", err)
+	}
+
+	return created, http.StatusCreated, nil
+}
+
+// InsertNewViewSet publishes black-litterman views for a scenario, every view must be on assets the scenario holds
+func (sc *ServiceContext) InsertNewViewSet(scenarioID int32, request sm.ViewSetRequest) (*dm.ViewSet, int, error) {
+	if strings.TrimSpace(request.Name) == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("name is required")
+	}
+
+	if len(request.Views) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("at least one view is required")
+	}
+
+	if err := validateViews(request.Views); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	scenario, err := sc.PostgresConnection.GetScenarioByID(sc.Context, scenarioID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("error getting scenario: %v", err)
+	}
+
+	held := make(map[int32]bool, len(scenario.Components))
+	for _, c := range scenario.Components {
+		held[c.AssetId] = true
+	}
+	for _, v := range request.Views {
+		if !held[v.AssetId] {
+			return nil, http.StatusBadRequest, fmt.Errorf("view on assetId %d is not an asset of scenario %s", v.AssetId, scenario.Name)
+		}
+		if v.VersusAssetId != nil && !held[*v.VersusAssetId] {
+			return nil, http.StatusBadRequest, fmt.Errorf("view on assetId %d is relative to assetId %d, which is not an asset of scenario %s", v.AssetId, *v.VersusAssetId, scenario.Name)
+		}
+	}
+
+	mappedSet := sm.MapViewSetRequestToDataModel(scenario.Id, request)
+	created, err := sc.PostgresConnection.InsertNewViewSet(sc.Context, mappedSet)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("error creating view set: %v", err)
+	}
+
+	return created, http.StatusCreated, nil
+}
+
 func validateScenarioRequest(req sm.ScenarioRequest) error {
 	if strings.TrimSpace(req.Name) == "" {
 		return fmt.Errorf("name is required")
@@ -114,13 +177,22 @@ func validateScenarioRequest(req sm.ScenarioRequest) error {
 		return fmt.Errorf("at least one component is required")
 	}
 
+	if err := validateRebalancePolicy(sm.GetRebalancePolicy(req.RebalancePolicy), req.RebalanceFrequency, req.RebalanceThreshold); err != nil {
+		return err
+	}
+
+	if req.AssumptionSetId != nil && *req.AssumptionSetId <= 0 {
+		return fmt.Errorf("assumptionSetId is invalid")
+	}
+
 	seen := make(map[int32]bool, len(req.Components))
 	weightSum := 0.0
 	for _, component := range req.Components {
 		if component.AssetId == 0 {
 			return fmt.Errorf("assetId must be provided")
 		}
-		if component.Weight <= 0 {
+		// a floated scenario keeps assets the optimizer set to zero so the next optimization can use them again
+		if component.Weight < 0 || (component.Weight == 0 && !req.FloatedWeight) {
 			return fmt.Errorf("component weights must be positive")
 		}
 		if seen[component.AssetId] {
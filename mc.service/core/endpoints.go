@@ -10,6 +10,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	ex "mc.data/extensions"
+	dm "mc.data/models"
 	sm "mc.service/models"
 )
 
@@ -61,6 +62,7 @@ func GetHttpServer(sc ServiceContext) *http.Server {
 	r.Route("/api/assets", func(r chi.Router) {
 		r.Get("/", func(w http.ResponseWriter, r *http.Request) { getAssets(w, sc) })
 		r.Post("/sync", func(w http.ResponseWriter, r *http.Request) { syncAsset(w, r, sc) })
+		r.Post("/weights", func(w http.ResponseWriter, r *http.Request) { constructWeights(w, r, sc) })
 	})
 
 	// scenarios, creation, retrieval, updating, and deletion
@@ -70,6 +72,25 @@ func GetHttpServer(sc ServiceContext) *http.Server {
 		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) { getScenario(w, r, sc) })
 		r.Put("/{id}", func(w http.ResponseWriter, r *http.Request) { updateScenario(w, r, sc) })
 		r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) { deleteScenario(w, r, sc) })
+		r.Get("/{id}/stress", func(w http.ResponseWriter, r *http.Request) { stressTestScenario(w, r, sc, false) })
+		r.Post("/{id}/stress", func(w http.ResponseWriter, r *http.Request) { stressTestScenario(w, r, sc, true) })
+		r.Post("/{id}/optimize", func(w http.ResponseWriter, r *http.Request) { optimizeScenario(w, r, sc) })
+		r.Post("/{id}/optimize/cvar", func(w http.ResponseWriter, r *http.Request) { optimizeScenarioCVaR(w, r, sc) })
+		r.Get("/{id}/views", func(w http.ResponseWriter, r *http.Request) { getViewSets(w, r, sc) })
+		r.Post("/{id}/views", func(w http.ResponseWriter, r *http.Request) { createViewSet(w, r, sc) })
+	})
+
+	// black-litterman view sets belong to a scenario, publishing a name again for the scenario adds a version
+	r.Get("/api/views/{id}", func(w http.ResponseWriter, r *http.Request) { getViewSet(w, r, sc) })
+
+	// historical crisis windows that scenarios can be stress tested against
+	r.Get("/api/stress/windows", func(w http.ResponseWriter, r *http.Request) { getCrisisWindows(w) })
+
+	// capital market assumption sets, publishing and retrieval. sets are immutable, publishing a name again adds a version
+	r.Route("/api/assumptions", func(r chi.Router) {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) { getAssumptionSets(w, sc) })
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) { createAssumptionSet(w, r, sc) })
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) { getAssumptionSet(w, r, sc) })
 	})
 
 	// simulation, resource retrieval, and running
@@ -267,6 +288,217 @@ func deleteScenario(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
 	jsonResponse(w, http.StatusOK, true)
 }
 
+// GET /api/scenarios/{id}/stress replays every library window, POST picks the windows in the body
+func stressTestScenario(w http.ResponseWriter, r *http.Request, sc ServiceContext, hasBody bool) {
+	scenarioID, err := scenarioIDFromRequest(r)
+	if err != nil {
+		jsonError(w, http.StatusNotFound, "scenario not found")
+		return
+	}
+
+	var req sm.StressTestRequest
+	if hasBody {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	res, status, err := sc.RunStressTest(scenarioID, req)
+	if err != nil {
+		jsonError(w, status, err.Error())
+		return
+	}
+
+	jsonResponse(w, status, res)
+}
+
+// POST /api/assets/weights, risk parity weights for a set of assets, optionally saved as a new scenario
+func constructWeights(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	var req sm.ConstructWeightsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, status, err := sc.ConstructWeights(req)
+	if err != nil {
+		jsonError(w, status, err.Error())
+		return
+	}
+
+	jsonResponse(w, status, res)
+}
+
+// POST /api/scenarios/{id}/optimize, the efficient frontier of a floated weight scenario
+func optimizeScenario(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	scenarioID, err := scenarioIDFromRequest(r)
+	if err != nil {
+		jsonError(w, http.StatusNotFound, "scenario not found")
+		return
+	}
+
+	var req sm.OptimizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, status, err := sc.RunOptimization(scenarioID, req)
+	if err != nil {
+		jsonError(w, status, err.Error())
+		return
+	}
+
+	jsonResponse(w, status, res)
+}
+
+// POST /api/scenarios/{id}/optimize/cvar, the weights with the lowest cvar over simulated paths
+func optimizeScenarioCVaR(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	scenarioID, err := scenarioIDFromRequest(r)
+	if err != nil {
+		jsonError(w, http.StatusNotFound, "scenario not found")
+		return
+	}
+
+	var req sm.CVaROptimizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, status, err := sc.RunCVaROptimization(scenarioID, req)
+	if err != nil {
+		jsonError(w, status, err.Error())
+		return
+	}
+
+	jsonResponse(w, status, res)
+}
+
+// GET /api/stress/windows
+func getCrisisWindows(w http.ResponseWriter) {
+	jsonResponse(w, http.StatusOK, sm.GetCrisisWindows())
+}
+
+// GET /api/assumptions
+func getAssumptionSets(w http.ResponseWriter, sc ServiceContext) {
+	sets, err := sc.PostgresConnection.GetAssumptionSets(sc.Context)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, fmt.Sprintf("error getting assumption sets: %v", err))
+		return
+	}
+
+	// the list only carries the configuration, the assets and correlations come with the set itself
+	res := make([]sm.AssumptionSetResponse, len(sets))
+	for i, set := range sets {
+		res[i] = sm.MapAssumptionSetToResponse(&dm.AssumptionSet{AssumptionSetConfiguration: *set})
+	}
+
+	jsonResponse(w, http.StatusOK, res)
+}
+
+// POST /api/assumptions
+func createAssumptionSet(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	var req sm.AssumptionSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	created, status, err := sc.InsertNewAssumptionSet(req)
+	if err != nil {
+		jsonError(w, status, err.Error())
+		return
+	}
+
+	res := sm.MapAssumptionSetToResponse(created)
+	jsonResponse(w, status, res)
+}
+
+// GET /api/assumptions/{id}
+func getAssumptionSet(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	setID, err := idFromRequest(r, "assumption set")
+	if err != nil {
+		jsonError(w, http.StatusNotFound, "assumption set not found")
+		return
+	}
+
+	set, err := sc.PostgresConnection.GetAssumptionSetByID(sc.Context, setID)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, fmt.Sprintf("error getting assumption set: %v", err))
+		return
+	}
+
+	res := sm.MapAssumptionSetToResponse(set)
+	jsonResponse(w, http.StatusOK, res)
+}
+
+// GET /api/scenarios/{id}/views
+func getViewSets(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	scenarioID, err := scenarioIDFromRequest(r)
+	if err != nil {
+		jsonError(w, http.StatusNotFound, "scenario not found")
+		return
+	}
+
+	sets, err := sc.PostgresConnection.GetViewSetsByScenarioID(sc.Context, scenarioID)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, fmt.Sprintf("error getting view sets: %v", err))
+		return
+	}
+
+	// the list only carries the configuration, the views come with the set itself
+	res := make([]sm.ViewSetResponse, len(sets))
+	for i, set := range sets {
+		res[i] = sm.MapViewSetToResponse(&dm.ViewSet{ViewSetConfiguration: *set})
+	}
+
+	jsonResponse(w, http.StatusOK, res)
+}
+
+// POST /api/scenarios/{id}/views
+func createViewSet(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	scenarioID, err := scenarioIDFromRequest(r)
+	if err != nil {
+		jsonError(w, http.StatusNotFound, "scenario not found")
+		return
+	}
+
+	var req sm.ViewSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	created, status, err := sc.InsertNewViewSet(scenarioID, req)
+	if err != nil {
+		jsonError(w, status, err.Error())
+		return
+	}
+
+	res := sm.MapViewSetToResponse(created)
+	jsonResponse(w, status, res)
+}
+
+// GET /api/views/{id}
+func getViewSet(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
+	setID, err := idFromRequest(r, "view set")
+	if err != nil {
+		jsonError(w, http.StatusNotFound, "view set not found")
+		return
+	}
+
+	set, err := sc.PostgresConnection.GetViewSetByID(sc.Context, setID)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, fmt.Sprintf("error getting view set: %v", err))
+		return
+	}
+
+	res := sm.MapViewSetToResponse(set)
+	jsonResponse(w, http.StatusOK, res)
+}
+
 // GET /api/simulation/run-history
 func getSimulationRunHistory(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
 	scenarioID, err := scenarioIDFromRequest(r)
@@ -315,14 +547,19 @@ func runSimulation(w http.ResponseWriter, r *http.Request, sc ServiceContext) {
 
 // scenarioIDFromRequest reads and parses the {id} URL param from a Chi route.
 func scenarioIDFromRequest(r *http.Request) (int32, error) {
+	return idFromRequest(r, "scenario")
+}
+
+// idFromRequest reads and parses the {id} URL param from a Chi route, name is only used in the errors.
+func idFromRequest(r *http.Request, name string) (int32, error) {
 	trimmed := strings.Trim(chi.URLParam(r, "id"), "/")
 	if trimmed == "" {
-		return 0, fmt.Errorf("scenario id is required")
+		return 0, fmt.Errorf("%s id is required", name)
 	}
 
 	id, err := strconv.ParseInt(trimmed, 10, 32)
 	if err != nil || id <= 0 {
-		return 0, fmt.Errorf("scenario id is invalid")
+		return 0, fmt.Errorf("%s id is invalid", name)
 	}
 
 	return int32(id), nil
@@ -0,0 +1,292 @@
+package core
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+
+	sm "mc.service/models"
+)
+
+// Estimate is the per period mean and covariance of the returns, before annualizing
+type Estimate struct {
+	Mean       []float64
+	Covariance *mat.SymDense
+	Shrinkage  float64 // weight on the shrinkage target, 0 for the sample and ewma covariance
+}
+
+// validateEstimators checks the estimator codes, the ewma estimators need a half life
+func validateEstimators(settings sm.SimulationRequestSettings) error {
+	switch settings.CovarianceEstimator {
+	case sm.SampleCovariance, sm.LedoitWolfCovariance, sm.ConstantCorrelationCovariance, sm.EwmaCovariance:
+	default:
+		return fmt.Errorf("unknown covariance estimator %d", settings.CovarianceEstimator)
+	}
+
+	switch settings.MeanEstimator {
+	case sm.SampleMean, sm.EwmaMean, sm.JamesSteinMean:
+	default:
+		return fmt.Errorf("unknown mean estimator %d", settings.MeanEstimator)
+	}
+
+	usesEwma := settings.CovarianceEstimator == sm.EwmaCovariance || settings.MeanEstimator == sm.EwmaMean
+	if usesEwma && settings.HalfLife < 1 {
+		return fmt.Errorf("half life must be at least 1 period for the ewma estimators, got %d", settings.HalfLife)
+	}
+
+	return nil
+}
+
+// getEstimate estimates the mean and covariance of the returns (one slice per asset, all the same length) with the requested estimators
+func getEstimate(returns [][]float64, settings sm.SimulationRequestSettings) (*Estimate, error) {
+	if err := validateEstimators(settings); err != nil {
+		return nil, err
+	}
+
+	nAssets, nObservations := len(returns), len(returns[0])
+	if nObservations < 2 {
+		return nil, fmt.Errorf("need at least 2 observations to estimate the covariance, got %d", nObservations)
+	}
+
+	res := &Estimate{Mean: make([]float64, nAssets)}
+	switch settings.MeanEstimator {
+	case sm.SampleMean:
+		for i, r := range returns {
+			res.Mean[i] = stat.Mean(r, nil)
+		}
+	case sm.EwmaMean:
+		weights := getEwmaWeights(nObservations, settings.HalfLife)
+		for i, r := range returns {
+			res.Mean[i] = stat.Mean(r, weights)
+		}
+	case sm.JamesSteinMean:
+		res.Mean = getJamesSteinMean(returns)
+	}
+
+	switch settings.CovarianceEstimator {
+	case sm.SampleCovariance:
+		res.Covariance = GetCovarianceMatrix(returns)
+	case sm.LedoitWolfCovariance:
+		res.Covariance, res.Shrinkage = getLedoitWolfCovariance(returns)
+	case sm.ConstantCorrelationCovariance:
+		res.Covariance, res.Shrinkage = getConstantCorrelationCovariance(returns)
+	case sm.EwmaCovariance:
+		res.Covariance = getEwmaCovariance(returns, settings.HalfLife)
+	}
+
+	return res, nil
+}
+
+// getEwmaWeights gives the newest observation the most weight, a weight halves every half life periods back. weights sum to 1.
+func getEwmaWeights(nObservations, halfLife int) []float64 {
+	decay := math.Pow(0.5, 1/float64(halfLife))
+	weights := make([]float64, nObservations)
+	total := 0.0
+	for t := range weights {
+		weights[t] = math.Pow(decay, float64(nObservations-1-t))
+		total += weights[t]
+	}
+
+	for t := range weights {
+		weights[t] /= total
+	}
+	return weights
+}
+
+// getEwmaCovariance is the exponentially weighted covariance around the weighted mean,
+// divided by 1 - sum(w^2) so it is unbiased like the sample covariance (reliability weights)
+func getEwmaCovariance(returns [][]float64, halfLife int) *mat.SymDense {
+	nAssets, nObservations := len(returns), len(returns[0])
+	weights := getEwmaWeights(nObservations, halfLife)
+
+	means := make([]float64, nAssets)
+	for i, r := range returns {
+		means[i] = stat.Mean(r, weights)
+	}
+
+	sumSquaredWeights := 0.0
+	for _, w := range weights {
+		sumSquaredWeights += w * w
+	}
+
+	res := mat.NewSymDense(nAssets, nil)
+	for i := range nAssets {
+		for j := range i + 1 {
+			cov := 0.0
+			for t, w := range weights {
+				cov += w * (returns[i][t] - means[i]) * (returns[j][t] - means[j])
+			}
+			res.SetSym(i, j, cov/(1-sumSquaredWeights))
+		}
+	}
+
+	return res
+}
+
+// getDemeanedReturns returns the demeaned observations (rows) and the biased sample covariance, which the ledoit wolf intensities are derived for
+func getDemeanedReturns(returns [][]float64) (*mat.Dense, *mat.SymDense) {
+	x := ArrToMatrix(returns)
+	nObservations, nAssets := x.Dims()
+	for j := range nAssets {
+		mean := stat.Mean(returns[j], nil)
+		for t := range nObservations {
+			x.Set(t, j, x.At(t, j)-mean)
+		}
+	}
+
+	sample := mat.NewSymDense(nAssets, nil)
+	sample.SymOuterK(1/float64(nObservations), x.T())
+	return x, sample
+}
+
+// getLedoitWolfCovariance shrinks the sample covariance toward a scaled identity (ledoit wolf 2004),
+// the intensity minimizes the expected frobenius distance to the true covariance
+func getLedoitWolfCovariance(returns [][]float64) (*mat.SymDense, float64) {
+	x, sample := getDemeanedReturns(returns)
+	nObservations, nAssets := x.Dims()
+
+	scale := mat.Trace(sample) / float64(nAssets)
+
+	// distance of the sample to the target, and how much of it is estimation noise
+	distance, noise := 0.0, 0.0
+	for i := range nAssets {
+		for j := range nAssets {
+			target := 0.0
+			if i == j {
+				target = scale
+			}
+			distance += math.Pow(sample.At(i, j)-target, 2)
+
+			for t := range nObservations {
+				noise += math.Pow(x.At(t, i)*x.At(t, j)-sample.At(i, j), 2)
+			}
+		}
+	}
+	noise /= float64(nObservations * nObservations)
+
+	shrinkage := 0.0
+	if distance > 0 {
+		shrinkage = math.Min(noise, distance) / distance
+	}
+
+	res := mat.NewSymDense(nAssets, nil)
+	for i := range nAssets {
+		for j := range i + 1 {
+			v := (1 - shrinkage) * sample.At(i, j)
+			if i == j {
+				v += shrinkage * scale
+			}
+			res.SetSym(i, j, v)
+		}
+	}
+
+	return res, shrinkage
+}
+
+// getConstantCorrelationCovariance shrinks the sample covariance toward a target with the sample variances
+// and the average correlation between every pair (ledoit wolf 2003, honey i shrunk the sample covariance matrix)
+func getConstantCorrelationCovariance(returns [][]float64) (*mat.SymDense, float64) {
+	x, sample := getDemeanedReturns(returns)
+	nObservations, nAssets := x.Dims()
+	if nAssets < 2 {
+		return sample, 0
+	}
+
+	stdDevs := make([]float64, nAssets)
+	for i := range nAssets {
+		stdDevs[i] = math.Sqrt(sample.At(i, i))
+	}
+
+	averageCorrelation := 0.0
+	for i := range nAssets {
+		for j := range i {
+			averageCorrelation += sample.At(i, j) / (stdDevs[i] * stdDevs[j])
+		}
+	}
+	averageCorrelation /= float64(nAssets * (nAssets - 1) / 2)
+
+	target := mat.NewSymDense(nAssets, nil)
+	for i := range nAssets {
+		for j := range i + 1 {
+			if i == j {
+				target.SetSym(i, i, sample.At(i, i))
+			} else {
+				target.SetSym(i, j, averageCorrelation*stdDevs[i]*stdDevs[j])
+			}
+		}
+	}
+
+	// pi is the asymptotic variance of the sample entries, rho its covariance with the target, gamma the misspecification of the target
+	pi, rho, gamma := 0.0, 0.0, 0.0
+	for i := range nAssets {
+		for j := range nAssets {
+			piij, thetaii, thetajj := 0.0, 0.0, 0.0
+			for t := range nObservations {
+				xij := x.At(t, i)*x.At(t, j) - sample.At(i, j)
+				piij += xij * xij
+				thetaii += (x.At(t, i)*x.At(t, i) - sample.At(i, i)) * xij
+				thetajj += (x.At(t, j)*x.At(t, j) - sample.At(j, j)) * xij
+			}
+			piij /= float64(nObservations)
+			pi += piij
+
+			if i == j {
+				rho += piij
+			} else {
+				thetaii /= float64(nObservations)
+				thetajj /= float64(nObservations)
+				rho += averageCorrelation / 2 * (stdDevs[j]/stdDevs[i]*thetaii + stdDevs[i]/stdDevs[j]*thetajj)
+			}
+
+			gamma += math.Pow(target.At(i, j)-sample.At(i, j), 2)
+		}
+	}
+
+	shrinkage := 0.0
+	if gamma > 0 {
+		shrinkage = math.Max(0, math.Min(1, (pi-rho)/gamma/float64(nObservations)))
+	}
+
+	res := mat.NewSymDense(nAssets, nil)
+	for i := range nAssets {
+		for j := range i + 1 {
+			res.SetSym(i, j, shrinkage*target.At(i, j)+(1-shrinkage)*sample.At(i, j))
+		}
+	}
+
+	return res, shrinkage
+}
+
+// getJamesSteinMean pulls every sample mean toward the average of the means, by more when the means are noisy compared to their spread.
+// with fewer than 4 assets there is nothing to gain, so the sample means are returned.
+func getJamesSteinMean(returns [][]float64) []float64 {
+	nAssets, nObservations := len(returns), len(returns[0])
+	means := make([]float64, nAssets)
+	averageVariance := 0.0
+	for i, r := range returns {
+		means[i] = stat.Mean(r, nil)
+		averageVariance += stat.Variance(r, nil) / float64(nAssets)
+	}
+
+	if nAssets < 4 {
+		return means
+	}
+
+	grandMean := stat.Mean(means, nil)
+	spread := 0.0
+	for _, m := range means {
+		spread += (m - grandMean) * (m - grandMean)
+	}
+
+	shrinkage := 1.0
+	if spread > 0 {
+		shrinkage = math.Min(1, float64(nAssets-3)*averageVariance/float64(nObservations)/spread)
+	}
+
+	for i, m := range means {
+		means[i] = grandMean + (1-shrinkage)*(m-grandMean)
+	}
+	return means
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+
+	sm "mc.service/models"
+)
+
+// generateFactorReturns draws returns for many assets that share one market factor with different betas, so the correlations differ by pair
+func generateFactorReturns(nAssets, nObservations int) [][]float64 {
+	rng := rand.New(rand.NewPCG(7, 0))
+	market := make([]float64, nObservations)
+	for t := range market {
+		market[t] = rng.NormFloat64() * 0.01
+	}
+
+	returns := make([][]float64, nAssets)
+	for i := range returns {
+		returns[i] = make([]float64, nObservations)
+		for t := range returns[i] {
+			returns[i][t] = 0.0004*float64(i%5) + (0.2+1.6*float64(i)/float64(nAssets))*market[t] + rng.NormFloat64()*0.01
+		}
+	}
+	return returns
+}
+
+func TestGetStatisticalResources_SampleEstimators(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily)
+	sr, err := GetStatisticalResources(seriesReturns, sm.SimulationRequestSettings{DistType: sm.StandardNormal})
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	// the default estimators are the plain sample moments
+	for i, r := range seriesReturns {
+		expectedSigma := stat.StdDev(r.Returns, nil) * math.Sqrt(sm.Daily)
+		if math.Abs(sr.Sigma[i]-expectedSigma) > 1e-12 {
+			t.Errorf("asset %d: expected sigma %.6f, got %.6f", i, expectedSigma, sr.Sigma[i])
+		}
+		if expectedMu := stat.Mean(r.Returns, nil) * sm.Daily; math.Abs(sr.Mu[i]-expectedMu) > 1e-12 {
+			t.Errorf("asset %d: expected mu %.6f, got %.6f", i, expectedMu, sr.Mu[i])
+		}
+	}
+
+	if sr.CovarianceShrinkage != 0 {
+		t.Errorf("Expected no shrinkage for the sample covariance, got %.4f", sr.CovarianceShrinkage)
+	}
+}
+
+// TestGetEstimate_Shrinkage has more assets than a short lookback can pin down, the sample covariance is singular but the shrunk ones are not
+func TestGetEstimate_Shrinkage(t *testing.T) {
+	nAssets := 30
+	returns := generateFactorReturns(nAssets, 25)
+
+	if _, err := GetCholeskyDecomposition(GetCovarianceMatrix(returns)); err == nil {
+		t.Fatal("Expected the sample covariance of 25 observations of 30 assets to be singular")
+	}
+
+	for _, estimator := range []int{sm.LedoitWolfCovariance, sm.ConstantCorrelationCovariance} {
+		name := sm.CovarianceEstimatorToString(estimator)
+		estimate, err := getEstimate(returns, sm.SimulationRequestSettings{CovarianceEstimator: estimator})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		t.Logf("%s shrinkage: %.4f", name, estimate.Shrinkage)
+		if estimate.Shrinkage <= 0 || estimate.Shrinkage > 1 {
+			t.Errorf("%s: expected shrinkage in (0, 1], got %.4f", name, estimate.Shrinkage)
+		}
+		if _, err := GetCholeskyDecomposition(estimate.Covariance); err != nil {
+			t.Errorf("%s: expected a positive definite covariance: %v", name, err)
+		}
+	}
+
+	// the constant correlation target keeps the variances, so only the correlations move toward their average
+	_, sample := getDemeanedReturns(returns)
+	estimate, _ := getEstimate(returns, sm.SimulationRequestSettings{CovarianceEstimator: sm.ConstantCorrelationCovariance})
+	for i := range nAssets {
+		if math.Abs(estimate.Covariance.At(i, i)-sample.At(i, i)) > 1e-15 {
+			t.Errorf("asset %d: expected variance %.6e, got %.6e", i, sample.At(i, i), estimate.Covariance.At(i, i))
+		}
+	}
+
+	// ledoit wolf keeps the average variance
+	estimate, _ = getEstimate(returns, sm.SimulationRequestSettings{CovarianceEstimator: sm.LedoitWolfCovariance})
+	if math.Abs(mat.Trace(estimate.Covariance)-mat.Trace(sample)) > 1e-12 {
+		t.Errorf("Expected trace %.6e, got %.6e", mat.Trace(sample), mat.Trace(estimate.Covariance))
+	}
+
+	// with plenty of history there is little noise to shrink away
+	long, _ := getEstimate(generateFactorReturns(nAssets, sm.Daily*20), sm.SimulationRequestSettings{CovarianceEstimator: sm.ConstantCorrelationCovariance})
+	shortEstimate, _ := getEstimate(returns, sm.SimulationRequestSettings{CovarianceEstimator: sm.ConstantCorrelationCovariance})
+	if long.Shrinkage >= shortEstimate.Shrinkage {
+		t.Errorf("Expected less shrinkage with more history, got %.4f with 20 years and %.4f with 25 days", long.Shrinkage, shortEstimate.Shrinkage)
+	}
+}
+
+func TestGetEstimate_Ewma(t *testing.T) {
+	weights := getEwmaWeights(100, 10)
+	if math.Abs(weights[99]/weights[89]-2) > 1e-12 {
+		t.Errorf("Expected the weight to halve every half life, got a ratio of %.6f", weights[99]/weights[89])
+	}
+	if sum := stat.Mean(weights, nil) * 100; math.Abs(sum-1) > 1e-12 {
+		t.Errorf("Expected the weights to sum to 1, got %.6f", sum)
+	}
+
+	// the volatility doubles half way through, a short half life only sees the recent regime
+	rng := rand.New(rand.NewPCG(3, 0))
+	returns := [][]float64{make([]float64, 1_000)}
+	for t := range returns[0] {
+		vol := 0.01
+		if t >= 500 {
+			vol = 0.02
+		}
+		returns[0][t] = 0.001 + rng.NormFloat64()*vol
+	}
+
+	settings := sm.SimulationRequestSettings{CovarianceEstimator: sm.EwmaCovariance, MeanEstimator: sm.EwmaMean, HalfLife: 20}
+	estimate, err := getEstimate(returns, settings)
+	if err != nil {
+		t.Fatalf("getEstimate: %v", err)
+	}
+	if vol := math.Sqrt(estimate.Covariance.At(0, 0)); math.Abs(vol-0.02) > 0.004 {
+		t.Errorf("Expected the ewma volatility to be near the recent 0.02, got %.4f", vol)
+	}
+
+	// a half life far longer than the history is close to equal weights
+	settings.HalfLife = 1_000_000
+	estimate, _ = getEstimate(returns, settings)
+	if sample := stat.Variance(returns[0], nil); math.Abs(estimate.Covariance.At(0, 0)-sample)/sample > 1e-3 {
+		t.Errorf("Expected a long half life to match the sample variance %.6e, got %.6e", sample, estimate.Covariance.At(0, 0))
+	}
+	if sample := stat.Mean(returns[0], nil); math.Abs(estimate.Mean[0]-sample) > 1e-6 {
+		t.Errorf("Expected a long half life to match the sample mean %.6f, got %.6f", sample, estimate.Mean[0])
+	}
+
+	settings.HalfLife = 0
+	if _, err := getEstimate(returns, settings); err == nil {
+		t.Error("Expected an error for the ewma estimators without a half life")
+	}
+}
+
+func TestGetEstimate_JamesSteinMean(t *testing.T) {
+	returns := generateFactorReturns(10, sm.Daily)
+	estimate, err := getEstimate(returns, sm.SimulationRequestSettings{MeanEstimator: sm.JamesSteinMean})
+	if err != nil {
+		t.Fatalf("getEstimate: %v", err)
+	}
+
+	means := make([]float64, len(returns))
+	for i, r := range returns {
+		means[i] = stat.Mean(r, nil)
+	}
+	grandMean := stat.Mean(means, nil)
+
+	// every mean moves toward the grand mean, and the grand mean itself does not move
+	for i, m := range means {
+		if math.Abs(estimate.Mean[i]-grandMean) > math.Abs(m-grandMean)+1e-15 {
+			t.Errorf("asset %d: expected %.6f to be pulled toward %.6f, got %.6f", i, m, grandMean, estimate.Mean[i])
+		}
+	}
+	if math.Abs(stat.Mean(estimate.Mean, nil)-grandMean) > 1e-15 {
+		t.Errorf("Expected the grand mean %.6f to stay, got %.6f", grandMean, stat.Mean(estimate.Mean, nil))
+	}
+}
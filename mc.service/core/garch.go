@@ -0,0 +1,112 @@
+package core
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/optimize"
+	"gonum.org/v1/gonum/stat"
+)
+
+const (
+	MinimumGarchObservations = 100   // fewer observations than this and the fit is not trustworthy
+	MaximumGarchPersistence  = 0.999 // alpha + beta at or above this has no meaningful long run variance
+)
+
+// GarchModel is a fitted GARCH(1,1) for a single asset, all values are in per period (not annualized) units.
+// h(t+1) = omega + alpha * e(t)^2 + beta * h(t)
+type GarchModel struct {
+	Omega  float64
+	Alpha  float64
+	Beta   float64
+	Fitted bool // false when the fit failed and the asset falls back to constant volatility
+}
+
+// LongRunVariance is the unconditional per period variance the model reverts to
+func (g GarchModel) LongRunVariance() float64 {
+	return g.Omega / (1 - g.Alpha - g.Beta)
+}
+
+// NextVariance steps the conditional variance forward one period given the unit variance shock that was drawn
+func (g GarchModel) NextVariance(variance, shock float64) float64 {
+	return g.Omega + g.Alpha*variance*shock*shock + g.Beta*variance
+}
+
+// FitGarch fits a GARCH(1,1) with gaussian quasi maximum likelihood on the demeaned returns.
+// returns need to be in chronological order, the variance recursion walks forward in time.
+func FitGarch(returns []float64) (GarchModel, error) {
+	if len(returns) < MinimumGarchObservations {
+		return GarchModel{}, fmt.Errorf("need at least %d observations to fit garch, got %d", MinimumGarchObservations, len(returns))
+	}
+
+	mean, variance := stat.MeanVariance(returns, nil)
+	if variance <= 0 {
+		return GarchModel{}, fmt.Errorf("returns have no variance, can not fit garch")
+	}
+
+	residuals := make([]float64, len(returns))
+	for i, r := range returns {
+		residuals[i] = r - mean
+	}
+
+	// optimize over an unconstrained parameterization so any point the optimizer tries is a valid model:
+	// omega = exp(x0) * variance, alpha + beta = logistic(x1), alpha = logistic(x2) * (alpha + beta)
+	// start at the usual alpha = 0.05, beta = 0.90
+	initial := []float64{math.Log(0.05), logit(0.95), logit(0.05 / 0.95)}
+	problem := optimize.Problem{
+		Func: func(x []float64) float64 {
+			return garchNegativeLogLikelihood(garchFromParameters(x, variance), residuals, variance)
+		},
+	}
+
+	result, err := optimize.Minimize(problem, initial, nil, &optimize.NelderMead{})
+	if err != nil {
+		return GarchModel{}, fmt.Errorf("error fitting garch: %w", err)
+	}
+
+	model := garchFromParameters(result.X, variance)
+	if math.IsNaN(result.F) || math.IsInf(result.F, 0) {
+		return GarchModel{}, fmt.Errorf("garch likelihood did not converge")
+	}
+
+	if model.Alpha+model.Beta >= MaximumGarchPersistence {
+		return GarchModel{}, fmt.Errorf("garch persistence %.4f is too close to 1", model.Alpha+model.Beta)
+	}
+
+	model.Fitted = true
+	return model, nil
+}
+
+// garchNegativeLogLikelihood is the gaussian negative log likelihood (dropping constants), the recursion starts at the sample variance
+func garchNegativeLogLikelihood(model GarchModel, residuals []float64, sampleVariance float64) float64 {
+	variance := sampleVariance
+	nll := 0.0
+	for _, e := range residuals {
+		if variance <= 0 {
+			return math.Inf(1)
+		}
+
+		nll += 0.5 * (math.Log(variance) + e*e/variance)
+		variance = model.Omega + model.Alpha*e*e + model.Beta*variance
+	}
+
+	return nll
+}
+
+func garchFromParameters(x []float64, sampleVariance float64) GarchModel {
+	persistence := logistic(x[1])
+	alpha := logistic(x[2]) * persistence
+	return GarchModel{
+		Omega: math.Exp(x[0]) * sampleVariance,
+		Alpha: alpha,
+		Beta:  persistence - alpha,
+	}
+}
+
+func logistic(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+func logit(p float64) float64 {
+	return math.Log(p / (1 - p))
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+	sm "mc.service/models"
+)
+
+// TestFitGarchRecoversParameters simulates a known garch(1,1) and makes sure the fit lands close to it
+func TestFitGarchRecoversParameters(t *testing.T) {
+	expected := GarchModel{Omega: 2e-6, Alpha: 0.08, Beta: 0.90}
+	returns := generateMockGarchReturns(t, expected, 10_000)
+
+	fitted, err := FitGarch(returns)
+	if err != nil {
+		t.Fatalf("FitGarch: %v", err)
+	}
+
+	t.Logf("Fitted omega: %.2e, alpha: %.4f, beta: %.4f", fitted.Omega, fitted.Alpha, fitted.Beta)
+
+	if !fitted.Fitted {
+		t.Error("Expected model to be marked as fitted")
+	}
+	if math.Abs(fitted.Alpha-expected.Alpha) > 0.03 {
+		t.Errorf("Alpha: expected %.4f, got %.4f", expected.Alpha, fitted.Alpha)
+	}
+	if math.Abs(fitted.Beta-expected.Beta) > 0.04 {
+		t.Errorf("Beta: expected %.4f, got %.4f", expected.Beta, fitted.Beta)
+	}
+
+	expectedLongRun := expected.LongRunVariance()
+	if math.Abs(fitted.LongRunVariance()-expectedLongRun)/expectedLongRun > 0.25 {
+		t.Errorf("Long run variance: expected %.2e, got %.2e", expectedLongRun, fitted.LongRunVariance())
+	}
+
+	if _, err := FitGarch(returns[:MinimumGarchObservations-1]); err == nil {
+		t.Error("Expected an error fitting garch with too few observations")
+	}
+}
+
+// TestStatisticalResourcesWorkerGarchCarriesVariance makes sure the worker carries variance across periods and resets per path
+func TestStatisticalResourcesWorkerGarchCarriesVariance(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, 2_000)
+	seriesReturns[0].Returns = generateMockGarchReturns(t, GarchModel{Omega: 2e-6, Alpha: 0.08, Beta: 0.90}, 2_000)
+
+	settings := sm.SimulationRequestSettings{DistType: sm.StandardNormal, SimulationUnitOfTime: sm.Daily, VolatilityModel: sm.Garch}
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	if !sr.Garch[0].Fitted {
+		t.Fatal("Expected garch fit for the garch asset")
+	}
+
+	worker := NewWorkerResources(sr, 42)
+	startingVariance := worker.conditionalVariance[0]
+
+	nSamples := 20_000
+	squaredReturns := make([]float64, nSamples)
+	for i := range nSamples {
+		r := worker.GetCorrelatedReturns(sm.Daily)[0]
+		squaredReturns[i] = r * r
+	}
+
+	if worker.conditionalVariance[0] == startingVariance {
+		t.Error("Expected conditional variance to move across periods")
+	}
+
+	// volatility clustering shows up as positive autocorrelation in squared returns
+	autocorrelation := stat.Correlation(squaredReturns[1:], squaredReturns[:nSamples-1], nil)
+	if autocorrelation < 0.05 {
+		t.Errorf("Expected clustering in squared returns, got autocorrelation %.4f", autocorrelation)
+	}
+
+	worker.ResetPath(1)
+	if worker.conditionalVariance[0] != startingVariance {
+		t.Errorf("Expected conditional variance to reset to %.2e, got %.2e", startingVariance, worker.conditionalVariance[0])
+	}
+}
+
+// Helper: Generate returns from a known garch(1,1) with gaussian innovations
+func generateMockGarchReturns(t *testing.T, model GarchModel, n int) []float64 {
+	t.Helper()
+
+	normalDist := distuv.Normal{Mu: 0, Sigma: 1, Src: rand.NewPCG(7, 0)}
+	returns := make([]float64, n)
+	variance := model.LongRunVariance()
+	for i := range n {
+		z := normalDist.Rand()
+		returns[i] = math.Sqrt(variance) * z
+		variance = model.NextVariance(variance, z)
+	}
+
+	return returns
+}
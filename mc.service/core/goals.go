@@ -0,0 +1,114 @@
+package core
+
+import (
+	"fmt"
+
+	sm "mc.service/models"
+)
+
+// goalAggregate streams how the paths did against one goal
+type goalAggregate struct {
+	goal         sm.Goal // period resolved, never 0
+	successes    int
+	shortfall    float64  // summed over the paths that missed
+	firstPeriods *tDigest // period the target was first reached or the floor first breached
+}
+
+func validateGoals(goals []sm.Goal, simulationDuration int) error {
+	for i, g := range goals {
+		switch g.Type {
+		case sm.TargetGoal, sm.FloorGoal:
+		default:
+			return fmt.Errorf("goal %d: unknown goal type %d", i, g.Type)
+		}
+
+		if g.Value <= 0 {
+			return fmt.Errorf("goal %d: value must be positive, got %.2f", i, g.Value)
+		}
+
+		if g.Period < 0 || g.Period > simulationDuration {
+			return fmt.Errorf("goal %d: period must be 0 (the end) or up to the end of the simulation (%d), got %d", i, simulationDuration, g.Period)
+		}
+	}
+
+	return nil
+}
+
+func newGoalAggregates(goals []sm.Goal, simulationDuration int) []*goalAggregate {
+	res := make([]*goalAggregate, len(goals))
+	for i, g := range goals {
+		if g.Period == 0 {
+			g.Period = simulationDuration
+		}
+		res[i] = &goalAggregate{goal: g, firstPeriods: newTDigest()}
+	}
+	return res
+}
+
+// Add scores a path against the goal, values are the path values with the starting value at 0
+func (g *goalAggregate) Add(values []float64) {
+	switch g.goal.Type {
+	case sm.TargetGoal:
+		if v := values[g.goal.Period]; v >= g.goal.Value {
+			g.successes++
+		} else {
+			g.shortfall += g.goal.Value - v
+		}
+
+		// only up to the goal's period, reaching the target after its date does not count
+		for t := 1; t <= g.goal.Period; t++ {
+			if values[t] >= g.goal.Value {
+				g.firstPeriods.Add(float64(t))
+				break
+			}
+		}
+	case sm.FloorGoal:
+		lowest, breach := values[0], 0
+		for t := 1; t <= g.goal.Period; t++ {
+			lowest = min(lowest, values[t])
+			if breach == 0 && values[t] < g.goal.Value {
+				breach = t
+			}
+		}
+
+		if breach == 0 {
+			g.successes++
+			return
+		}
+		g.shortfall += g.goal.Value - lowest
+		g.firstPeriods.Add(float64(breach))
+	}
+}
+
+func (g *goalAggregate) Merge(other *goalAggregate) {
+	g.successes += other.successes
+	g.shortfall += other.shortfall
+	g.firstPeriods.Merge(other.firstPeriods)
+}
+
+// calculateGoalSummaries reports every goal of the run, nil when the run had none
+func calculateGoalSummaries(aggregate *SimulationAggregate) []sm.GoalSummary {
+	if len(aggregate.goals) == 0 {
+		return nil
+	}
+
+	n := aggregate.paths
+	res := make([]sm.GoalSummary, len(aggregate.goals))
+	for i, g := range aggregate.goals {
+		res[i] = sm.GoalSummary{
+			Goal:               g.goal,
+			SuccessProbability: float64(g.successes) / float64(n),
+		}
+
+		if misses := n - g.successes; misses > 0 {
+			res[i].ExpectedShortfall = g.shortfall / float64(misses)
+		}
+
+		if g.firstPeriods.Count() > 0 {
+			median := g.firstPeriods.Quantile(0.50)
+			res[i].MedianFirstPeriod = &median
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	sm "mc.service/models"
+)
+
+func TestCalculateGoalSummaries(t *testing.T) {
+	paths := [][]float64{
+		{100, 110, 120, 130},
+		{100, 90, 80, 95},
+		{100, 105, 125, 115},
+		{100, 95, 100, 102},
+	}
+	results := make([]*SimulationResult, len(paths))
+	for i, p := range paths {
+		results[i] = &SimulationResult{PathMetrics: PathMetrics{FinalValue: p[len(p)-1]}, PathValues: p}
+	}
+
+	settings := sm.SimulationRequestSettings{
+		SimulationDuration: 3,
+		Goals: []sm.Goal{
+			{Name: "retire", Type: sm.TargetGoal, Value: 120},
+			{Name: "early", Type: sm.TargetGoal, Value: 120, Period: 2},
+			{Name: "floor", Type: sm.FloorGoal, Value: 92},
+			{Name: "first period floor", Type: sm.FloorGoal, Value: 96, Period: 1},
+			{Name: "moon", Type: sm.TargetGoal, Value: 200},
+		},
+	}
+	sr := &StatisticalResources{AssetWeight: []float64{1}}
+
+	// split across two aggregates to check the merge
+	aggregate := aggregateResults(results[:2], sr, settings)
+	aggregate.Merge(aggregateResults(results[2:], sr, settings))
+	goals := calculateGoalSummaries(aggregate)
+
+	one, two := 1.0, 2.0
+	expected := []struct {
+		success, shortfall float64
+		firstPeriod        *float64
+	}{
+		{0.25, (25.0 + 5 + 18) / 3, &two}, // the first and third path reach 120 in period 2
+		{0.5, 30, &two},                   // resolved at period 2, 40 and 20 short
+		{0.75, 12, &one},                  // the second path breaks the floor in period 1 and bottoms 12 below it
+		{0.5, 3.5, &one},                  // 6 and 1 below in period 1, later periods do not count
+		{0, 200 - (130.0+95+115+102)/4, nil},
+	}
+
+	if len(goals) != len(expected) {
+		t.Fatalf("Expected %d goal summaries, got %d", len(expected), len(goals))
+	}
+	for i, e := range expected {
+		g := goals[i]
+		if math.Abs(g.SuccessProbability-e.success) > 1e-12 || math.Abs(g.ExpectedShortfall-e.shortfall) > 1e-12 {
+			t.Errorf("%s: expected success %.2f and shortfall %.2f, got %.2f and %.2f", g.Name, e.success, e.shortfall, g.SuccessProbability, g.ExpectedShortfall)
+		}
+		if (e.firstPeriod == nil) != (g.MedianFirstPeriod == nil) || (e.firstPeriod != nil && *g.MedianFirstPeriod != *e.firstPeriod) {
+			t.Errorf("%s: expected median first period %v, got %v", g.Name, e.firstPeriod, g.MedianFirstPeriod)
+		}
+	}
+
+	if goals[0].Period != 3 || goals[1].Period != 2 {
+		t.Errorf("Expected the end of the simulation to resolve to period 3, got %d and %d", goals[0].Period, goals[1].Period)
+	}
+
+	// the first path only reaches 130 in period 3, after the goal's date
+	settings.Goals = []sm.Goal{{Name: "late", Type: sm.TargetGoal, Value: 130, Period: 2}}
+	if goals := calculateGoalSummaries(aggregateResults(results, sr, settings)); goals[0].MedianFirstPeriod != nil {
+		t.Errorf("Expected no first period when the target is only reached after the goal's period, got %v", *goals[0].MedianFirstPeriod)
+	}
+
+	settings.Goals = nil
+	if goals := calculateGoalSummaries(aggregateResults(results, sr, settings)); goals != nil {
+		t.Errorf("Expected no goal summaries without goals, got %+v", goals)
+	}
+}
+
+func TestRunMonteCarloSimulation_Goals(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily)
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Weekly,
+		SimulationDuration:   52,
+		Iterations:           500,
+		Seed:                 42,
+		Goals:                []sm.Goal{{Name: "grow", Type: sm.TargetGoal, Value: 105}},
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	sc := &ServiceContext{Context: context.Background()}
+	res, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	reached := 0
+	for _, r := range res {
+		if r.FinalValue >= 105 {
+			reached++
+		}
+	}
+
+	goals := calculateGoalSummaries(aggregateResults(res, sr, settings))
+	if expected := float64(reached) / float64(len(res)); math.Abs(goals[0].SuccessProbability-expected) > 1e-12 {
+		t.Errorf("Expected the share of paths ending at 105 or more (%.4f), got %.4f", expected, goals[0].SuccessProbability)
+	}
+	if goals[0].MedianFirstPeriod == nil || *goals[0].MedianFirstPeriod < 1 || *goals[0].MedianFirstPeriod > 52 {
+		t.Errorf("Expected a median first period inside the simulation, got %v", goals[0].MedianFirstPeriod)
+	}
+}
+
+func TestValidateGoals(t *testing.T) {
+	if err := validateGoals([]sm.Goal{{Type: sm.FloorGoal, Value: 80, Period: 10}}, 10); err != nil {
+		t.Errorf("Expected a valid goal: %v", err)
+	}
+
+	invalid := map[string]sm.Goal{
+		"unknown type":        {Type: 7, Value: 100},
+		"no value":            {Type: sm.TargetGoal},
+		"negative period":     {Type: sm.TargetGoal, Value: 100, Period: -1},
+		"after the last step": {Type: sm.TargetGoal, Value: 100, Period: 11},
+	}
+	for name, goal := range invalid {
+		if err := validateGoals([]sm.Goal{goal}, 10); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package core
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/stat"
+
+	sm "mc.service/models"
+)
+
+const (
+	DefaultJumpThresholdStdDevs = 3.0 // returns further than this from the mean are treated as jumps when estimating
+	jumpEstimationPasses        = 5   // outliers inflate the std dev, so we re-estimate it without them a few times
+)
+
+// JumpModel is a compound poisson jump in log returns, jumps arrive with Intensity per year and each jump is N(Mean, Volatility)
+type JumpModel struct {
+	Intensity  float64
+	Mean       float64
+	Volatility float64
+}
+
+// Compensator is the annualized drift correction so adding jumps does not change the expected growth of the asset (merton)
+func (j JumpModel) Compensator() float64 {
+	return j.Intensity * (math.Exp(j.Mean+0.5*j.Volatility*j.Volatility) - 1)
+}
+
+// JumpResources are the jumps layered on top of the diffusion returns
+type JumpResources struct {
+	Assets         []JumpModel // per asset, in series returns order
+	Market         *JumpModel  // optional, when it fires every asset gets the same jump
+	DiffusionSigma []float64   // per asset, the annualized volatility without the jumps, only set when the jumps are estimated
+}
+
+func validateJumpSettings(settings sm.SimulationRequestSettings) error {
+	if settings.Jumps == nil {
+		return nil
+	}
+
+	if settings.DistType == sm.HistoricalBootstrap {
+		return fmt.Errorf("jumps are not supported with historical bootstrap, the bootstrap already draws historical jumps")
+	}
+
+	// garch and the regimes are fitted on the history with the jumps still in it, estimated jumps would count them twice
+	if settings.Jumps.Estimate && settings.VolatilityModel == sm.Garch {
+		return fmt.Errorf("estimated jumps are not supported with garch, the fitted volatility already has the jumps in it")
+	}
+	if settings.Jumps.Estimate && settings.Regimes != nil {
+		return fmt.Errorf("estimated jumps are not supported with regime switching, the fitted regimes already have the jumps in them")
+	}
+
+	return nil
+}
+
+// getJumpResources builds the jumps from user specified parameters or estimates them from the return history.
+// When jumps are estimated, the returns are drawn with the diffusion only volatility so the jumps are not counted twice.
+// sigma and the covariance keep the total volatility, jumps included, for everything that measures the risk of the assets.
+func getJumpResources(seriesReturns []*SeriesReturns, settings sm.SimulationRequestSettings) (*JumpResources, error) {
+	if err := validateJumpSettings(settings); err != nil {
+		return nil, err
+	}
+
+	jumpSettings := settings.Jumps
+	jr := &JumpResources{Assets: make([]JumpModel, len(seriesReturns))}
+
+	if jumpSettings.MarketJump != nil {
+		p := jumpSettings.MarketJump
+		market := JumpModel{Intensity: p.Intensity, Mean: p.Mean, Volatility: p.Volatility}
+		if err := validateJumpModel(market); err != nil {
+			return nil, fmt.Errorf("market jump: %w", err)
+		}
+		jr.Market = &market
+	}
+
+	if jumpSettings.Estimate {
+		threshold := jumpSettings.ThresholdStdDevs
+		if threshold == 0 {
+			threshold = DefaultJumpThresholdStdDevs
+		}
+
+		jr.DiffusionSigma = make([]float64, len(seriesReturns))
+		for i, r := range seriesReturns {
+			jump, diffusionSigma, err := EstimateJumpModel(r.Returns, r.AnnualizationFactor, threshold)
+			if err != nil {
+				return nil, fmt.Errorf("error estimating jumps for asset %d: %w", r.AssetId, err)
+			}
+			jr.Assets[i] = jump
+			jr.DiffusionSigma[i] = diffusionSigma
+		}
+
+		return jr, nil
+	}
+
+	for _, p := range jumpSettings.Assets {
+		idx := -1
+		for i, r := range seriesReturns {
+			if r.AssetId == p.AssetId {
+				idx = i
+			}
+		}
+
+		if idx < 0 {
+			return nil, fmt.Errorf("jump parameters supplied for asset %d which is not in the scenario", p.AssetId)
+		}
+
+		jr.Assets[idx] = JumpModel{Intensity: p.Intensity, Mean: p.Mean, Volatility: p.Volatility}
+		if err := validateJumpModel(jr.Assets[idx]); err != nil {
+			return nil, fmt.Errorf("asset %d jump: %w", p.AssetId, err)
+		}
+	}
+
+	return jr, nil
+}
+
+// EstimateJumpModel treats returns more than thresholdStdDevs from the mean as jumps.
+// the diffusion mean and std dev are re-estimated without the jumps until the set of jumps settles,
+// intensity is jumps per year and the jump size is measured from the diffusion mean.
+// returns the jump model and the annualized diffusion only volatility.
+func EstimateJumpModel(returns []float64, annualizationFactor int, thresholdStdDevs float64) (JumpModel, float64, error) {
+	if thresholdStdDevs <= 0 {
+		return JumpModel{}, 0, fmt.Errorf("jump threshold must be positive, got %.2f", thresholdStdDevs)
+	}
+
+	mean, stdDev := stat.MeanStdDev(returns, nil)
+	var jumps, diffusion []float64
+	for range jumpEstimationPasses {
+		jumps, diffusion = jumps[:0], diffusion[:0]
+		for _, r := range returns {
+			if math.Abs(r-mean) > thresholdStdDevs*stdDev {
+				jumps = append(jumps, r)
+			} else {
+				diffusion = append(diffusion, r)
+			}
+		}
+
+		if len(diffusion) < 2 {
+			return JumpModel{}, 0, fmt.Errorf("not enough non jump returns to estimate the diffusion")
+		}
+		mean, stdDev = stat.MeanStdDev(diffusion, nil)
+	}
+
+	diffusionSigma := stdDev * math.Sqrt(float64(annualizationFactor))
+	if len(jumps) == 0 {
+		return JumpModel{}, diffusionSigma, nil
+	}
+
+	sizes := make([]float64, len(jumps))
+	for i, j := range jumps {
+		sizes[i] = j - mean
+	}
+
+	years := float64(len(returns)) / float64(annualizationFactor)
+	jump := JumpModel{
+		Intensity: float64(len(jumps)) / years,
+		Mean:      stat.Mean(sizes, nil),
+	}
+
+	if len(sizes) > 1 {
+		jump.Volatility = stat.StdDev(sizes, nil)
+	}
+
+	return jump, diffusionSigma, nil
+}
+
+func validateJumpModel(j JumpModel) error {
+	if j.Intensity < 0 {
+		return fmt.Errorf("jump intensity must not be negative, got %.4f", j.Intensity)
+	}
+	if j.Volatility < 0 {
+		return fmt.Errorf("jump volatility must not be negative, got %.4f", j.Volatility)
+	}
+	return nil
+}
+
+// addJumps layers the compensated jumps on top of a period of returns
+func (wr *WorkerResource) addJumps(returns []float64, simulationUnitOfTime int) {
+	periodsPerYear := float64(simulationUnitOfTime)
+
+	if wr.Jumps.Market != nil {
+		// one market jump size hits every asset in the period
+		wr.marketJump = wr.drawJumps(*wr.Jumps.Market, periodsPerYear) - wr.Jumps.Market.Compensator()/periodsPerYear
+		for i := range returns {
+			returns[i] += wr.marketJump
+		}
+	}
+
+	for i, j := range wr.Jumps.Assets {
+		if j.Intensity > 0 {
+			returns[i] += wr.drawJumps(j, periodsPerYear) - j.Compensator()/periodsPerYear
+		}
+	}
+}
+
+// drawJumps draws the number of jumps in the period (poisson) and sums their sizes
+func (wr *WorkerResource) drawJumps(j JumpModel, periodsPerYear float64) float64 {
+	nJumps := wr.drawPoisson(j.Intensity / periodsPerYear)
+	total := 0.0
+	for range nJumps {
+		total += j.Mean + j.Volatility*wr.normalDist.Rand()
+	}
+	return total
+}
+
+// drawPoisson uses knuth's algorithm, fine for the small per period rates we see with jumps
+func (wr *WorkerResource) drawPoisson(lambda float64) int {
+	limit := math.Exp(-lambda)
+	k := 0
+	p := wr.rng.Float64()
+	for p > limit {
+		k++
+		p *= wr.rng.Float64()
+	}
+	return k
+}
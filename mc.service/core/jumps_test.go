@@ -0,0 +1,161 @@
+package core
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"gonum.org/v1/gonum/stat/distuv"
+	sm "mc.service/models"
+)
+
+// TestEstimateJumpModelRecoversJumps injects known jumps into gaussian returns and makes sure the estimate finds them
+func TestEstimateJumpModelRecoversJumps(t *testing.T) {
+	expected := JumpModel{Intensity: 4, Mean: -0.08, Volatility: 0.02}
+	diffusionSigma := 0.15
+	returns := generateMockJumpReturns(t, expected, diffusionSigma, sm.Daily*20)
+
+	// a wider threshold keeps gaussian tail days from being counted as jumps
+	jump, sigma, err := EstimateJumpModel(returns, sm.Daily, 4)
+	if err != nil {
+		t.Fatalf("EstimateJumpModel: %v", err)
+	}
+
+	t.Logf("Estimated intensity: %.4f, mean: %.4f, volatility: %.4f, diffusion sigma: %.4f", jump.Intensity, jump.Mean, jump.Volatility, sigma)
+
+	if math.Abs(jump.Intensity-expected.Intensity) > 1 {
+		t.Errorf("Intensity: expected ~%.4f, got %.4f", expected.Intensity, jump.Intensity)
+	}
+	if math.Abs(jump.Mean-expected.Mean) > 0.01 {
+		t.Errorf("Mean: expected ~%.4f, got %.4f", expected.Mean, jump.Mean)
+	}
+	if math.Abs(sigma-diffusionSigma) > 0.01 {
+		t.Errorf("Diffusion sigma: expected ~%.4f, got %.4f", diffusionSigma, sigma)
+	}
+
+	if _, _, err := EstimateJumpModel(returns, sm.Daily, 0); err == nil {
+		t.Error("Expected an error with a zero threshold")
+	}
+}
+
+// TestStatisticalResourcesWorkerJumps makes sure jumps show up at the requested rate and the compensator keeps the mean growth
+func TestStatisticalResourcesWorkerJumps(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, 1_000)[:2]
+	market := sm.JumpParameters{Intensity: 2, Mean: -0.10, Volatility: 0.03}
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Weekly,
+		Jumps:                &sm.JumpSettings{MarketJump: &market},
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	worker := NewWorkerResources(sr, 42)
+	nSamples := 200_000
+	jumpPeriods, grossReturn := 0, 0.0
+	for range nSamples {
+		// pulling the jumps on their own keeps the test free of diffusion noise
+		jump := worker.drawJumps(*sr.Jumps.Market, sm.Weekly)
+		if jump != 0 {
+			jumpPeriods++
+		}
+		grossReturn += math.Exp(jump - sr.Jumps.Market.Compensator()/sm.Weekly)
+	}
+
+	expectedRate := 1 - math.Exp(-market.Intensity/sm.Weekly)
+	rate := float64(jumpPeriods) / float64(nSamples)
+	if math.Abs(rate-expectedRate) > 0.003 {
+		t.Errorf("Expected jumps in ~%.4f of periods, got %.4f", expectedRate, rate)
+	}
+
+	if meanGross := grossReturn / float64(nSamples); math.Abs(meanGross-1) > 0.001 {
+		t.Errorf("Expected compensated jumps to have mean gross return ~1, got %.6f", meanGross)
+	}
+
+	settings.DistType = sm.HistoricalBootstrap
+	settings.BlockLength = 5
+	settings.SimulationUnitOfTime = sm.Daily
+	if _, err := GetStatisticalResources(seriesReturns, settings); err == nil {
+		t.Error("Expected an error combining jumps with historical bootstrap")
+	}
+
+	settings.DistType = sm.StandardNormal
+	settings.SimulationUnitOfTime = sm.Weekly
+	estimated := map[string]sm.SimulationRequestSettings{
+		"garch":   {DistType: sm.StandardNormal, VolatilityModel: sm.Garch, Jumps: &sm.JumpSettings{Estimate: true}},
+		"regimes": {DistType: sm.StandardNormal, Regimes: &sm.RegimeSettings{NumberOfRegimes: 2}, Jumps: &sm.JumpSettings{Estimate: true}},
+	}
+	for name, s := range estimated {
+		if _, err := getJumpResources(seriesReturns, s); err == nil {
+			t.Errorf("%s: expected an error combining it with estimated jumps", name)
+		}
+		if err := validateStatisticalSettings(s); err == nil {
+			t.Errorf("%s: expected an error before the run", name)
+		}
+	}
+
+	settings.Jumps = &sm.JumpSettings{Assets: []sm.JumpParameters{{AssetId: -1, Intensity: 1}}}
+	if _, err := GetStatisticalResources(seriesReturns, settings); err == nil {
+		t.Error("Expected an error for jump parameters on an asset outside the scenario")
+	}
+}
+
+// TestGetStatisticalResources_EstimatedJumps checks the diffusion sigma only drives the draws, sigma and the covariance keep the jumps
+func TestGetStatisticalResources_EstimatedJumps(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*20)[:2]
+	seriesReturns[0].Returns = generateMockJumpReturns(t, JumpModel{Intensity: 4, Mean: -0.08, Volatility: 0.02}, 0.15, len(seriesReturns[0].Returns))
+	settings := sm.SimulationRequestSettings{DistType: sm.StandardNormal, SimulationUnitOfTime: sm.Weekly}
+
+	withoutJumps, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	settings.Jumps = &sm.JumpSettings{Estimate: true, ThresholdStdDevs: 4}
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	for i := range seriesReturns {
+		if sr.Sigma[i] != withoutJumps.Sigma[i] {
+			t.Errorf("asset %d: expected sigma to keep the jump variance, got %.6f instead of %.6f", i, sr.Sigma[i], withoutJumps.Sigma[i])
+		}
+		if expected := sr.Sigma[i] * sr.Sigma[i] / sm.Daily; math.Abs(sr.CovMatrix.At(i, i)-expected) > 1e-15 {
+			t.Errorf("asset %d: expected the covariance to match sigma, got %.6e", i, sr.CovMatrix.At(i, i))
+		}
+	}
+	if sr.Jumps.DiffusionSigma[0] >= sr.Sigma[0] {
+		t.Errorf("Expected the diffusion sigma under the total sigma, got %.6f and %.6f", sr.Jumps.DiffusionSigma[0], sr.Sigma[0])
+	}
+
+	worker := NewWorkerResources(sr, 42)
+	expected := CalculateLogNormalReturn(sr.Mu[0], sr.Jumps.DiffusionSigma[0], 1, sm.Weekly)
+	if r := worker.calculateReturn(0, 1, sm.Weekly); r != expected {
+		t.Errorf("Expected the return to be drawn with the diffusion sigma, got %.6f instead of %.6f", r, expected)
+	}
+}
+
+// Helper: Generate daily gaussian returns with compound poisson jumps added on top
+func generateMockJumpReturns(t *testing.T, jump JumpModel, sigma float64, n int) []float64 {
+	t.Helper()
+
+	src := rand.NewPCG(13, 0)
+	rng := rand.New(src)
+	normalDist := distuv.Normal{Mu: 0, Sigma: 1, Src: src}
+
+	dailySigma := sigma / math.Sqrt(sm.Daily)
+	jumpProbability := jump.Intensity / sm.Daily
+	returns := make([]float64, n)
+	for i := range n {
+		returns[i] = dailySigma * normalDist.Rand()
+		if rng.Float64() < jumpProbability {
+			returns[i] += jump.Mean + jump.Volatility*normalDist.Rand()
+		}
+	}
+
+	return returns
+}
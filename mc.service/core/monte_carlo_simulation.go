@@ -5,6 +5,7 @@ import (
 	"log"
 	"maps"
 	"math"
+	"math/rand/v2"
 	"slices"
 	"time"
 
@@ -17,9 +18,8 @@ import (
 )
 
 const (
-	Workers               = 8
-	BatchSize             = 10_000
-	InitialPortfolioValue = 100.0
+	Workers   = 8
+	BatchSize = 10_000
 )
 
 // TODO: this is being abstracted out a bit to the simulation controller
@@ -29,7 +29,7 @@ type SimulationSettings struct {
 
 	Iterations int   `json:"iterations"`
 	Seed       int64 `json:"seed"`     // ^^
-	DistType   int   `json:"disttype"` // standar normal, student t
+	DistType   int   `json:"disttype"` // standar normal, student t, historical bootstrap
 
 	SimulationUnitOfTime int `json:"simulationunitoftime"` // daily, weekly, monthly, quarterly, yearly
 	SimulationDuration   int `json:"simulationduration"`   // number of units of time to simulate
@@ -38,7 +38,14 @@ type SimulationSettings struct {
 
 type SimulationResult struct {
 	PathMetrics
-	PathValues []float64
+	PathValues      []float64
+	RegimePeriods   []int          // periods spent in each regime, nil without regime switching
+	FinalWeights    []float64      // weights at the end of the path, drift away from target unless rebalanced every period
+	Rebalances      int            // number of times the path was rebalanced
+	DepletionPeriod int            // period the path ran out of money after a withdrawal, 0 when it never did
+	ControlValue    float64        // buy and hold terminal value from the same returns, only set for the control variate
+	AssetReturns    []float64      // simple return of each asset over the path (up to when it ran out), shocks included, only set when every path is kept
+	Active          *ActiveMetrics // against the benchmark, nil without one
 }
 
 type PathMetrics struct {
@@ -47,6 +54,7 @@ type PathMetrics struct {
 	AnnualizedReturn     float64
 	AnnualizedVolatility float64
 	MaxDrawdown          float64
+	Performance          []float64 // one per performance metric in the order of performanceMetrics, NaN where the path does not define it
 }
 
 type SeriesReturns struct {
@@ -83,15 +91,71 @@ func GetNumberOfJobsAndWorkers(iterations int, batchSize int, workers int) ([]jo
 
 // RunMonteCarloSimulation runs the monte carlo simulation, abstracted out the
 func (sc *ServiceContext) RunMonteCarloSimulation(statisticalResources *StatisticalResources, simulationSettings sm.SimulationRequestSettings) ([]*SimulationResult, error) {
+	if err := validateSimulation(statisticalResources, simulationSettings); err != nil {
+		return nil, err
+	}
+
+	logSimulation(simulationSettings)
+
 	res := make([]*SimulationResult, simulationSettings.Iterations)
+	if err := sc.simulatePaths(statisticalResources, simulationSettings, 0, simulationSettings.Iterations, res, nil); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// getEffectiveSeed keeps the requested seed, a zero seed means none was given so a random one is drawn that can be returned and replayed
+func getEffectiveSeed(seed int64) int64 {
+	for seed == 0 {
+		seed = rand.Int64()
+	}
+	return seed
+}
+
+func validateSimulation(statisticalResources *StatisticalResources, simulationSettings sm.SimulationRequestSettings) error {
+	if err := validateCashFlows(simulationSettings.CashFlows, simulationSettings.SimulationDuration); err != nil {
+		return err
+	}
+
+	if err := validateGoals(simulationSettings.Goals, simulationSettings.SimulationDuration); err != nil {
+		return err
+	}
 
-	jobs, nWorkers := GetNumberOfJobsAndWorkers(simulationSettings.Iterations, BatchSize, Workers)
+	if err := validateRiskFreeRate(simulationSettings.RiskFreeRate); err != nil {
+		return err
+	}
 
+	if err := validateVarianceReduction(statisticalResources, simulationSettings); err != nil {
+		return err
+	}
+
+	// every period takes one coordinate per asset, so the points have to cover the whole path
+	if sobol := statisticalResources.Sobol; sobol != nil && sobol.Dimension != len(statisticalResources.AssetWeight)*simulationSettings.SimulationDuration {
+		return fmt.Errorf("sobol dimension %d does not match %d assets over %d periods", sobol.Dimension, len(statisticalResources.AssetWeight), simulationSettings.SimulationDuration)
+	}
+
+	return nil
+}
+
+func logSimulation(simulationSettings sm.SimulationRequestSettings) {
 	log.Println("Starting monte carlo simulation:")
 	log.Printf("\t Simulation duration: %v %s", simulationSettings.SimulationDuration, ms.ConvertFrequencyToString(simulationSettings.SimulationUnitOfTime))
 	log.Printf("\t Simulation paths: %v", simulationSettings.Iterations)
+	log.Printf("\t Initial portfolio value: %.2f %s", simulationSettings.GetInitialPortfolioValue(), simulationSettings.GetCurrency())
+	log.Printf("\t Seed: %v", simulationSettings.Seed)
+	log.Printf("\t Random source: %s", ms.RandomSourceToString(simulationSettings.RandomSource))
+	log.Printf("\t Estimators: %s covariance, %s mean", ms.CovarianceEstimatorToString(simulationSettings.CovarianceEstimator), ms.MeanEstimatorToString(simulationSettings.MeanEstimator))
 	log.Printf("\t Simulation batch size: %v", BatchSize)
 	log.Printf("\t Workers: %v", Workers)
+}
+
+// simulatePaths runs n paths starting at offset, the offset is the index of the first path across every round.
+// res keeps every path when it is not nil, aggregate streams them when it is not nil.
+// every path draws from its own stream of the seed, so the paths do not depend on the workers, the batch size or the rounds.
+func (sc *ServiceContext) simulatePaths(statisticalResources *StatisticalResources, simulationSettings sm.SimulationRequestSettings, offset, n int, res []*SimulationResult, aggregate *SimulationAggregate) error {
+	initialPortfolioValue := simulationSettings.GetInitialPortfolioValue()
+	jobs, nWorkers := GetNumberOfJobsAndWorkers(n, BatchSize, Workers)
 
 	// this is the channel that will hold all the jobs to be processed, workers will steal jobs from this channel as they process other jobs
 	jobsChannel := make(chan job, len(jobs))
@@ -105,8 +169,33 @@ func (sc *ServiceContext) RunMonteCarloSimulation(statisticalResources *Statisti
 	// if a worker errors, it wont take down the user's context
 	group, ctx := errgroup.WithContext(sc.Context)
 
-	for i := range nWorkers {
-		workerResource := NewWorkerResources(statisticalResources, uint64(simulationSettings.Seed), uint64(i+1))
+	// every job streams into its own aggregate, they are merged in job order as they finish so the run does not depend on which worker got which job
+	var finishedJobs chan finishedJob
+	merged := make(chan struct{})
+	if aggregate != nil {
+		finishedJobs = make(chan finishedJob, len(jobs))
+		go func() {
+			defer close(merged)
+			mergeFinishedJobs(aggregate, finishedJobs)
+		}()
+	}
+
+	for range nWorkers {
+		workerResource := NewWorkerResources(statisticalResources, uint64(simulationSettings.Seed))
+		portfolio := NewPortfolio(statisticalResources.AssetWeight, statisticalResources.Rebalance)
+		var logReturnSums []float64 // per asset, only tracked for the control variate
+		if simulationSettings.ControlVariate {
+			logReturnSums = make([]float64, len(statisticalResources.AssetWeight))
+		}
+		var assetLogReturns []float64 // per asset, only tracked when every path is kept
+		if res != nil {
+			assetLogReturns = make([]float64, len(statisticalResources.AssetWeight))
+		}
+		var benchmark *Portfolio // steps on the same returns as the portfolio, only with a benchmark
+		var active activeReturns
+		if statisticalResources.Benchmark != nil {
+			benchmark = NewPortfolio(statisticalResources.Benchmark.Weights, statisticalResources.Benchmark.Rebalance)
+		}
 		group.Go(func() error {
 			// this will loop over available jobs, and will reup if a job finishes and there are more jobs
 			for j := range jobsChannel {
@@ -119,29 +208,120 @@ func (sc *ServiceContext) RunMonteCarloSimulation(statisticalResources *Statisti
 				default:
 				}
 
+				var jobAggregate *SimulationAggregate
+				if aggregate != nil {
+					jobAggregate = newSimulationAggregate(statisticalResources, simulationSettings)
+				}
+
 				for sim := j.start; sim <= j.end; sim++ { // this will loop over the iterations
-					portfolioValue := InitialPortfolioValue
+					// random streams and sobol points are indexed by path so jobs never share one, an antithetic pair shares one and negates it
+					stream := offset + sim
+					if simulationSettings.Antithetic {
+						stream /= 2
+					}
+					workerResource.SetSobolPoint(stream)
+
+					// antithetic pairs are (even, odd) paths, jobs start on an even path so a pair never splits across workers
+					if simulationSettings.Antithetic && sim%2 == 1 {
+						workerResource.ResetAntitheticPath()
+					} else {
+						workerResource.ResetPath(uint64(stream))
+					}
+
+					portfolio.Reset(initialPortfolioValue)
+					clear(logReturnSums)
+					clear(assetLogReturns)
 					pathValues := make([]float64, simulationSettings.SimulationDuration+1)
-					pathValues[0] = portfolioValue
+					pathValues[0] = initialPortfolioValue
+					depletionPeriod := 0
+
+					// returns are time weighted, a period's return is before its cash flows
+					logReturns := make([]float64, 0, simulationSettings.SimulationDuration)
+					previousValue, previousBenchmarkValue := initialPortfolioValue, initialPortfolioValue
+					if benchmark != nil {
+						benchmark.Reset(initialPortfolioValue)
+						active.Reset()
+					}
 
 					for period := range simulationSettings.SimulationDuration { // this will loop over the time steps for the duration by the unit of time
 						correlatedReturns := workerResource.GetCorrelatedReturns(simulationSettings.SimulationUnitOfTime)
-						portfolioReturn, err := ex.DotProduct(statisticalResources.AssetWeight, correlatedReturns)
-						if err != nil {
-							log.Printf("error calculating dot product in resource worker for simulation %d: %v", sim, err)
+						if len(correlatedReturns) != len(portfolio.Holdings) {
+							err := fmt.Errorf("got %d returns for %d holdings", len(correlatedReturns), len(portfolio.Holdings))
+							log.Printf("error stepping portfolio in resource worker for simulation %d: %v", sim, err)
 							return err
 						}
 
-						portfolioValue *= math.Exp(portfolioReturn)
+						for i := range logReturnSums {
+							logReturnSums[i] += correlatedReturns[i]
+						}
+
+						// shocks land on top of the random returns, after the control variate so its known expectation still holds
+						for i, shock := range statisticalResources.Shocks[period] {
+							correlatedReturns[i] += shock
+						}
+
+						for i := range assetLogReturns {
+							assetLogReturns[i] += correlatedReturns[i]
+						}
+
+						// holdings drift with their own returns, the rebalance policy decides when they go back to target
+						portfolioValue := portfolio.Step(period, correlatedReturns)
+						logReturn := math.Log(portfolioValue / previousValue)
+						logReturns = append(logReturns, logReturn)
+						if benchmark != nil {
+							benchmarkReturns := workerResource.GetBenchmarkReturns(period, correlatedReturns, simulationSettings.SimulationUnitOfTime)
+							benchmarkValue := benchmark.Step(period, benchmarkReturns)
+							active.Add(logReturn, math.Log(benchmarkValue/previousBenchmarkValue))
+							previousBenchmarkValue = benchmarkValue
+						}
+
+						if len(simulationSettings.CashFlows) > 0 {
+							cashFlow := getCashFlow(simulationSettings.CashFlows, period, portfolioValue, simulationSettings.SimulationUnitOfTime)
+							portfolioValue = portfolio.ApplyCashFlow(cashFlow)
+						}
+
 						pathValues[period+1] = portfolioValue
+						previousValue = portfolioValue
+						if portfolioValue <= 0 {
+							// ruin is final, the rest of the path stays at zero
+							depletionPeriod = period + 1
+							break
+						}
 					}
 
-					pathMetrics := calculatePathMetrics(pathValues, simulationSettings.SimulationUnitOfTime)
+					pathMetrics := calculatePathMetrics(pathValues, logReturns, simulationSettings.SimulationUnitOfTime, simulationSettings.RiskFreeRate)
 
-					res[sim] = &SimulationResult{
-						PathMetrics: pathMetrics,
-						PathValues:  pathValues,
+					result := &SimulationResult{
+						PathMetrics:     pathMetrics,
+						PathValues:      pathValues,
+						RegimePeriods:   workerResource.RegimePeriods(),
+						FinalWeights:    portfolio.Weights(),
+						Rebalances:      portfolio.Rebalances,
+						DepletionPeriod: depletionPeriod,
 					}
+
+					if benchmark != nil {
+						result.Active = active.Metrics(simulationSettings.SimulationUnitOfTime)
+					}
+
+					if simulationSettings.ControlVariate {
+						result.ControlValue = getControlValue(initialPortfolioValue, statisticalResources.AssetWeight, logReturnSums)
+					}
+
+					if res != nil {
+						result.AssetReturns = make([]float64, len(assetLogReturns))
+						for i, r := range assetLogReturns {
+							result.AssetReturns[i] = math.Expm1(r)
+						}
+						res[sim] = result
+					}
+					if jobAggregate != nil {
+						jobAggregate.Add(offset+sim, result)
+					}
+				}
+
+				if jobAggregate != nil {
+					finishedJobs <- finishedJob{index: j.start / BatchSize, aggregate: jobAggregate}
 				}
 			}
 
@@ -149,35 +329,49 @@ func (sc *ServiceContext) RunMonteCarloSimulation(statisticalResources *Statisti
 		})
 	}
 
-	if err := group.Wait(); err != nil {
-		return nil, err
+	err := group.Wait()
+	if aggregate != nil {
+		close(finishedJobs)
+		<-merged
 	}
 
-	return res, nil
+	return err
 }
 
-func calculatePathMetrics(pathValues []float64, simulationUnitOfTime int) PathMetrics {
-	n := len(pathValues)
+type finishedJob struct {
+	index     int
+	aggregate *SimulationAggregate
+}
 
-	var sumReturns, sumSquaredReturns, maxDrawdown, peak float64
-	logReturns := make([]float64, n-1)
-
-	for i := range n {
-		if i != 0 {
-			logReturn := math.Log(pathValues[i] / pathValues[i-1])
-			logReturns[i-1] = logReturn
-			sumReturns += logReturn
-			sumSquaredReturns += logReturn * logReturn
+// mergeFinishedJobs merges each job's aggregate once every job before it has been merged, jobs are handed out in order
+// so only a few finished jobs wait at any time
+func mergeFinishedJobs(aggregate *SimulationAggregate, finishedJobs <-chan finishedJob) {
+	waiting := make(map[int]*SimulationAggregate)
+	next := 0
+	for finished := range finishedJobs {
+		waiting[finished.index] = finished.aggregate
+		for a, ok := waiting[next]; ok; a, ok = waiting[next] {
+			aggregate.Merge(a)
+			delete(waiting, next)
+			next++
 		}
+	}
+}
 
-		if pathValues[i] > peak {
-			peak = pathValues[i]
-		}
+// calculatePathMetrics measures the path, the final value and total return are the wealth at the end, cash flows included.
+// the annualized return, volatility and drawdown are time weighted from the period log returns before any cash flow,
+// so a contribution is not a gain and a withdrawal is not a loss. a path that ran out of money has no returns after it did.
+func calculatePathMetrics(pathValues, logReturns []float64, simulationUnitOfTime int, riskFreeRate float64) PathMetrics {
+	n := len(pathValues)
 
-		drawdown := (peak - pathValues[i]) / peak
-		if drawdown > maxDrawdown {
-			maxDrawdown = drawdown
-		}
+	// the drawdown is of the growth of a unit invested, which only moves with the returns
+	var sumReturns, sumSquaredReturns, maxDrawdown, peak float64
+	for _, logReturn := range logReturns {
+		sumReturns += logReturn
+		sumSquaredReturns += logReturn * logReturn
+
+		peak = math.Max(peak, sumReturns)
+		maxDrawdown = math.Max(maxDrawdown, 1-math.Exp(sumReturns-peak))
 	}
 
 	initialValue := pathValues[0]
@@ -185,26 +379,44 @@ func calculatePathMetrics(pathValues []float64, simulationUnitOfTime int) PathMe
 	totalReturn := (finalValue - initialValue) / initialValue
 
 	// general required factors for annualization
-	numPeriods := float64(n - 1)
+	numReturns := float64(len(logReturns))
 	periodsPerYear := float64(simulationUnitOfTime)
 
 	// annualized return: geometric mean of returns, spelling this out to be explicit
-	totalLogReturn := math.Log(finalValue / initialValue)
-	annualizedReturn := math.Exp(totalLogReturn*periodsPerYear/numPeriods) - 1.0
+	annualizedReturn := 0.0
+	if numReturns > 0 {
+		annualizedReturn = math.Exp(sumReturns*periodsPerYear/numReturns) - 1.0
+	}
 
 	// annualized volatility: sample standard deviation of log returns, spelling this out to be explicit
-	meanReturn := sumReturns / numPeriods
-	variance := (sumSquaredReturns - numPeriods*meanReturn*meanReturn) / (numPeriods - 1)
-	periodVolatility := math.Sqrt(variance)
-	annualizedVolatility := periodVolatility * math.Sqrt(periodsPerYear)
+	annualizedVolatility := 0.0
+	if numReturns > 1 {
+		meanReturn := sumReturns / numReturns
+		variance := (sumSquaredReturns - numReturns*meanReturn*meanReturn) / (numReturns - 1)
+		periodVolatility := math.Sqrt(math.Max(variance, 0))
+		annualizedVolatility = periodVolatility * math.Sqrt(periodsPerYear)
+	}
 
-	return PathMetrics{
+	res := PathMetrics{
 		FinalValue:           finalValue,
 		TotalReturn:          totalReturn,
 		AnnualizedReturn:     annualizedReturn,
 		AnnualizedVolatility: annualizedVolatility,
 		MaxDrawdown:          maxDrawdown,
 	}
+
+	performance := &pathPerformance{
+		PathMetrics:    res,
+		logReturns:     logReturns,
+		periodsPerYear: periodsPerYear,
+		riskFreeRate:   riskFreeRate,
+	}
+	res.Performance = make([]float64, len(performanceMetrics))
+	for i, m := range performanceMetrics {
+		res.Performance[i] = m.value(performance)
+	}
+
+	return res
 }
 
 func (sc *ServiceContext) getSeriesReturns(scenario *dm.Scenario, maxLookback time.Time) ([]*SeriesReturns, error) {
@@ -2,6 +2,8 @@ package core
 
 import (
 	"context"
+	"math"
+	"slices"
 	"testing"
 	"time"
 
@@ -104,8 +106,107 @@ func TestRunMonteCarloSimulation_SingleAsset(t *testing.T) {
 		if len(r.PathValues) != settings.SimulationDuration+1 {
 			t.Errorf("result[%d]: expected PathValues length %d, got %d", i, settings.SimulationDuration+1, len(r.PathValues))
 		}
-		if r.PathValues[0] != InitialPortfolioValue {
-			t.Errorf("result[%d]: expected initial value %f, got %v", i, InitialPortfolioValue, r.PathValues[0])
+		if r.PathValues[0] != sm.DefaultInitialPortfolioValue {
+			t.Errorf("result[%d]: expected initial value %f, got %v", i, sm.DefaultInitialPortfolioValue, r.PathValues[0])
 		}
 	}
 }
+
+// TestRunMonteCarloSimulation_InitialPortfolioValue starts from real capital and checks the money amounts line up with the returns
+func TestRunMonteCarloSimulation_InitialPortfolioValue(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*5)
+	settings := sm.SimulationRequestSettings{
+		DistType:              sm.StandardNormal,
+		SimulationUnitOfTime:  sm.Weekly,
+		SimulationDuration:    52,
+		Iterations:            1_000,
+		Seed:                  42,
+		InitialPortfolioValue: 250_000,
+		Currency:              "eur",
+	}
+
+	if err := validateReportingSettings(settings); err != nil {
+		t.Fatalf("validateReportingSettings: %v", err)
+	}
+	if settings.GetCurrency() != "EUR" {
+		t.Errorf("Expected currency to be upper cased, got %s", settings.GetCurrency())
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	sc := &ServiceContext{Context: context.Background()}
+	res, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	for i, r := range res {
+		if r.PathValues[0] != settings.InitialPortfolioValue {
+			t.Fatalf("result[%d]: expected initial value %.2f, got %.2f", i, settings.InitialPortfolioValue, r.PathValues[0])
+		}
+	}
+
+	metrics := buildSimulationResponse(aggregateResults(res, sr, settings)).RiskMetrics
+	if math.Abs(metrics.VaR95Amount-metrics.VaR95*settings.InitialPortfolioValue) > 1e-6 {
+		t.Errorf("Expected var95 amount %.2f, got %.2f", metrics.VaR95*settings.InitialPortfolioValue, metrics.VaR95Amount)
+	}
+	if metrics.CVaR99Amount > metrics.VaR99Amount {
+		t.Errorf("Expected cvar99 amount (%.2f) to be a larger loss than var99 amount (%.2f)", metrics.CVaR99Amount, metrics.VaR99Amount)
+	}
+
+	for _, invalid := range []sm.SimulationRequestSettings{{InitialPortfolioValue: -1}, {Currency: "US"}, {Currency: "US1"}} {
+		if err := validateReportingSettings(invalid); err == nil {
+			t.Errorf("Expected an error for initial value %.2f and currency %q", invalid.InitialPortfolioValue, invalid.Currency)
+		}
+	}
+}
+
+// TestRunMonteCarloSimulation_ReproduciblePaths makes sure a path only depends on the seed and its index, not on which job or worker ran it
+func TestRunMonteCarloSimulation_ReproduciblePaths(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*5)
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StudentT,
+		DegreesOfFreedom:     5,
+		SimulationUnitOfTime: sm.Weekly,
+		SimulationDuration:   26,
+		Iterations:           BatchSize * 2,
+		Seed:                 42,
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	sc := &ServiceContext{Context: context.Background()}
+	res, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	// a few paths from the middle of the second job, run on their own
+	offset := BatchSize + 1_234
+	partial := make([]*SimulationResult, 3)
+	if err := sc.simulatePaths(sr, settings, offset, len(partial), partial, nil); err != nil {
+		t.Fatalf("simulatePaths: %v", err)
+	}
+	for i, r := range partial {
+		if !slices.Equal(r.PathValues, res[offset+i].PathValues) {
+			t.Errorf("path %d: expected the same values when run on its own, got %.6f and %.6f", offset+i, r.FinalValue, res[offset+i].FinalValue)
+		}
+	}
+
+	if res[0].FinalValue == res[1].FinalValue {
+		t.Error("Expected neighbouring paths to draw different shocks")
+	}
+
+	if seed := getEffectiveSeed(0); seed == 0 {
+		t.Error("Expected a random seed when none is given")
+	}
+	if seed := getEffectiveSeed(42); seed != 42 {
+		t.Errorf("Expected the requested seed to be kept, got %d", seed)
+	}
+}
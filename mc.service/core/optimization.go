@@ -0,0 +1,445 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+
+	dm "mc.data/models"
+	sm "mc.service/models"
+)
+
+const (
+	DefaultFrontierPoints  = 20
+	MaxFrontierPoints      = 200
+	maxOptimizerIterations = 50_000
+	optimizerTolerance     = 1e-12 // largest weight change between iterations that counts as converged
+	frontierSearchSteps    = 60    // bisection and golden section steps over the risk tolerance
+	savedWeightDecimals    = 6     // scenario weights are stored as NUMERIC(8, 6)
+)
+
+// meanVarianceProblem is a long only portfolio with per asset bounds, fully invested, on annualized estimates
+type meanVarianceProblem struct {
+	mu           []float64
+	covariance   *mat.SymDense
+	lower, upper []float64
+	step         float64 // 1 / lipschitz constant of the gradient
+}
+
+// optimizationInputs is what every optimizer of a floated weight scenario starts from, the same estimates a simulation would use
+type optimizationInputs struct {
+	scenario             *dm.Scenario
+	settings             sm.SimulationRequestSettings
+	seriesReturns        []*SeriesReturns
+	statisticalResources *StatisticalResources
+}
+
+// getOptimizationInputs gets the scenario and the estimates the settings ask for, only scenarios that float their weights can be optimized
+func (sc *ServiceContext) getOptimizationInputs(scenarioID int32, settings sm.SimulationRequestSettings) (*optimizationInputs, int, error) {
+	scenario, err := sc.PostgresConnection.GetScenarioByID(sc.Context, scenarioID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("error getting scenario: %v", err)
+	}
+
+	if !scenario.FloatedWeight {
+		return nil, http.StatusBadRequest, fmt.Errorf("scenario %s does not float its weights", scenario.Name)
+	}
+
+	settings, err = sc.resolveCapitalMarketAssumptions(scenario, settings)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	settings, err = sc.resolveViews(scenario, settings)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	seriesReturns, err := sc.getSeriesReturns(scenario, time.Now().Add(-settings.MaxLookback))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	statisticalResources, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	return &optimizationInputs{
+		scenario:             scenario,
+		settings:             settings,
+		seriesReturns:        seriesReturns,
+		statisticalResources: statisticalResources,
+	}, http.StatusOK, nil
+}
+
+func (inputs *optimizationInputs) assetIds() []int32 {
+	res := make([]int32, len(inputs.seriesReturns))
+	for i, r := range inputs.seriesReturns {
+		res[i] = r.AssetId
+	}
+	return res
+}
+
+// RunOptimization computes the efficient frontier of a floated weight scenario from the estimates a simulation would use,
+// and saves the max sharpe or min variance weights as the scenario's when asked
+func (sc *ServiceContext) RunOptimization(scenarioID int32, request sm.OptimizationRequest) (*sm.OptimizationResponse, int, error) {
+	if err := validateOptimizationRequest(request); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	inputs, status, err := sc.getOptimizationInputs(scenarioID, request.Settings)
+	if err != nil {
+		return nil, status, err
+	}
+
+	problem, err := newMeanVarianceProblem(inputs.seriesReturns, inputs.statisticalResources, request.Bounds)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	res := &sm.OptimizationResponse{
+		ScenarioId:      inputs.scenario.Id,
+		AssetIds:        inputs.assetIds(),
+		ExpectedReturns: inputs.statisticalResources.Mu,
+		Volatilities:    inputs.statisticalResources.Sigma,
+		Current:         problem.point(inputs.statisticalResources.AssetWeight, request.RiskFreeRate),
+	}
+
+	nPoints := request.FrontierPoints
+	if nPoints == 0 {
+		nPoints = DefaultFrontierPoints
+	}
+
+	res.Frontier, res.MinVariance, res.MaxSharpe = problem.getEfficientFrontier(nPoints, request.RiskFreeRate)
+	log.Printf("Optimized scenario %v: min variance volatility %.4f, max sharpe %.4f", inputs.scenario.Name, res.MinVariance.Volatility, res.MaxSharpe.SharpeRatio)
+
+	if request.Save == "" {
+		return res, http.StatusOK, nil
+	}
+
+	weights := res.MaxSharpe.Weights
+	if request.Save == sm.MinVariancePortfolio {
+		weights = res.MinVariance.Weights
+	}
+
+	if status, err := sc.saveOptimizedWeights(inputs, weights); err != nil {
+		return nil, status, err
+	}
+
+	res.Saved = request.Save
+	return res, http.StatusOK, nil
+}
+
+// saveOptimizedWeights makes the weights the scenario's, nothing else about the scenario changes
+func (sc *ServiceContext) saveOptimizedWeights(inputs *optimizationInputs, weights []float64) (int, error) {
+	_, status, err := sc.UpdateScenario(inputs.scenario.Id, getOptimizedScenarioRequest(inputs.scenario, inputs.assetIds(), weights))
+	if err == nil {
+		log.Printf("Saved optimized weights %v to scenario %v", weights, inputs.scenario.Name)
+	}
+	return status, err
+}
+
+func validateOptimizationRequest(request sm.OptimizationRequest) error {
+	if request.FrontierPoints < 0 || request.FrontierPoints == 1 || request.FrontierPoints > MaxFrontierPoints {
+		return fmt.Errorf("frontier points must be between 2 and %d, got %d", MaxFrontierPoints, request.FrontierPoints)
+	}
+
+	switch request.Save {
+	case "", sm.MaxSharpePortfolio, sm.MinVariancePortfolio:
+	default:
+		return fmt.Errorf("save must be %s or %s, got %q", sm.MaxSharpePortfolio, sm.MinVariancePortfolio, request.Save)
+	}
+
+	return nil
+}
+
+// newMeanVarianceProblem annualizes the covariance from sigma and the correlations, so it agrees with any assumptions or repair
+func newMeanVarianceProblem(seriesReturns []*SeriesReturns, statisticalResources *StatisticalResources, bounds []sm.WeightBounds) (*meanVarianceProblem, error) {
+	n := len(seriesReturns)
+	p := &meanVarianceProblem{
+		mu:         statisticalResources.Mu,
+		covariance: mat.NewSymDense(n, nil),
+	}
+
+	corrMatrix := GetCorrelationMatrix(statisticalResources.CovMatrix)
+	for i := range n {
+		for j := range i + 1 {
+			p.covariance.SetSym(i, j, corrMatrix.At(i, j)*statisticalResources.Sigma[i]*statisticalResources.Sigma[j])
+		}
+	}
+
+	var err error
+	if p.lower, p.upper, err = getWeightBounds(seriesReturns, bounds); err != nil {
+		return nil, err
+	}
+
+	var eigen mat.EigenSym
+	if ok := eigen.Factorize(p.covariance, false); !ok {
+		return nil, fmt.Errorf("unable to factorize the covariance matrix")
+	}
+	values := eigen.Values(nil)
+	p.step = 1 / (2 * math.Max(values[n-1], 1e-12))
+
+	return p, nil
+}
+
+// getWeightBounds lines the bounds up with the assets, assets without bounds can take any weight from 0 to 1
+func getWeightBounds(seriesReturns []*SeriesReturns, bounds []sm.WeightBounds) ([]float64, []float64, error) {
+	n := len(seriesReturns)
+	lower, upper := make([]float64, n), make([]float64, n)
+	for i := range n {
+		upper[i] = 1
+	}
+
+	seen := make(map[int32]bool, len(bounds))
+	for _, b := range bounds {
+		idx := slices.IndexFunc(seriesReturns, func(r *SeriesReturns) bool { return r.AssetId == b.AssetId })
+		if idx < 0 {
+			return nil, nil, fmt.Errorf("bounds asset id %d is not in the scenario", b.AssetId)
+		}
+		if seen[b.AssetId] {
+			return nil, nil, fmt.Errorf("duplicate bounds for asset id %d", b.AssetId)
+		}
+		seen[b.AssetId] = true
+
+		if b.Min < 0 || b.Max > 1 || b.Min > b.Max {
+			return nil, nil, fmt.Errorf("bounds for asset id %d must satisfy 0 <= min <= max <= 1, got %.4f and %.4f", b.AssetId, b.Min, b.Max)
+		}
+		lower[idx], upper[idx] = b.Min, b.Max
+	}
+
+	// the weights have to be able to sum to 1
+	lowerSum, upperSum := 0.0, 0.0
+	for i := range n {
+		lowerSum += lower[i]
+		upperSum += upper[i]
+	}
+	if lowerSum > 1+1e-9 || upperSum < 1-1e-9 {
+		return nil, nil, fmt.Errorf("bounds can not be met, the minimums sum to %.4f and the maximums to %.4f", lowerSum, upperSum)
+	}
+
+	return lower, upper, nil
+}
+
+// getEfficientFrontier spaces the points evenly in expected return from the min variance portfolio to the max return portfolio,
+// each point is the lowest variance portfolio with that return. max sharpe is found along the same curve.
+func (p *meanVarianceProblem) getEfficientFrontier(nPoints int, riskFreeRate float64) ([]sm.PortfolioPoint, sm.PortfolioPoint, sm.PortfolioPoint) {
+	minVariance := p.solve(0, nil)
+	minReturn := p.expectedReturn(minVariance)
+
+	// the risk tolerance that reaches the max return portfolio, the solution stops moving past it
+	maxReturn := p.expectedReturn(p.getMaxReturnWeights())
+	highTolerance := 1.0
+	for p.expectedReturn(p.solve(highTolerance, nil)) < maxReturn-1e-9 && highTolerance < 1e12 {
+		highTolerance *= 2
+	}
+
+	frontier := make([]sm.PortfolioPoint, nPoints)
+	frontier[0] = p.point(minVariance, riskFreeRate)
+	previous, low := minVariance, 0.0
+	for k := 1; k < nPoints; k++ {
+		target := minReturn + (maxReturn-minReturn)*float64(k)/float64(nPoints-1)
+
+		// the return of the solution grows with the risk tolerance, so the tolerance that hits the target is found by bisection
+		lo, hi := low, highTolerance
+		for range frontierSearchSteps {
+			mid := (lo + hi) / 2
+			if p.expectedReturn(p.solve(mid, previous)) < target {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+
+		previous, low = p.solve(hi, previous), lo
+		frontier[k] = p.point(previous, riskFreeRate)
+	}
+
+	// the sharpe ratio is quasi concave along the frontier, golden section over the risk tolerance finds the top
+	sharpe := func(tolerance float64) float64 {
+		return p.point(p.solve(tolerance, nil), riskFreeRate).SharpeRatio
+	}
+	golden := (math.Sqrt(5) - 1) / 2
+	a, b := 0.0, highTolerance
+	for range frontierSearchSteps {
+		c, d := b-golden*(b-a), a+golden*(b-a)
+		if sharpe(c) >= sharpe(d) {
+			b = d
+		} else {
+			a = c
+		}
+	}
+	maxSharpe := p.point(p.solve((a+b)/2, nil), riskFreeRate)
+
+	// an endpoint of the frontier can beat the interior, for example when every asset returns less than the risk free rate
+	for _, point := range []sm.PortfolioPoint{frontier[0], frontier[nPoints-1]} {
+		if point.SharpeRatio > maxSharpe.SharpeRatio {
+			maxSharpe = point
+		}
+	}
+
+	return frontier, frontier[0], maxSharpe
+}
+
+// solve minimizes w'Σw - tolerance μ'w over the bounds with accelerated projected gradient (fista), start warms it up
+func (p *meanVarianceProblem) solve(tolerance float64, start []float64) []float64 {
+	n := len(p.mu)
+	w := start
+	if w == nil {
+		w = p.project(make([]float64, n))
+	}
+
+	y := slices.Clone(w)
+	gradient := mat.NewVecDense(n, nil)
+	momentum := 1.0
+	for range maxOptimizerIterations {
+		gradient.MulVec(p.covariance, mat.NewVecDense(n, y))
+
+		next := make([]float64, n)
+		for i := range n {
+			next[i] = y[i] - p.step*(2*gradient.AtVec(i)-tolerance*p.mu[i])
+		}
+		next = p.project(next)
+
+		change := 0.0
+		nextMomentum := (1 + math.Sqrt(1+4*momentum*momentum)) / 2
+		for i := range n {
+			change = math.Max(change, math.Abs(next[i]-w[i]))
+			y[i] = next[i] + (momentum-1)/nextMomentum*(next[i]-w[i])
+		}
+
+		w, momentum = next, nextMomentum
+		if change < optimizerTolerance {
+			break
+		}
+	}
+
+	return w
+}
+
+// project finds the nearest weights within the bounds that sum to 1, by bisection on the shift that clamps them there
+func (p *meanVarianceProblem) project(v []float64) []float64 {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i := range v {
+		lo = math.Min(lo, v[i]-p.upper[i])
+		hi = math.Max(hi, v[i]-p.lower[i])
+	}
+
+	res := make([]float64, len(v))
+	clamped := func(shift float64) float64 {
+		sum := 0.0
+		for i := range v {
+			res[i] = math.Min(math.Max(v[i]-shift, p.lower[i]), p.upper[i])
+			sum += res[i]
+		}
+		return sum
+	}
+
+	for range 100 {
+		mid := (lo + hi) / 2
+		if clamped(mid) > 1 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	clamped((lo + hi) / 2)
+	return res
+}
+
+// getMaxReturnWeights fills the highest returning assets up to their max once every asset has its min
+func (p *meanVarianceProblem) getMaxReturnWeights() []float64 {
+	res := slices.Clone(p.lower)
+	remaining := 1.0
+	for _, l := range p.lower {
+		remaining -= l
+	}
+
+	order := make([]int, len(p.mu))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		if p.mu[a] > p.mu[b] {
+			return -1
+		}
+		if p.mu[a] < p.mu[b] {
+			return 1
+		}
+		return 0
+	})
+
+	for _, i := range order {
+		add := math.Min(remaining, p.upper[i]-p.lower[i])
+		res[i] += add
+		remaining -= add
+	}
+
+	return res
+}
+
+func (p *meanVarianceProblem) expectedReturn(weights []float64) float64 {
+	res := 0.0
+	for i, w := range weights {
+		res += w * p.mu[i]
+	}
+	return res
+}
+
+func (p *meanVarianceProblem) point(weights []float64, riskFreeRate float64) sm.PortfolioPoint {
+	w := mat.NewVecDense(len(weights), slices.Clone(weights))
+	res := sm.PortfolioPoint{
+		ExpectedReturn: p.expectedReturn(weights),
+		Volatility:     math.Sqrt(math.Max(mat.Inner(w, p.covariance, w), 0)),
+		Weights:        w.RawVector().Data,
+	}
+
+	if res.Volatility > 0 {
+		res.SharpeRatio = (res.ExpectedReturn - riskFreeRate) / res.Volatility
+	}
+
+	return res
+}
+
+// getOptimizedScenarioRequest keeps everything about the scenario but its weights, assets at zero weight stay in the scenario
+func getOptimizedScenarioRequest(scenario *dm.Scenario, assetIds []int32, weights []float64) sm.ScenarioRequest {
+	return sm.ScenarioRequest{
+		Name:               scenario.Name,
+		FloatedWeight:      scenario.FloatedWeight,
+		RebalancePolicy:    scenario.RebalancePolicy,
+		RebalanceFrequency: scenario.RebalanceFrequency,
+		RebalanceThreshold: scenario.RebalanceThreshold,
+		AssumptionSetId:    scenario.AssumptionSetId,
+		Components:         getScenarioComponents(assetIds, weights),
+	}
+}
+
+// getScenarioComponents rounds the weights to what the database stores, the rounding is put on the largest weight
+// so they still sum to 1
+func getScenarioComponents(assetIds []int32, weights []float64) []sm.ScenarioComponentPayload {
+	scale := math.Pow(10, savedWeightDecimals)
+	rounded := make([]float64, len(weights))
+	largest, sum := 0, 0.0
+	for i, w := range weights {
+		rounded[i] = math.Round(w*scale) / scale
+		sum += rounded[i]
+		if w > weights[largest] {
+			largest = i
+		}
+	}
+	rounded[largest] = math.Round((rounded[largest]+1-sum)*scale) / scale
+
+	res := make([]sm.ScenarioComponentPayload, len(assetIds))
+	for i, id := range assetIds {
+		res[i] = sm.ScenarioComponentPayload{AssetId: id, Weight: rounded[i]}
+	}
+
+	return res
+}
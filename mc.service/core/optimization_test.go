@@ -0,0 +1,158 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+
+	dm "mc.data/models"
+	sm "mc.service/models"
+)
+
+// getMockOptimizationAssets only needs the asset ids, the estimates are set directly
+func getMockOptimizationAssets(ids ...int32) []*SeriesReturns {
+	res := make([]*SeriesReturns, len(ids))
+	for i, id := range ids {
+		res[i] = &SeriesReturns{ScenarioConfigurationComponent: dm.ScenarioConfigurationComponent{AssetId: id}}
+	}
+	return res
+}
+
+// getMockMeanVarianceProblem is three uncorrelated assets with rising returns and volatilities
+func getMockMeanVarianceProblem(t *testing.T, bounds []sm.WeightBounds) *meanVarianceProblem {
+	t.Helper()
+
+	seriesReturns := getMockOptimizationAssets(1, 2, 3)
+	sr := &StatisticalResources{
+		Mu:        []float64{0.04, 0.07, 0.10},
+		Sigma:     []float64{0.10, 0.15, 0.25},
+		CovMatrix: mat.NewSymDense(3, []float64{0.0001, 0, 0, 0, 0.000225, 0, 0, 0, 0.000625}),
+	}
+
+	p, err := newMeanVarianceProblem(seriesReturns, sr, bounds)
+	if err != nil {
+		t.Fatalf("newMeanVarianceProblem: %v", err)
+	}
+	return p
+}
+
+func TestMeanVarianceProblem_Project(t *testing.T) {
+	p := getMockMeanVarianceProblem(t, []sm.WeightBounds{{AssetId: 1, Min: 0.1, Max: 0.3}})
+
+	for _, v := range [][]float64{{0, 0, 0}, {5, -2, 1}, {0.2, 0.3, 0.5}, {-1, -1, -1}} {
+		w := p.project(v)
+		sum := 0.0
+		for i := range w {
+			if w[i] < p.lower[i]-1e-12 || w[i] > p.upper[i]+1e-12 {
+				t.Errorf("%v: weight %d out of bounds, got %.6f", v, i, w[i])
+			}
+			sum += w[i]
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("%v: expected weights to sum to 1, got %.9f", v, sum)
+		}
+	}
+
+	// already feasible weights stay put
+	if w := p.project([]float64{0.2, 0.3, 0.5}); math.Abs(w[0]-0.2) > 1e-9 || math.Abs(w[2]-0.5) > 1e-9 {
+		t.Errorf("Expected a feasible point to be its own projection, got %v", w)
+	}
+}
+
+func TestMeanVarianceProblem_MinVariance(t *testing.T) {
+	p := getMockMeanVarianceProblem(t, nil)
+
+	// uncorrelated, so the min variance weights are proportional to 1 / variance
+	w := p.solve(0, nil)
+	inverse := []float64{1 / 0.01, 1 / 0.0225, 1 / 0.0625}
+	total := inverse[0] + inverse[1] + inverse[2]
+	for i := range w {
+		if math.Abs(w[i]-inverse[i]/total) > 1e-6 {
+			t.Errorf("asset %d: expected min variance weight %.6f, got %.6f", i, inverse[i]/total, w[i])
+		}
+	}
+
+	// capping the first asset pushes the rest onto the others
+	bounded := getMockMeanVarianceProblem(t, []sm.WeightBounds{{AssetId: 1, Min: 0, Max: 0.4}})
+	if w := bounded.solve(0, nil); math.Abs(w[0]-0.4) > 1e-6 {
+		t.Errorf("Expected the capped asset at its max of 0.4, got %.6f", w[0])
+	}
+}
+
+func TestMeanVarianceProblem_EfficientFrontier(t *testing.T) {
+	p := getMockMeanVarianceProblem(t, []sm.WeightBounds{{AssetId: 3, Min: 0.05, Max: 0.6}})
+
+	frontier, minVariance, maxSharpe := p.getEfficientFrontier(10, 0.02)
+	if len(frontier) != 10 {
+		t.Fatalf("Expected 10 frontier points, got %d", len(frontier))
+	}
+
+	for k := 1; k < len(frontier); k++ {
+		if frontier[k].ExpectedReturn <= frontier[k-1].ExpectedReturn || frontier[k].Volatility < frontier[k-1].Volatility-1e-9 {
+			t.Errorf("point %d: expected return and volatility to rise along the frontier, got %+v after %+v", k, frontier[k], frontier[k-1])
+		}
+	}
+
+	// the max return portfolio puts the max on the best asset and the rest on the next one
+	last := frontier[len(frontier)-1]
+	if math.Abs(last.Weights[2]-0.6) > 1e-4 || math.Abs(last.Weights[1]-0.4) > 1e-4 {
+		t.Errorf("Expected the last point at the max return weights, got %v", last.Weights)
+	}
+
+	if minVariance.Volatility != frontier[0].Volatility {
+		t.Errorf("Expected the min variance portfolio to start the frontier")
+	}
+	for k, point := range frontier {
+		if point.SharpeRatio > maxSharpe.SharpeRatio+1e-6 {
+			t.Errorf("point %d: sharpe %.6f beats the max sharpe %.6f", k, point.SharpeRatio, maxSharpe.SharpeRatio)
+		}
+	}
+}
+
+func TestNewMeanVarianceProblem_InvalidBounds(t *testing.T) {
+	seriesReturns := getMockOptimizationAssets(1, 2)
+	sr := &StatisticalResources{
+		Mu:        []float64{0.05, 0.08},
+		Sigma:     []float64{0.1, 0.2},
+		CovMatrix: mat.NewSymDense(2, []float64{1, 0, 0, 1}),
+	}
+
+	invalid := map[string][]sm.WeightBounds{
+		"unknown asset":   {{AssetId: 9, Max: 1}},
+		"duplicate":       {{AssetId: 1, Max: 1}, {AssetId: 1, Max: 1}},
+		"short":           {{AssetId: 1, Min: -0.1, Max: 1}},
+		"min above max":   {{AssetId: 1, Min: 0.6, Max: 0.5}},
+		"mins over 1":     {{AssetId: 1, Min: 0.6, Max: 1}, {AssetId: 2, Min: 0.6, Max: 1}},
+		"maxes under 1":   {{AssetId: 1, Max: 0.4}, {AssetId: 2, Max: 0.4}},
+		"leveraged asset": {{AssetId: 2, Max: 1.5}},
+	}
+	for name, bounds := range invalid {
+		if _, err := newMeanVarianceProblem(seriesReturns, sr, bounds); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestGetOptimizedScenarioRequest(t *testing.T) {
+	scenario := &dm.Scenario{ScenarioConfiguration: dm.ScenarioConfiguration{Name: "floated", FloatedWeight: true}}
+	req := getOptimizedScenarioRequest(scenario, []int32{1, 2, 3}, []float64{1.0 / 3, 1.0 / 3, 1.0/3 + 1e-9})
+
+	sum := 0.0
+	for _, c := range req.Components {
+		sum += c.Weight
+	}
+	if math.Abs(sum-1) > 1e-12 || req.Name != "floated" || !req.FloatedWeight {
+		t.Errorf("Expected rounded weights summing to 1 on the same scenario, got %+v", req)
+	}
+
+	// an optimizer can zero an asset out, a floated scenario keeps it
+	req = getOptimizedScenarioRequest(scenario, []int32{1, 2}, []float64{0, 1})
+	if err := validateScenarioRequest(req); err != nil {
+		t.Errorf("Expected a zero weight to be valid on a floated scenario: %v", err)
+	}
+	req.FloatedWeight = false
+	if err := validateScenarioRequest(req); err == nil {
+		t.Error("Expected a zero weight to be invalid on a fixed scenario")
+	}
+}
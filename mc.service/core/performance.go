@@ -0,0 +1,134 @@
+package core
+
+import (
+	"fmt"
+	"math"
+
+	sm "mc.service/models"
+)
+
+// pathPerformance is what the performance metrics get to work with for one path, the metrics are time weighted so cash flows
+// move the path value but not its ratios
+type pathPerformance struct {
+	PathMetrics
+	logReturns     []float64 // per period before any cash flow, a path that ran out of money has none once it hits zero
+	periodsPerYear float64
+	riskFreeRate   float64 // annualized
+}
+
+// performanceMetric is a risk adjusted measure of a path, NaN when the path does not define it (a sharpe ratio without any volatility)
+type performanceMetric struct {
+	name  string
+	value func(*pathPerformance) float64
+}
+
+// performanceMetrics are calculated for every path and reported as distributions over the paths,
+// a new metric only needs an entry here, the simulation and the aggregate pick it up from the list
+var performanceMetrics = []performanceMetric{
+	{"sharpe", sharpeRatio},
+	{"sortino", sortinoRatio},
+	{"calmar", calmarRatio},
+	{"omega", omegaRatio},
+	{"ulcer", ulcerIndex},
+}
+
+func validateRiskFreeRate(riskFreeRate float64) error {
+	if riskFreeRate <= -1 || riskFreeRate >= 1 {
+		return fmt.Errorf("risk free rate must be between -1 and 1, got %.6f", riskFreeRate)
+	}
+	return nil
+}
+
+// periodThreshold is the risk free rate as a log return per period
+func (p *pathPerformance) periodThreshold() float64 {
+	return math.Log1p(p.riskFreeRate) / p.periodsPerYear
+}
+
+// sharpeRatio is the annualized return over the risk free rate per unit of annualized volatility
+func sharpeRatio(p *pathPerformance) float64 {
+	if p.AnnualizedVolatility == 0 {
+		return math.NaN()
+	}
+	return (p.AnnualizedReturn - p.riskFreeRate) / p.AnnualizedVolatility
+}
+
+// sortinoRatio only counts the periods that returned less than the risk free rate as risk
+func sortinoRatio(p *pathPerformance) float64 {
+	if len(p.logReturns) == 0 {
+		return math.NaN()
+	}
+
+	threshold := p.periodThreshold()
+	downside := 0.0
+	for _, r := range p.logReturns {
+		if r < threshold {
+			downside += (r - threshold) * (r - threshold)
+		}
+	}
+	if downside == 0 {
+		return math.NaN()
+	}
+
+	downsideDeviation := math.Sqrt(downside / float64(len(p.logReturns)) * p.periodsPerYear)
+	return (p.AnnualizedReturn - p.riskFreeRate) / downsideDeviation
+}
+
+// calmarRatio is the annualized return per unit of max drawdown
+func calmarRatio(p *pathPerformance) float64 {
+	if p.MaxDrawdown == 0 {
+		return math.NaN()
+	}
+	return p.AnnualizedReturn / p.MaxDrawdown
+}
+
+// omegaRatio is the returns above the risk free rate over the returns below it, period by period
+func omegaRatio(p *pathPerformance) float64 {
+	threshold := p.periodThreshold()
+	var gains, losses float64
+	for _, r := range p.logReturns {
+		if r > threshold {
+			gains += r - threshold
+		} else {
+			losses += threshold - r
+		}
+	}
+
+	if losses == 0 {
+		return math.NaN()
+	}
+	return gains / losses
+}
+
+// ulcerIndex is the root mean square drawdown of the time weighted index, so it weighs how deep and how long the path was under water.
+// the index starts level at 0, which counts as one more value with no drawdown
+func ulcerIndex(p *pathPerformance) float64 {
+	var cumulative, peak, sumSquares float64
+	for _, r := range p.logReturns {
+		cumulative += r
+		peak = math.Max(peak, cumulative)
+		drawdown := 1 - math.Exp(cumulative-peak)
+		sumSquares += drawdown * drawdown
+	}
+	return math.Sqrt(sumSquares / float64(len(p.logReturns)+1))
+}
+
+func newPerformanceAggregates() []*tDigest {
+	res := make([]*tDigest, len(performanceMetrics))
+	for i := range res {
+		res[i] = newTDigest()
+	}
+	return res
+}
+
+// calculatePerformanceSummaries reports every performance metric, in the order of performanceMetrics
+func calculatePerformanceSummaries(aggregate *SimulationAggregate) []sm.PerformanceSummary {
+	res := make([]sm.PerformanceSummary, len(performanceMetrics))
+	for i, m := range performanceMetrics {
+		res[i] = sm.PerformanceSummary{
+			Name:               m.name,
+			Paths:              int(aggregate.performance[i].Count()),
+			MetricDistribution: calculateMetricDistribution(aggregate.performance[i]),
+		}
+	}
+	return res
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	sm "mc.service/models"
+)
+
+func TestCalculatePathMetrics_Performance(t *testing.T) {
+	returns := []float64{math.Log(1.1), math.Log(0.9), math.Log(121.0 / 99)}
+	metrics := calculatePathMetrics([]float64{100, 110, 99, 121}, returns, sm.Yearly, 0.02)
+	performance := make(map[string]float64, len(performanceMetrics))
+	for i, m := range performanceMetrics {
+		performance[m.name] = metrics.Performance[i]
+	}
+
+	threshold := math.Log(1.02)
+	expected := map[string]float64{
+		"sharpe":  (metrics.AnnualizedReturn - 0.02) / metrics.AnnualizedVolatility,
+		"sortino": (metrics.AnnualizedReturn - 0.02) / math.Sqrt((returns[1]-threshold)*(returns[1]-threshold)/3), // only the second year is under the risk free rate
+		"calmar":  metrics.AnnualizedReturn / 0.1,
+		"omega":   (returns[0] + returns[2] - 2*threshold) / (threshold - returns[1]),
+		"ulcer":   math.Sqrt(0.1 * 0.1 / 4), // 10% under water for one of the four values
+	}
+	for name, e := range expected {
+		if math.Abs(performance[name]-e) > 1e-12 {
+			t.Errorf("%s: expected %.6f, got %.6f", name, e, performance[name])
+		}
+	}
+
+	// a contribution moves the values but not the returns, so none of the ratios change
+	contributed := calculatePathMetrics([]float64{100, 110, 109, 143.2}, returns, sm.Yearly, 0.02)
+	for i, m := range performanceMetrics {
+		if contributed.Performance[i] != metrics.Performance[i] {
+			t.Errorf("%s: expected no change with a contribution, got %.6f and %.6f", m.name, contributed.Performance[i], metrics.Performance[i])
+		}
+	}
+
+	// a flat path has no risk, so only the ulcer index is defined
+	metrics = calculatePathMetrics([]float64{100, 100, 100}, []float64{0, 0}, sm.Yearly, 0)
+	for i, m := range performanceMetrics {
+		if v := metrics.Performance[i]; (m.name == "ulcer" && v != 0) || (m.name != "ulcer" && !math.IsNaN(v)) {
+			t.Errorf("%s: expected the metric to be undefined on a flat path, got %.6f", m.name, v)
+		}
+	}
+}
+
+func TestRunMonteCarloSimulation_Performance(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily)
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Weekly,
+		SimulationDuration:   52,
+		Iterations:           500,
+		Seed:                 42,
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	sc := &ServiceContext{Context: context.Background()}
+	run := func(riskFreeRate float64) []sm.PerformanceSummary {
+		settings.RiskFreeRate = riskFreeRate
+		res, err := sc.RunMonteCarloSimulation(sr, settings)
+		if err != nil {
+			t.Fatalf("RunMonteCarloSimulation: %v", err)
+		}
+		return calculatePerformanceSummaries(aggregateResults(res, sr, settings))
+	}
+
+	base := run(0)
+	if len(base) != len(performanceMetrics) {
+		t.Fatalf("Expected %d performance summaries, got %d", len(performanceMetrics), len(base))
+	}
+	for i, p := range base {
+		if p.Name != performanceMetrics[i].name || p.Paths != settings.Iterations {
+			t.Errorf("Expected %s on every path, got %s on %d", performanceMetrics[i].name, p.Name, p.Paths)
+		}
+		if p.P5 > p.P50 || p.P50 > p.P95 {
+			t.Errorf("%s: expected ordered percentiles, got %+v", p.Name, p.MetricDistribution)
+		}
+	}
+
+	// the same paths against a higher risk free rate look worse, the calmar ratio and ulcer index do not use it
+	higher := run(0.05)
+	for i, p := range higher {
+		switch p.Name {
+		case "sharpe", "sortino", "omega":
+			if p.Mean >= base[i].Mean {
+				t.Errorf("%s: expected a lower mean against a higher risk free rate, got %.6f and %.6f", p.Name, p.Mean, base[i].Mean)
+			}
+		default:
+			if p.MetricDistribution != base[i].MetricDistribution {
+				t.Errorf("%s: expected no change with the risk free rate, got %+v and %+v", p.Name, p.MetricDistribution, base[i].MetricDistribution)
+			}
+		}
+	}
+
+	for _, riskFreeRate := range []float64{-1, 1.5} {
+		if err := validateRiskFreeRate(riskFreeRate); err == nil {
+			t.Errorf("Expected an error for a risk free rate of %.2f", riskFreeRate)
+		}
+	}
+}
+
+// TestRunMonteCarloSimulation_PerformanceWithCashFlows checks a contribution schedule leaves the ratios of every path as they were
+func TestRunMonteCarloSimulation_PerformanceWithCashFlows(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*5)
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Monthly,
+		SimulationDuration:   36,
+		Iterations:           200,
+		Seed:                 42,
+		RiskFreeRate:         0.02,
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+	sr.Rebalance = RebalancePolicy{Policy: sm.ContinuousRebalance} // contributions go in at target, so the mix stays the same either way
+
+	sc := &ServiceContext{Context: context.Background()}
+	baseline, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	// a contribution every quarter for the first two years
+	settings.CashFlows = []sm.CashFlow{{Type: sm.FixedCashFlow, Amount: 25, Frequency: 3, EndPeriod: 24}}
+	contributing, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	for sim, path := range contributing {
+		for i, m := range performanceMetrics {
+			got, expected := path.Performance[i], baseline[sim].Performance[i]
+			if math.IsNaN(got) != math.IsNaN(expected) || (!math.IsNaN(got) && math.Abs(got-expected) > 1e-9) {
+				t.Fatalf("path %d: expected the same %s with contributions, got %.9f and %.9f", sim, m.name, got, expected)
+			}
+		}
+	}
+}
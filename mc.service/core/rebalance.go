@@ -0,0 +1,136 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	dm "mc.data/models"
+	sm "mc.service/models"
+)
+
+// RebalancePolicy is how a path gets back to the scenario's target weights, the zero value rebalances every period
+type RebalancePolicy struct {
+	Policy    string
+	Frequency int     // periods between calendar rebalances
+	Threshold float64 // absolute weight drift that triggers a threshold rebalance
+}
+
+// getRebalancePolicy reads the policy stored on the scenario
+func getRebalancePolicy(config dm.ScenarioConfiguration) (RebalancePolicy, error) {
+	policy := RebalancePolicy{
+		Policy:    sm.GetRebalancePolicy(config.RebalancePolicy),
+		Frequency: config.RebalanceFrequency,
+		Threshold: config.RebalanceThreshold,
+	}
+
+	if err := validateRebalancePolicy(policy.Policy, policy.Frequency, policy.Threshold); err != nil {
+		return RebalancePolicy{}, err
+	}
+
+	return policy, nil
+}
+
+func validateRebalancePolicy(policy string, frequency int, threshold float64) error {
+	switch policy {
+	case sm.ContinuousRebalance, sm.BuyAndHold:
+		return nil
+	case sm.CalendarRebalance:
+		if frequency < 1 {
+			return fmt.Errorf("calendar rebalancing needs a frequency of at least 1 period, got %d", frequency)
+		}
+		return nil
+	case sm.ThresholdRebalance:
+		if threshold <= 0 || threshold >= 1 {
+			return fmt.Errorf("rebalance threshold must be between 0 and 1, got %.4f", threshold)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown rebalance policy %q", policy)
+	}
+}
+
+// Portfolio tracks what is held in each asset along a path, each worker keeps its own.
+// target weights that sum to less than 1 leave the rest in cash, which earns nothing.
+type Portfolio struct {
+	policy     RebalancePolicy
+	target     []float64
+	Holdings   []float64 // value held in each asset
+	Cash       float64   // value not allocated to any asset
+	Rebalances int       // number of times the path was rebalanced
+}
+
+func NewPortfolio(target []float64, policy RebalancePolicy) *Portfolio {
+	return &Portfolio{
+		policy:   policy,
+		target:   target,
+		Holdings: make([]float64, len(target)),
+	}
+}
+
+// Reset invests value at the target weights for the start of a new path
+func (p *Portfolio) Reset(value float64) {
+	p.rebalance(value)
+	p.Rebalances = 0
+}
+
+// Step grows each holding by its log return for the period, rebalances if the policy calls for it and returns the portfolio value
+func (p *Portfolio) Step(period int, logReturns []float64) float64 {
+	value := p.Cash
+	for i := range p.Holdings {
+		p.Holdings[i] *= math.Exp(logReturns[i])
+		value += p.Holdings[i]
+	}
+
+	if p.shouldRebalance(period, value) {
+		p.rebalance(value)
+		p.Rebalances++
+	}
+
+	return value
+}
+
+// Weights are the current weights of the holdings, cash is the remainder
+func (p *Portfolio) Weights() []float64 {
+	value := p.Cash
+	for _, h := range p.Holdings {
+		value += h
+	}
+
+	weights := slices.Clone(p.Holdings)
+	if value <= 0 {
+		return weights // nothing is held once a path runs out of money
+	}
+
+	for i := range weights {
+		weights[i] /= value
+	}
+
+	return weights
+}
+
+func (p *Portfolio) shouldRebalance(period int, value float64) bool {
+	switch p.policy.Policy {
+	case sm.BuyAndHold:
+		return false
+	case sm.CalendarRebalance:
+		return (period+1)%p.policy.Frequency == 0
+	case sm.ThresholdRebalance:
+		for i, h := range p.Holdings {
+			if math.Abs(h/value-p.target[i]) > p.policy.Threshold {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *Portfolio) rebalance(value float64) {
+	p.Cash = value
+	for i, w := range p.target {
+		p.Holdings[i] = w * value
+		p.Cash -= p.Holdings[i]
+	}
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	sm "mc.service/models"
+)
+
+// TestPortfolioRebalancePolicies steps a two asset portfolio through the same returns under each policy
+func TestPortfolioRebalancePolicies(t *testing.T) {
+	target := []float64{0.6, 0.4}
+	returns := [][]float64{{0.10, -0.05}, {0.08, -0.02}, {-0.03, 0.01}, {0.12, 0.00}, {0.05, -0.04}, {0.02, 0.03}}
+
+	// buy and hold, each asset just compounds on its own
+	portfolio := NewPortfolio(target, RebalancePolicy{Policy: sm.BuyAndHold})
+	portfolio.Reset(100)
+	var value float64
+	for period, r := range returns {
+		value = portfolio.Step(period, r)
+	}
+
+	sums := []float64{0, 0}
+	for _, r := range returns {
+		sums[0] += r[0]
+		sums[1] += r[1]
+	}
+	expected := 60*math.Exp(sums[0]) + 40*math.Exp(sums[1])
+	if math.Abs(value-expected) > 1e-9 {
+		t.Errorf("Buy and hold: expected value %.6f, got %.6f", expected, value)
+	}
+	if portfolio.Rebalances != 0 {
+		t.Errorf("Buy and hold: expected no rebalances, got %d", portfolio.Rebalances)
+	}
+	if weights := portfolio.Weights(); weights[0] <= target[0] {
+		t.Errorf("Buy and hold: expected the winning asset to drift above target, got %.4f", weights[0])
+	}
+
+	// continuous, every period grows by the weighted simple returns
+	portfolio = NewPortfolio(target, RebalancePolicy{Policy: sm.ContinuousRebalance})
+	portfolio.Reset(100)
+	expected = 100.0
+	for period, r := range returns {
+		value = portfolio.Step(period, r)
+		expected *= target[0]*math.Exp(r[0]) + target[1]*math.Exp(r[1])
+	}
+	if math.Abs(value-expected) > 1e-9 {
+		t.Errorf("Continuous: expected value %.6f, got %.6f", expected, value)
+	}
+	if weights := portfolio.Weights(); math.Abs(weights[0]-target[0]) > 1e-12 {
+		t.Errorf("Continuous: expected weights back at target, got %v", weights)
+	}
+
+	// calendar, every 4 periods only rebalances once over 6 periods
+	portfolio = NewPortfolio(target, RebalancePolicy{Policy: sm.CalendarRebalance, Frequency: 4})
+	portfolio.Reset(100)
+	for period, r := range returns {
+		portfolio.Step(period, r)
+	}
+	if portfolio.Rebalances != 1 {
+		t.Errorf("Calendar: expected 1 rebalance, got %d", portfolio.Rebalances)
+	}
+
+	// threshold, first period drifts 0.6 -> ~0.63 which is inside 5% but the drift keeps building
+	portfolio = NewPortfolio(target, RebalancePolicy{Policy: sm.ThresholdRebalance, Threshold: 0.05})
+	portfolio.Reset(100)
+	portfolio.Step(0, returns[0])
+	if portfolio.Rebalances != 0 {
+		t.Errorf("Threshold: expected no rebalance inside the band, got %d", portfolio.Rebalances)
+	}
+	portfolio.Step(1, returns[1])
+	if portfolio.Rebalances != 1 {
+		t.Errorf("Threshold: expected a rebalance once outside the band, got %d", portfolio.Rebalances)
+	}
+
+	portfolio.Reset(100)
+	if portfolio.Rebalances != 0 || portfolio.Holdings[0] != 60 {
+		t.Errorf("Expected reset to invest at target with no rebalances, got %v and %d rebalances", portfolio.Holdings, portfolio.Rebalances)
+	}
+
+	invalid := []RebalancePolicy{
+		{Policy: "monthly"},
+		{Policy: sm.CalendarRebalance},
+		{Policy: sm.ThresholdRebalance, Threshold: 1.5},
+	}
+	for _, p := range invalid {
+		if err := validateRebalancePolicy(p.Policy, p.Frequency, p.Threshold); err == nil {
+			t.Errorf("Expected an error for policy %+v", p)
+		}
+	}
+}
+
+// TestRunMonteCarloSimulation_BuyAndHoldDrifts makes sure the policy reaches the paths and weights drift without rebalancing
+func TestRunMonteCarloSimulation_BuyAndHoldDrifts(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*5)[:2]
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Weekly,
+		SimulationDuration:   260,
+		Iterations:           1_000,
+		Seed:                 42,
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	sc := &ServiceContext{Context: context.Background()}
+	for _, policy := range []RebalancePolicy{{Policy: sm.BuyAndHold}, {Policy: sm.CalendarRebalance, Frequency: 13}} {
+		sr.Rebalance = policy
+		res, err := sc.RunMonteCarloSimulation(sr, settings)
+		if err != nil {
+			t.Fatalf("RunMonteCarloSimulation: %v", err)
+		}
+
+		summary := calculateRebalanceSummary(aggregateResults(res, sr, settings), policy)
+		expectedRebalances := 0.0
+		if policy.Policy == sm.CalendarRebalance {
+			expectedRebalances = float64(settings.SimulationDuration / policy.Frequency)
+		}
+
+		if summary.AverageRebalances != expectedRebalances {
+			t.Errorf("%s: expected %.0f rebalances per path, got %.2f", policy.Policy, expectedRebalances, summary.AverageRebalances)
+		}
+
+		drift := 0.0
+		for _, r := range res {
+			drift += math.Abs(r.FinalWeights[0] - sr.AssetWeight[0])
+		}
+		t.Logf("%s: average final weights %v, average drift %.4f", policy.Policy, summary.AverageFinalWeights, drift/float64(len(res)))
+
+		if policy.Policy == sm.BuyAndHold && drift == 0 {
+			t.Errorf("Expected buy and hold weights to drift from target")
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distmv"
+
+	sm "mc.service/models"
+)
+
+const (
+	MaxRegimeIterations   = 500
+	RegimeTolerance       = 1e-6  // change in log likelihood to stop em
+	RegimeCovarianceRidge = 1e-10 // added to the diagonal so a regime covariance always factorizes
+)
+
+// Regime is a single state of the markov chain, each regime has its own mean vector and covariance
+type Regime struct {
+	Label         string
+	Mu            []float64 // annualized
+	Sigma         []float64 // annualized
+	CorrMatrix    *mat.SymDense
+	CholeskyCorrL *mat.TriDense
+}
+
+// RegimeModel is a markov regime switching model, the transition matrix governs switching per simulated period
+type RegimeModel struct {
+	Regimes                []Regime
+	TransitionMatrix       [][]float64 // row i is the probability of moving from regime i to each regime
+	StationaryDistribution []float64   // long run fraction of time in each regime, used to draw the starting regime
+}
+
+// getRegimeModel either builds the manually supplied regimes or estimates them from the return history
+func getRegimeModel(seriesReturns []*SeriesReturns, settings sm.SimulationRequestSettings) (*RegimeModel, error) {
+	if settings.DistType == sm.HistoricalBootstrap {
+		return nil, fmt.Errorf("regime switching is not supported with historical bootstrap")
+	}
+
+	if settings.VolatilityModel == sm.Garch {
+		return nil, fmt.Errorf("regime switching is not supported with garch volatility")
+	}
+
+	nRegimes := settings.GetNumberOfRegimes()
+	if nRegimes < 2 {
+		return nil, fmt.Errorf("regime switching requires at least 2 regimes, got %d", nRegimes)
+	}
+
+	if len(settings.Regimes.Regimes) > 0 {
+		return NewRegimeModel(settings.Regimes.Regimes, settings.Regimes.TransitionMatrix, len(seriesReturns))
+	}
+
+	// the estimated transition matrix is per period of the history, so it can only be stepped in that unit of time
+	returns := make([][]float64, len(seriesReturns))
+	for i, r := range seriesReturns {
+		if r.AnnualizationFactor != settings.SimulationUnitOfTime {
+			return nil, fmt.Errorf("estimated regimes require the simulation unit of time (%s) to match the return history (%s)",
+				sm.ConvertFrequencyToString(settings.SimulationUnitOfTime), sm.ConvertFrequencyToString(r.AnnualizationFactor))
+		}
+		returns[i] = r.Returns
+	}
+
+	return EstimateRegimeModel(returns, nRegimes, settings.SimulationUnitOfTime)
+}
+
+// NewRegimeModel builds a regime model from manually supplied regimes, mu and covariance are annualized
+func NewRegimeModel(params []sm.RegimeParameters, transitionMatrix [][]float64, nAssets int) (*RegimeModel, error) {
+	nRegimes := len(params)
+	if err := validateTransitionMatrix(transitionMatrix, nRegimes); err != nil {
+		return nil, err
+	}
+
+	regimes := make([]Regime, nRegimes)
+	for k, p := range params {
+		if len(p.Mu) != nAssets || len(p.Covariance) != nAssets {
+			return nil, fmt.Errorf("regime %d must have a mu and covariance row for each of the %d assets", k+1, nAssets)
+		}
+
+		covMatrix := mat.NewSymDense(nAssets, nil)
+		for i, row := range p.Covariance {
+			if len(row) != nAssets {
+				return nil, fmt.Errorf("regime %d covariance must be %d x %d", k+1, nAssets, nAssets)
+			}
+			for j := range i + 1 {
+				covMatrix.SetSym(i, j, row[j])
+			}
+		}
+
+		regime, err := newRegime(p.Label, p.Mu, covMatrix, 1)
+		if err != nil {
+			return nil, fmt.Errorf("regime %d: %w", k+1, err)
+		}
+		regimes[k] = regime
+	}
+
+	return &RegimeModel{
+		Regimes:                regimes,
+		TransitionMatrix:       transitionMatrix,
+		StationaryDistribution: getStationaryDistribution(transitionMatrix),
+	}, nil
+}
+
+// EstimateRegimeModel fits a gaussian hidden markov model with em (baum-welch), the forward pass is the hamilton filter.
+// returns are [asset][observation] in chronological order and regimes are sorted from calmest to most volatile.
+func EstimateRegimeModel(returns [][]float64, nRegimes int, annualizationFactor int) (*RegimeModel, error) {
+	nAssets := len(returns)
+	nObservations := len(returns[0])
+	if nObservations < nRegimes*(nAssets+1)*10 {
+		return nil, fmt.Errorf("not enough history to estimate %d regimes for %d assets (%d observations)", nRegimes, nAssets, nObservations)
+	}
+
+	observations := make([][]float64, nObservations)
+	for t := range nObservations {
+		observations[t] = make([]float64, nAssets)
+		for i := range nAssets {
+			observations[t][i] = returns[i][t]
+		}
+	}
+
+	means, covs := initializeRegimes(observations, nRegimes)
+	transition := make([][]float64, nRegimes)
+	for j := range nRegimes {
+		transition[j] = make([]float64, nRegimes)
+		for k := range nRegimes {
+			transition[j][k] = 0.1 / float64(nRegimes-1)
+		}
+		transition[j][j] = 0.9
+	}
+
+	initial := make([]float64, nRegimes)
+	for k := range initial {
+		initial[k] = 1 / float64(nRegimes)
+	}
+
+	logDensity := make([][]float64, nObservations)
+	filtered := make([][]float64, nObservations) // hamilton filter probabilities
+	smoothed := make([][]float64, nObservations)
+	backward := make([][]float64, nObservations)
+	scale := make([]float64, nObservations)
+	for t := range nObservations {
+		logDensity[t] = make([]float64, nRegimes)
+		filtered[t] = make([]float64, nRegimes)
+		smoothed[t] = make([]float64, nRegimes)
+		backward[t] = make([]float64, nRegimes)
+	}
+
+	previousLogLikelihood := math.Inf(-1)
+	for range MaxRegimeIterations {
+		// e step, regime densities for every observation
+		for k := range nRegimes {
+			dist, ok := distmv.NewNormal(means[k], covs[k], nil)
+			if !ok {
+				return nil, fmt.Errorf("regime %d covariance is not positive definite", k+1)
+			}
+			for t, x := range observations {
+				logDensity[t][k] = dist.LogProb(x)
+			}
+		}
+
+		// forward pass, densities are shifted by the max per observation so nothing underflows, the shift is added back to the likelihood
+		logLikelihood := 0.0
+		for t := range nObservations {
+			shift := slices.Max(logDensity[t])
+			total := 0.0
+			for k := range nRegimes {
+				prior := initial[k]
+				if t > 0 {
+					prior = 0
+					for j := range nRegimes {
+						prior += filtered[t-1][j] * transition[j][k]
+					}
+				}
+				filtered[t][k] = prior * math.Exp(logDensity[t][k]-shift)
+				total += filtered[t][k]
+			}
+
+			for k := range nRegimes {
+				filtered[t][k] /= total
+			}
+			scale[t] = total
+			logLikelihood += math.Log(total) + shift
+		}
+
+		// backward pass (kim smoother equivalent), using the same scaling as the forward pass
+		for k := range nRegimes {
+			backward[nObservations-1][k] = 1
+		}
+		for t := nObservations - 2; t >= 0; t-- {
+			shift := slices.Max(logDensity[t+1])
+			for j := range nRegimes {
+				backward[t][j] = 0
+				for k := range nRegimes {
+					backward[t][j] += transition[j][k] * math.Exp(logDensity[t+1][k]-shift) * backward[t+1][k]
+				}
+				backward[t][j] /= scale[t+1]
+			}
+		}
+
+		for t := range nObservations {
+			total := 0.0
+			for k := range nRegimes {
+				smoothed[t][k] = filtered[t][k] * backward[t][k]
+				total += smoothed[t][k]
+			}
+			for k := range nRegimes {
+				smoothed[t][k] /= total
+			}
+		}
+
+		// m step, transition matrix from the expected number of switches
+		expectedTransitions := make([][]float64, nRegimes)
+		for j := range nRegimes {
+			expectedTransitions[j] = make([]float64, nRegimes)
+		}
+		for t := range nObservations - 1 {
+			shift := slices.Max(logDensity[t+1])
+			for j := range nRegimes {
+				for k := range nRegimes {
+					expectedTransitions[j][k] += filtered[t][j] * transition[j][k] * math.Exp(logDensity[t+1][k]-shift) * backward[t+1][k] / scale[t+1]
+				}
+			}
+		}
+
+		for j := range nRegimes {
+			rowTotal := 0.0
+			for k := range nRegimes {
+				rowTotal += expectedTransitions[j][k]
+			}
+			for k := range nRegimes {
+				transition[j][k] = expectedTransitions[j][k] / rowTotal
+			}
+		}
+
+		copy(initial, smoothed[0])
+
+		// m step, weighted mean and covariance per regime
+		for k := range nRegimes {
+			weights := make([]float64, nObservations)
+			for t := range nObservations {
+				weights[t] = smoothed[t][k]
+			}
+			means[k], covs[k] = weightedMeanAndCovariance(observations, weights)
+		}
+
+		if math.Abs(logLikelihood-previousLogLikelihood) < RegimeTolerance {
+			break
+		}
+		previousLogLikelihood = logLikelihood
+	}
+
+	// sort calmest first so labels mean something
+	order := make([]int, nRegimes)
+	for k := range order {
+		order[k] = k
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Compare(mat.Trace(covs[a]), mat.Trace(covs[b]))
+	})
+
+	regimes := make([]Regime, nRegimes)
+	sortedTransition := make([][]float64, nRegimes)
+	for newIdx, oldIdx := range order {
+		mu := make([]float64, nAssets)
+		for i := range nAssets {
+			mu[i] = means[oldIdx][i] * float64(annualizationFactor)
+		}
+
+		regime, err := newRegime(getRegimeLabel(newIdx, nRegimes), mu, covs[oldIdx], annualizationFactor)
+		if err != nil {
+			return nil, err
+		}
+		regimes[newIdx] = regime
+
+		sortedTransition[newIdx] = make([]float64, nRegimes)
+		for newTo, oldTo := range order {
+			sortedTransition[newIdx][newTo] = transition[oldIdx][oldTo]
+		}
+	}
+
+	return &RegimeModel{
+		Regimes:                regimes,
+		TransitionMatrix:       sortedTransition,
+		StationaryDistribution: getStationaryDistribution(sortedTransition),
+	}, nil
+}
+
+// newRegime builds the regime from an annualized mu and a covariance in the units of the annualization factor
+func newRegime(label string, mu []float64, covMatrix *mat.SymDense, annualizationFactor int) (Regime, error) {
+	n := len(mu)
+	sigma := make([]float64, n)
+	for i := range n {
+		sigma[i] = math.Sqrt(covMatrix.At(i, i) * float64(annualizationFactor))
+	}
+
+	corrMatrix := GetCorrelationMatrix(covMatrix)
+	choleskyCorrL, err := GetCholeskyDecomposition(corrMatrix)
+	if err != nil {
+		return Regime{}, fmt.Errorf("failed to compute regime correlation Cholesky: %w", err)
+	}
+
+	return Regime{
+		Label:         label,
+		Mu:            mu,
+		Sigma:         sigma,
+		CorrMatrix:    corrMatrix,
+		CholeskyCorrL: choleskyCorrL,
+	}, nil
+}
+
+// initializeRegimes splits the observations into groups by the size of the move, so the starting regimes go from calm to volatile
+func initializeRegimes(observations [][]float64, nRegimes int) ([][]float64, []*mat.SymDense) {
+	nObservations := len(observations)
+	magnitude := make([]float64, nObservations)
+	for t, x := range observations {
+		for _, v := range x {
+			magnitude[t] += v * v
+		}
+	}
+
+	sortedMagnitude := slices.Clone(magnitude)
+	slices.Sort(sortedMagnitude)
+
+	means := make([][]float64, nRegimes)
+	covs := make([]*mat.SymDense, nRegimes)
+	for k := range nRegimes {
+		lower := stat.Quantile(float64(k)/float64(nRegimes), stat.Empirical, sortedMagnitude, nil)
+		upper := stat.Quantile(float64(k+1)/float64(nRegimes), stat.Empirical, sortedMagnitude, nil)
+
+		weights := make([]float64, nObservations)
+		for t, m := range magnitude {
+			if (k == 0 || m > lower) && m <= upper {
+				weights[t] = 1
+			}
+		}
+		means[k], covs[k] = weightedMeanAndCovariance(observations, weights)
+	}
+
+	return means, covs
+}
+
+func weightedMeanAndCovariance(observations [][]float64, weights []float64) ([]float64, *mat.SymDense) {
+	nAssets := len(observations[0])
+	totalWeight := 0.0
+	mean := make([]float64, nAssets)
+	for t, x := range observations {
+		totalWeight += weights[t]
+		for i := range nAssets {
+			mean[i] += weights[t] * x[i]
+		}
+	}
+	for i := range nAssets {
+		mean[i] /= totalWeight
+	}
+
+	cov := mat.NewSymDense(nAssets, nil)
+	for i := range nAssets {
+		for j := range i + 1 {
+			total := 0.0
+			for t, x := range observations {
+				total += weights[t] * (x[i] - mean[i]) * (x[j] - mean[j])
+			}
+			cov.SetSym(i, j, total/totalWeight)
+		}
+		cov.SetSym(i, i, cov.At(i, i)+RegimeCovarianceRidge)
+	}
+
+	return mean, cov
+}
+
+func validateTransitionMatrix(transitionMatrix [][]float64, nRegimes int) error {
+	if len(transitionMatrix) != nRegimes {
+		return fmt.Errorf("transition matrix must be %d x %d", nRegimes, nRegimes)
+	}
+
+	for j, row := range transitionMatrix {
+		if len(row) != nRegimes {
+			return fmt.Errorf("transition matrix must be %d x %d", nRegimes, nRegimes)
+		}
+
+		rowTotal := 0.0
+		for _, p := range row {
+			if p < 0 {
+				return fmt.Errorf("transition probabilities must not be negative")
+			}
+			rowTotal += p
+		}
+
+		if math.Abs(rowTotal-1.0) > 1e-6 {
+			return fmt.Errorf("transition matrix row %d must sum to 1.0, got %.6f", j+1, rowTotal)
+		}
+	}
+
+	return nil
+}
+
+// getStationaryDistribution finds the long run regime probabilities by stepping the chain until it settles
+func getStationaryDistribution(transitionMatrix [][]float64) []float64 {
+	n := len(transitionMatrix)
+	dist := make([]float64, n)
+	for k := range dist {
+		dist[k] = 1 / float64(n)
+	}
+
+	next := make([]float64, n)
+	for range 10_000 {
+		for k := range n {
+			next[k] = 0
+			for j := range n {
+				next[k] += dist[j] * transitionMatrix[j][k]
+			}
+		}
+
+		settled := true
+		for k := range n {
+			if math.Abs(next[k]-dist[k]) > 1e-12 {
+				settled = false
+			}
+		}
+		copy(dist, next)
+
+		if settled {
+			break
+		}
+	}
+
+	return dist
+}
+
+func getRegimeLabel(idx, nRegimes int) string {
+	if nRegimes == 2 {
+		return []string{"calm", "crisis"}[idx]
+	}
+	return fmt.Sprintf("regime %d", idx+1)
+}
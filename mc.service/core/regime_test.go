@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"gonum.org/v1/gonum/stat/distuv"
+	sm "mc.service/models"
+)
+
+// TestEstimateRegimeModelRecoversRegimes simulates a known calm/crisis chain and makes sure em finds it again
+func TestEstimateRegimeModelRecoversRegimes(t *testing.T) {
+	sigmas := []float64{0.10, 0.40}
+	transition := [][]float64{{0.98, 0.02}, {0.05, 0.95}}
+	returns := generateMockRegimeReturns(t, sigmas, transition, 5_000)
+
+	model, err := EstimateRegimeModel(returns, 2, sm.Daily)
+	if err != nil {
+		t.Fatalf("EstimateRegimeModel: %v", err)
+	}
+
+	for k, regime := range model.Regimes {
+		t.Logf("%s - sigma: %.4f, %.4f, transition: %v", regime.Label, regime.Sigma[0], regime.Sigma[1], model.TransitionMatrix[k])
+
+		for i := range regime.Sigma {
+			if math.Abs(regime.Sigma[i]-sigmas[k]) > 0.03 {
+				t.Errorf("%s asset %d: expected sigma ~%.4f, got %.4f", regime.Label, i, sigmas[k], regime.Sigma[i])
+			}
+		}
+
+		if math.Abs(model.TransitionMatrix[k][k]-transition[k][k]) > 0.02 {
+			t.Errorf("%s: expected staying probability ~%.4f, got %.4f", regime.Label, transition[k][k], model.TransitionMatrix[k][k])
+		}
+	}
+
+	if model.Regimes[0].Label != "calm" || model.Regimes[1].Label != "crisis" {
+		t.Errorf("Expected regimes sorted calm then crisis, got %s then %s", model.Regimes[0].Label, model.Regimes[1].Label)
+	}
+}
+
+// TestRunMonteCarloSimulation_RegimeSwitching runs manual regimes and checks time spent lines up with the stationary distribution
+func TestRunMonteCarloSimulation_RegimeSwitching(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*10)[:2]
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Weekly,
+		SimulationDuration:   520,
+		Iterations:           2_000,
+		Seed:                 42,
+		Regimes: &sm.RegimeSettings{
+			Regimes: []sm.RegimeParameters{
+				{Label: "calm", Mu: []float64{0.08, 0.04}, Covariance: [][]float64{{0.0225, 0.003}, {0.003, 0.0025}}},
+				{Label: "crisis", Mu: []float64{-0.20, 0.02}, Covariance: [][]float64{{0.16, 0.02}, {0.02, 0.01}}},
+			},
+			TransitionMatrix: [][]float64{{0.98, 0.02}, {0.05, 0.95}},
+		},
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	stationary := sr.RegimeModel.StationaryDistribution
+	if math.Abs(stationary[0]-5.0/7.0) > 1e-6 {
+		t.Errorf("Expected stationary calm probability %.4f, got %.4f", 5.0/7.0, stationary[0])
+	}
+
+	sc := &ServiceContext{Context: context.Background()}
+	res, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	for i, r := range res {
+		if len(r.RegimePeriods) != 2 || r.RegimePeriods[0]+r.RegimePeriods[1] != settings.SimulationDuration {
+			t.Fatalf("result[%d]: expected regime periods to sum to %d, got %v", i, settings.SimulationDuration, r.RegimePeriods)
+		}
+	}
+
+	summaries := calculateRegimeSummaries(aggregateResults(res, sr, settings), sr.RegimeModel)
+	for k, summary := range summaries {
+		if math.Abs(summary.AverageTimeSpent-stationary[k]) > 0.02 {
+			t.Errorf("%s: expected ~%.4f of time spent, got %.4f", summary.Label, stationary[k], summary.AverageTimeSpent)
+		}
+	}
+
+	settings.Regimes.TransitionMatrix = [][]float64{{0.9, 0.2}, {0.05, 0.95}}
+	if _, err := GetStatisticalResources(seriesReturns, settings); err == nil {
+		t.Error("Expected an error when transition rows do not sum to 1")
+	}
+}
+
+// Helper: Generate daily returns for two assets that switch between regimes with the given annualized volatilities
+func generateMockRegimeReturns(t *testing.T, sigmas []float64, transition [][]float64, n int) [][]float64 {
+	t.Helper()
+
+	src := rand.NewPCG(11, 0)
+	rng := rand.New(src)
+	normalDist := distuv.Normal{Mu: 0, Sigma: 1, Src: src}
+
+	returns := [][]float64{make([]float64, n), make([]float64, n)}
+	regime := 0
+	for i := range n {
+		sigma := sigmas[regime] / math.Sqrt(sm.Daily)
+		z1 := normalDist.Rand()
+		z2 := 0.5*z1 + math.Sqrt(1-0.25)*normalDist.Rand()
+		returns[0][i] = sigma * z1
+		returns[1][i] = sigma * z2
+
+		if rng.Float64() >= transition[regime][regime] {
+			regime = 1 - regime
+		}
+	}
+
+	return returns
+}
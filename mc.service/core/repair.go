@@ -0,0 +1,141 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+
+	sm "mc.service/models"
+)
+
+const (
+	RepairEigenvalueFloor = 1e-8  // smallest eigenvalue a repaired correlation matrix keeps, so the cholesky does not sit on the edge
+	MaxHighamIterations   = 1_000 // alternating projections usually settle in well under a hundred
+	highamTolerance       = 1e-10 // frobenius change between iterations that counts as converged
+)
+
+// repairCorrelationMatrix makes the correlation matrix positive definite when the settings ask for it,
+// the summary reports how far it moved so a repaired run is not mistaken for the raw estimate
+func repairCorrelationMatrix(corrMatrix *mat.SymDense, settings sm.SimulationRequestSettings) (*mat.SymDense, sm.CorrelationRepairSummary, error) {
+	summary := sm.CorrelationRepairSummary{MinEigenvalueBefore: getMinEigenvalue(corrMatrix)}
+	summary.MinEigenvalueAfter = summary.MinEigenvalueBefore
+
+	_, err := GetCholeskyDecomposition(corrMatrix)
+	switch settings.CorrelationRepair {
+	case sm.RepairWhenNeeded:
+		if err == nil {
+			return corrMatrix, summary, nil
+		}
+	case sm.AlwaysRepair:
+	case sm.NeverRepair:
+		return corrMatrix, summary, err
+	default:
+		return nil, summary, fmt.Errorf("unknown correlation repair %d", settings.CorrelationRepair)
+	}
+
+	var repaired *mat.SymDense
+	switch settings.RepairMethod {
+	case sm.HighamRepair:
+		repaired, summary.Iterations = getNearestCorrelationMatrix(corrMatrix)
+	case sm.EigenvalueClippingRepair:
+		repaired = clipEigenvalues(corrMatrix)
+	default:
+		return nil, summary, fmt.Errorf("unknown repair method %d", settings.RepairMethod)
+	}
+
+	summary.Repaired = true
+	summary.Method = sm.RepairMethodToString(settings.RepairMethod)
+	summary.MinEigenvalueAfter = getMinEigenvalue(repaired)
+	summary.FrobeniusDistance = getFrobeniusDistance(corrMatrix, repaired)
+
+	log.Printf("Repaired correlation matrix with %s: min eigenvalue %.2e -> %.2e, frobenius distance %.4f",
+		summary.Method, summary.MinEigenvalueBefore, summary.MinEigenvalueAfter, summary.FrobeniusDistance)
+
+	return repaired, summary, nil
+}
+
+// getNearestCorrelationMatrix is higham's (2002) alternating projections with dykstra's correction, between the positive semidefinite
+// matrices and the unit diagonal matrices. the result is the nearest correlation matrix in the frobenius norm, floored so it stays definite.
+func getNearestCorrelationMatrix(corrMatrix *mat.SymDense) (*mat.SymDense, int) {
+	n := corrMatrix.SymmetricDim()
+	y := mat.NewSymDense(n, nil)
+	y.CopySym(corrMatrix)
+	correction := mat.NewSymDense(n, nil)
+
+	iterations := 0
+	for iterations < MaxHighamIterations {
+		iterations++
+
+		r := subtractSym(y, correction)
+		x := projectPositiveSemidefinite(r, 0)
+		correction = subtractSym(x, r)
+
+		// the unit diagonal set is flat, so its projection needs no correction
+		previous := y
+		y = mat.NewSymDense(n, nil)
+		y.CopySym(x)
+		for i := range n {
+			y.SetSym(i, i, 1)
+		}
+
+		if getFrobeniusDistance(previous, y) < highamTolerance {
+			break
+		}
+	}
+
+	// the projections only reach the semidefinite boundary, the floor pushes the result inside it
+	return clipEigenvalues(y), iterations
+}
+
+// clipEigenvalues raises eigenvalues below the floor to it, then rescales back to a unit diagonal (which keeps it definite)
+func clipEigenvalues(corrMatrix *mat.SymDense) *mat.SymDense {
+	return GetCorrelationMatrix(projectPositiveSemidefinite(corrMatrix, RepairEigenvalueFloor))
+}
+
+// projectPositiveSemidefinite rebuilds the matrix from its eigen decomposition with every eigenvalue at least the floor
+func projectPositiveSemidefinite(m *mat.SymDense, floor float64) *mat.SymDense {
+	var eigen mat.EigenSym
+	eigen.Factorize(m, true)
+	values := eigen.Values(nil)
+	var vectors mat.Dense
+	eigen.VectorsTo(&vectors)
+
+	n := len(values)
+	res := mat.NewSymDense(n, nil)
+	for i := range n {
+		for j := range i + 1 {
+			v := 0.0
+			for k, value := range values {
+				v += vectors.At(i, k) * math.Max(value, floor) * vectors.At(j, k)
+			}
+			res.SetSym(i, j, v)
+		}
+	}
+
+	return res
+}
+
+func getMinEigenvalue(m *mat.SymDense) float64 {
+	var eigen mat.EigenSym
+	if ok := eigen.Factorize(m, false); !ok {
+		return math.NaN()
+	}
+	return eigen.Values(nil)[0] // ascending
+}
+
+func getFrobeniusDistance(a, b *mat.SymDense) float64 {
+	return mat.Norm(subtractSym(a, b), 2)
+}
+
+func subtractSym(a, b *mat.SymDense) *mat.SymDense {
+	n := a.SymmetricDim()
+	res := mat.NewSymDense(n, nil)
+	for i := range n {
+		for j := range i + 1 {
+			res.SetSym(i, j, a.At(i, j)-b.At(i, j))
+		}
+	}
+	return res
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+
+	sm "mc.service/models"
+)
+
+// TestRepairCorrelationMatrix_Higham checks the worked example from higham (2002), which has a known nearest correlation matrix
+func TestRepairCorrelationMatrix_Higham(t *testing.T) {
+	corrMatrix := mat.NewSymDense(3, []float64{
+		1, 1, 0,
+		1, 1, 1,
+		0, 1, 1,
+	})
+
+	repaired, summary, err := repairCorrelationMatrix(corrMatrix, sm.SimulationRequestSettings{})
+	if err != nil {
+		t.Fatalf("repairCorrelationMatrix: %v", err)
+	}
+
+	t.Logf("Repair: %+v", summary)
+	if !summary.Repaired || summary.Method != "higham" {
+		t.Fatalf("Expected a higham repair, got %+v", summary)
+	}
+	if summary.MinEigenvalueBefore >= 0 || summary.MinEigenvalueAfter < RepairEigenvalueFloor/2 {
+		t.Errorf("Expected the min eigenvalue to go from negative to the floor, got %.2e -> %.2e", summary.MinEigenvalueBefore, summary.MinEigenvalueAfter)
+	}
+
+	expected := mat.NewSymDense(3, []float64{
+		1, 0.7607, 0.1573,
+		0.7607, 1, 0.7607,
+		0.1573, 0.7607, 1,
+	})
+	for i := range 3 {
+		for j := range 3 {
+			if math.Abs(repaired.At(i, j)-expected.At(i, j)) > 1e-4 {
+				t.Errorf("(%d, %d): expected %.4f, got %.4f", i, j, expected.At(i, j), repaired.At(i, j))
+			}
+		}
+	}
+
+	if _, err := GetCholeskyDecomposition(repaired); err != nil {
+		t.Errorf("Expected the repaired matrix to factorize: %v", err)
+	}
+
+	// clipping is also valid but not the nearest
+	settings := sm.SimulationRequestSettings{RepairMethod: sm.EigenvalueClippingRepair}
+	clipped, clippedSummary, err := repairCorrelationMatrix(corrMatrix, settings)
+	if err != nil {
+		t.Fatalf("repairCorrelationMatrix: %v", err)
+	}
+	if _, err := GetCholeskyDecomposition(clipped); err != nil {
+		t.Errorf("Expected the clipped matrix to factorize: %v", err)
+	}
+	for i := range 3 {
+		if clipped.At(i, i) != 1 {
+			t.Errorf("Expected a unit diagonal after clipping, got %.6f at %d", clipped.At(i, i), i)
+		}
+	}
+	if clippedSummary.FrobeniusDistance < summary.FrobeniusDistance {
+		t.Errorf("Expected higham to be nearer than clipping, got %.4f and %.4f", summary.FrobeniusDistance, clippedSummary.FrobeniusDistance)
+	}
+
+	settings.CorrelationRepair = sm.NeverRepair
+	if _, _, err := repairCorrelationMatrix(corrMatrix, settings); err == nil {
+		t.Error("Expected an error when repair is turned off")
+	}
+}
+
+func TestGetStatisticalResources_CorrelationRepair(t *testing.T) {
+	// a sample covariance of 25 observations of 30 assets is singular, so without a repair the run would fail
+	returns := generateFactorReturns(30, 25)
+	seriesReturns := make([]*SeriesReturns, len(returns))
+	for i, r := range returns {
+		seriesReturns[i] = &SeriesReturns{Returns: r, AnnualizationFactor: sm.Daily}
+	}
+
+	settings := sm.SimulationRequestSettings{DistType: sm.StandardNormal}
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+	if !sr.CorrelationRepair.Repaired || sr.CorrelationRepair.FrobeniusDistance <= 0 {
+		t.Errorf("Expected the correlation matrix to be repaired, got %+v", sr.CorrelationRepair)
+	}
+
+	// the repair leaves the volatilities alone
+	for i := range returns {
+		if expected := math.Sqrt(GetCovarianceMatrix(returns).At(i, i) * sm.Daily); math.Abs(sr.Sigma[i]-expected) > 1e-12 {
+			t.Errorf("asset %d: expected sigma %.6f, got %.6f", i, expected, sr.Sigma[i])
+		}
+		if expected := sr.Sigma[i] * sr.Sigma[i] / sm.Daily; math.Abs(sr.CovMatrix.At(i, i)-expected) > 1e-15 {
+			t.Errorf("asset %d: expected variance %.6e, got %.6e", i, expected, sr.CovMatrix.At(i, i))
+		}
+	}
+
+	settings.CorrelationRepair = sm.NeverRepair
+	if _, err := GetStatisticalResources(seriesReturns, settings); err == nil {
+		t.Error("Expected the singular covariance to fail without a repair")
+	}
+
+	// a healthy matrix is only touched when a repair is always asked for
+	sr, err = GetStatisticalResources(GenerateMockSeriesReturns(t, sm.Daily), sm.SimulationRequestSettings{DistType: sm.StandardNormal})
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+	if sr.CorrelationRepair.Repaired || sr.CorrelationRepair.MinEigenvalueBefore <= 0 {
+		t.Errorf("Expected no repair of a positive definite matrix, got %+v", sr.CorrelationRepair)
+	}
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+
+	sm "mc.service/models"
+)
+
+// validateShocks checks the shocks against the simulation and the assets of the run, an asset id of 0 shocks every asset
+func validateShocks(settings sm.SimulationRequestSettings, assetIds []int32) error {
+	for _, shock := range settings.Shocks {
+		if shock.Period < 1 || shock.Period > settings.SimulationDuration {
+			return fmt.Errorf("shock period must be between 1 and the simulation duration %d, got %d", settings.SimulationDuration, shock.Period)
+		}
+
+		if shock.Return <= -1 {
+			return fmt.Errorf("shock return must be greater than -100%%, got %.4f", shock.Return)
+		}
+
+		if shock.AssetId != 0 && !slices.Contains(assetIds, shock.AssetId) {
+			return fmt.Errorf("shock asset id %d is not in the scenario", shock.AssetId)
+		}
+	}
+
+	return nil
+}
+
+// getShockOverlay turns the shocks into log returns per asset keyed by the 0 based period, shocks to the same asset in the same period compound
+func getShockOverlay(seriesReturns []*SeriesReturns, settings sm.SimulationRequestSettings) (map[int][]float64, error) {
+	assetIds := make([]int32, len(seriesReturns))
+	for i, r := range seriesReturns {
+		assetIds[i] = r.AssetId
+	}
+	if err := validateShocks(settings, assetIds); err != nil {
+		return nil, err
+	}
+
+	res := make(map[int][]float64)
+	for _, shock := range settings.Shocks {
+		idx := slices.Index(assetIds, shock.AssetId)
+		period := shock.Period - 1
+		if res[period] == nil {
+			res[period] = make([]float64, len(seriesReturns))
+		}
+
+		for i := range seriesReturns {
+			if shock.AssetId == 0 || i == idx {
+				res[period][i] += math.Log1p(shock.Return)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// runShockBaseline runs the paths of the shocked run again without the shocks. every path draws from its own stream of the seed,
+// so the baseline path sees the same random numbers as its shocked path (common random numbers) and the difference is only the shocks.
+func (sc *ServiceContext) runShockBaseline(statisticalResources *StatisticalResources, settings sm.SimulationRequestSettings, shocked *SimulationAggregate, response *sm.SimulationResponse) (*sm.ShockSummary, error) {
+	baselineResources := *statisticalResources
+	baselineResources.Shocks = nil
+	baselineResources.Benchmark = nil // the baseline only reports the portfolio
+
+	// the same number of paths, converging again would stop at a different count
+	settings.Iterations = shocked.Paths()
+	settings.Convergence = nil
+
+	log.Printf("Running the unshocked baseline over the same %v paths", settings.Iterations)
+	baseline := newSimulationAggregate(&baselineResources, settings)
+	if err := sc.simulatePaths(&baselineResources, settings, 0, settings.Iterations, nil, baseline); err != nil {
+		return nil, err
+	}
+
+	_, meanFinalValue := calculateStandardErrors(baseline, &baselineResources, settings)
+	riskMetrics := calculateRiskMetrics(baseline.risk(), baseline.initialValue)
+	riskMetrics.MeanFinalValue = meanFinalValue
+
+	return &sm.ShockSummary{
+		Shocks:          settings.Shocks,
+		Baseline:        riskMetrics,
+		BaselineSummary: calculateSummaryStats(baseline),
+		Impact:          calculateShockImpact(response.RiskMetrics, riskMetrics),
+	}, nil
+}
+
+func calculateShockImpact(shocked, baseline sm.SimulationRiskMetrics) sm.ShockImpact {
+	return sm.ShockImpact{
+		MeanFinalValue:    shocked.MeanFinalValue - baseline.MeanFinalValue,
+		MedianFinalValue:  shocked.MedianFinalValue - baseline.MedianFinalValue,
+		VaR95:             shocked.VaR95 - baseline.VaR95,
+		CVaR95:            shocked.CVaR95 - baseline.CVaR95,
+		ProbabilityOfLoss: shocked.ProbabilityOfLoss - baseline.ProbabilityOfLoss,
+		MaxDrawdownP95:    shocked.MaxDrawdownP95 - baseline.MaxDrawdownP95,
+		ProbabilityOfRuin: shocked.ProbabilityOfRuin - baseline.ProbabilityOfRuin,
+	}
+}
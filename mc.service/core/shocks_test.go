@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	sm "mc.service/models"
+)
+
+func TestGetShockOverlay(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily)
+	settings := sm.SimulationRequestSettings{
+		SimulationDuration: 52,
+		Shocks: []sm.Shock{
+			{AssetId: 0, Period: 26, Return: -0.3},
+			{AssetId: 1, Period: 26, Return: -0.1},
+			{AssetId: 2, Period: 52, Return: 0.05},
+		},
+	}
+
+	overlay, err := getShockOverlay(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("getShockOverlay: %v", err)
+	}
+	if len(overlay) != 2 {
+		t.Fatalf("Expected shocks in 2 periods, got %d", len(overlay))
+	}
+
+	// shocks to the same asset in the same period compound
+	expected := []float64{math.Log(0.7), math.Log(0.7 * 0.9), math.Log(0.7)}
+	for i, e := range expected {
+		if math.Abs(overlay[25][i]-e) > 1e-12 {
+			t.Errorf("asset %d: expected log shock %.6f, got %.6f", i, e, overlay[25][i])
+		}
+	}
+	if overlay[51][0] != 0 || math.Abs(overlay[51][2]-math.Log(1.05)) > 1e-12 {
+		t.Errorf("Expected only asset 2 to be shocked in the last period, got %v", overlay[51])
+	}
+
+	if err := validateShocks(settings, []int32{1, 2, 3}); err != nil {
+		t.Errorf("Expected the shocks to be valid before the run: %v", err)
+	}
+
+	invalid := map[string]sm.Shock{
+		"period 0":         {Period: 0, Return: -0.1},
+		"past the end":     {Period: 53, Return: -0.1},
+		"total loss":       {Period: 1, Return: -1},
+		"unknown asset id": {AssetId: 99, Period: 1, Return: -0.1},
+	}
+	for name, shock := range invalid {
+		settings.Shocks = []sm.Shock{shock}
+		if _, err := getShockOverlay(seriesReturns, settings); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if err := validateShocks(settings, []int32{1, 2, 3}); err == nil {
+			t.Errorf("%s: expected an error before the run", name)
+		}
+	}
+}
+
+// TestRunMonteCarloSimulation_ShockBaseline checks the baseline draws the same numbers, with every asset shocked and continuous
+// rebalancing each path ends exactly the shock below its baseline path
+func TestRunMonteCarloSimulation_ShockBaseline(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*5)
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Weekly,
+		SimulationDuration:   52,
+		Iterations:           2_000,
+		Seed:                 42,
+		Shocks:               []sm.Shock{{Period: 26, Return: -0.3}},
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	sc := &ServiceContext{Context: context.Background()}
+	shocked, _, err := sc.RunAdaptiveMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunAdaptiveMonteCarloSimulation: %v", err)
+	}
+
+	response := buildSimulationResponse(shocked)
+	summary, err := sc.runShockBaseline(sr, settings, shocked, response)
+	if err != nil {
+		t.Fatalf("runShockBaseline: %v", err)
+	}
+
+	if sr.Shocks == nil {
+		t.Fatal("Expected the baseline to leave the shocked resources alone")
+	}
+
+	if ratio := response.RiskMetrics.MedianFinalValue / summary.Baseline.MedianFinalValue; math.Abs(ratio-0.7) > 1e-9 {
+		t.Errorf("Expected the median final value to be 70%% of the baseline, got %.6f", ratio)
+	}
+	if math.Abs(summary.Impact.VaR95-(0.7*(1+summary.Baseline.VaR95)-1-summary.Baseline.VaR95)) > 1e-9 {
+		t.Errorf("Expected the var95 impact of a 30%% fall, got %.6f", summary.Impact.VaR95)
+	}
+	if summary.Impact.ProbabilityOfLoss <= 0 || summary.Impact.MaxDrawdownP95 <= 0 {
+		t.Errorf("Expected the shock to add losses and drawdowns, got %+v", summary.Impact)
+	}
+
+	// the bands only split at the shock
+	if summary.BaselineSummary.P50[25] != response.Summary.P50[25] || summary.BaselineSummary.P50[26] == response.Summary.P50[26] {
+		t.Errorf("Expected the medians to match until period 26, got %.4f and %.4f before, %.4f and %.4f after",
+			summary.BaselineSummary.P50[25], response.Summary.P50[25], summary.BaselineSummary.P50[26], response.Summary.P50[26])
+	}
+}
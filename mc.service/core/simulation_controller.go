@@ -5,9 +5,9 @@ import (
 	"log"
 	"math"
 	"slices"
+	"strings"
 	"time"
 
-	"gonum.org/v1/gonum/stat"
 	dm "mc.data/models"
 	sm "mc.service/models"
 )
@@ -23,6 +23,45 @@ func (sc *ServiceContext) RunSimulation(scenarioID int32, settings sm.Simulation
 	}
 
 	log.Printf("Recieved request to run scenario: %v", scenario.Name)
+	if err := validateReportingSettings(settings); err != nil {
+		log.Printf("Error validating settings for scenario %v: %v", scenario.Name, err)
+		return nil, err
+	}
+
+	if settings, err = sc.resolveCapitalMarketAssumptions(scenario, settings); err != nil {
+		log.Printf("Error getting capital market assumptions for scenario %v: %v", scenario.Name, err)
+		return nil, err
+	}
+
+	if settings, err = sc.resolveViews(scenario, settings); err != nil {
+		log.Printf("Error getting black-litterman views for scenario %v: %v", scenario.Name, err)
+		return nil, err
+	}
+
+	benchmark, err := sc.getBenchmark(scenario, settings)
+	if err != nil {
+		log.Printf("Error getting benchmark for scenario %v: %v", scenario.Name, err)
+		return nil, err
+	}
+
+	// a bad request is the caller's error, not a failed run, so everything the request alone decides is checked before the run is recorded
+	if err := validateStatisticalSettings(settings); err != nil {
+		log.Printf("Error validating settings for scenario %v: %v", scenario.Name, err)
+		return nil, err
+	}
+
+	assetIds := make([]int32, len(scenario.Components))
+	for i, c := range scenario.Components {
+		assetIds[i] = c.AssetId
+	}
+	if err := validateShocks(settings, assetIds); err != nil {
+		log.Printf("Error validating shocks for scenario %v: %v", scenario.Name, err)
+		return nil, err
+	}
+
+	// the effective seed is stored and returned so the run can be replayed bit for bit
+	settings.Seed = getEffectiveSeed(settings.Seed)
+
 	maxLookbackDate := time.Now().Add(-settings.MaxLookback)
 	log.Printf("Inserting scenario %v to simulation run history (time: %v)", scenario.Name, time.Since(start))
 	dmSimulationRunHistory := sm.MapSimulationRequestSettingsToSimulationRunHistory(settings, maxLookbackDate)
@@ -52,20 +91,80 @@ func (sc *ServiceContext) RunSimulation(scenarioID int32, settings sm.Simulation
 		return nil, err
 	}
 
+	if statisticalResources.Rebalance, err = getRebalancePolicy(scenario.ScenarioConfiguration); err != nil {
+		log.Printf("Error getting rebalance policy for scenario %v: %v", scenario.Name, err)
+		return sc.markSimulationRunAsFailure(simulationRunId, err.Error())
+	}
+
+	if benchmark != nil {
+		var benchmarkReturns []*SeriesReturns
+		if benchmarkScenario := getBenchmarkAssetsScenario(scenario, benchmark); benchmarkScenario != nil {
+			if benchmarkReturns, err = sc.getSeriesReturns(benchmarkScenario, maxLookbackDate); err != nil {
+				log.Printf("Error getting benchmark series returns for scenario %v: %v", scenario.Name, err)
+				return sc.markSimulationRunAsFailure(simulationRunId, err.Error())
+			}
+		}
+
+		if statisticalResources.Benchmark, err = getBenchmarkResources(seriesReturns, benchmarkReturns, statisticalResources, benchmark, settings); err != nil {
+			log.Printf("Error getting benchmark resources for scenario %v: %v", scenario.Name, err)
+			return sc.markSimulationRunAsFailure(simulationRunId, err.Error())
+		}
+	}
+
 	log.Printf("Running monte carlo simulation for scenario %v (time: %v)", scenario.Name, time.Since(start))
-	res, err := sc.RunMonteCarloSimulation(statisticalResources, settings)
+	aggregate, convergence, err := sc.RunAdaptiveMonteCarloSimulation(statisticalResources, settings)
 	if err != nil {
 		log.Printf("Error running monte carlo simulation for scenario %v: %v", scenario.Name, err)
 		return sc.markSimulationRunAsFailure(simulationRunId, err.Error())
 	}
 
-	if err := sc.PostgresConnection.UpdateSimulationRunAsSuccess(sc.Context, simulationRunId); err != nil {
+	if err := sc.PostgresConnection.UpdateSimulationRunAsSuccess(sc.Context, simulationRunId, aggregate.Paths()); err != nil {
 		log.Printf("Error updating simulation run as success for scenario %v: %v", scenario.Name, err)
 		return nil, err // not making this as failure here, if we cant update it to success, we most likely cant update it to failure either
 	}
 
 	log.Printf("Building simulation response for scenario %v (time: %v)", scenario.Name, time.Since(start))
-	response := buildSimulationResponse(res)
+	standardErrors, meanFinalValue := calculateStandardErrors(aggregate, statisticalResources, settings)
+	metricStandardErrors := calculateRiskMetricStandardErrors(aggregate)
+	if metricStandardErrors != nil {
+		// these two have the variance reduction applied, and var95 is the one convergence targets
+		metricStandardErrors["meanFinalValue"] = standardErrors.MeanFinalValue
+		metricStandardErrors["var95"] = standardErrors.VaR95
+	}
+
+	response := buildSimulationResponse(aggregate)
+	response.StandardErrors = standardErrors
+	response.RiskMetrics.MeanFinalValue = meanFinalValue
+	response.Goals = calculateGoalSummaries(aggregate)
+	response.Performance = calculatePerformanceSummaries(aggregate)
+	response.ConfidenceIntervals = getConfidenceIntervals(response.RiskMetrics, metricStandardErrors)
+	response.PathsRun = aggregate.Paths()
+	response.Seed = settings.Seed
+	response.Convergence = convergence
+	response.InitialPortfolioValue = settings.GetInitialPortfolioValue()
+	response.Currency = settings.GetCurrency()
+	response.Rebalancing = calculateRebalanceSummary(aggregate, statisticalResources.Rebalance)
+	response.CorrelationRepair = statisticalResources.CorrelationRepair
+	response.Assumptions = statisticalResources.Assumptions
+	response.BlackLitterman = statisticalResources.BlackLitterman
+	if settings.VolatilityModel == sm.Garch {
+		response.GarchParameters = mapGarchParameters(seriesReturns, statisticalResources)
+	}
+	if statisticalResources.RegimeModel != nil {
+		response.Regimes = calculateRegimeSummaries(aggregate, statisticalResources.RegimeModel)
+	}
+	if statisticalResources.Jumps != nil {
+		response.Jumps = mapJumpSummaries(seriesReturns, statisticalResources.Jumps)
+	}
+	if statisticalResources.Benchmark != nil {
+		response.Benchmark = calculateBenchmarkSummary(aggregate, statisticalResources, seriesReturns, *settings.Benchmark)
+	}
+	if statisticalResources.Shocks != nil {
+		if response.Shock, err = sc.runShockBaseline(statisticalResources, settings, aggregate, response); err != nil {
+			log.Printf("Error running the unshocked baseline for scenario %v: %v", scenario.Name, err)
+			return nil, err
+		}
+	}
 
 	log.Printf("Simulation for scenario %v completed (time: %v)", scenario.Name, time.Since(start))
 	return response, nil
@@ -94,13 +193,38 @@ func validateScenario(scenario *dm.Scenario) error {
 	return nil
 }
 
+// validateReportingSettings checks the starting capital and the currency it is reported in
+func validateReportingSettings(settings sm.SimulationRequestSettings) error {
+	if settings.InitialPortfolioValue < 0 {
+		return fmt.Errorf("initial portfolio value must not be negative, got %.2f", settings.InitialPortfolioValue)
+	}
+
+	currency := settings.GetCurrency()
+	notLetter := func(r rune) bool { return r < 'A' || r > 'Z' }
+	if len(currency) != 3 || strings.IndexFunc(currency, notLetter) >= 0 {
+		return fmt.Errorf("currency must be a 3 letter iso code, got %q", settings.Currency)
+	}
+
+	return nil
+}
+
 func (sc *ServiceContext) markSimulationRunAsFailure(runId int32, errorMessage string) (*sm.SimulationResponse, error) {
 	return nil, sc.PostgresConnection.UpdateSimulationRunAsFailure(sc.Context, runId, errorMessage)
 }
 
-func buildSimulationResponse(results []*SimulationResult) *sm.SimulationResponse {
-	// sort once by final value (ascending). All quintile calculations use this order,
-	// most of the rest dont care about order, so this is fine
+func buildSimulationResponse(aggregate *SimulationAggregate) *sm.SimulationResponse {
+	riskMetrics := calculateRiskMetrics(aggregate.risk(), aggregate.initialValue)
+	samplePaths := selectSamplePaths(aggregate)
+	summary := calculateSummaryStats(aggregate)
+
+	return &sm.SimulationResponse{
+		RiskMetrics: riskMetrics,
+		SamplePaths: samplePaths,
+		Summary:     summary,
+	}
+}
+
+func sortResultsByFinalValue(results []*SimulationResult) {
 	slices.SortFunc(results, func(a, b *SimulationResult) int {
 		if a.FinalValue < b.FinalValue {
 			return -1
@@ -110,67 +234,165 @@ func buildSimulationResponse(results []*SimulationResult) *sm.SimulationResponse
 		}
 		return 0
 	})
+}
 
-	riskMetrics := calculateRiskMetrics(results)
-	samplePaths := selectSamplePaths(results)
-	summary := calculateSummaryStats(results)
+// mapGarchParameters reports the fitted garch models, garch models line up with the series returns by index
+func mapGarchParameters(seriesReturns []*SeriesReturns, statisticalResources *StatisticalResources) []sm.GarchParameters {
+	res := make([]sm.GarchParameters, len(seriesReturns))
+	for i, r := range seriesReturns {
+		g := statisticalResources.Garch[i]
+		res[i] = sm.GarchParameters{
+			AssetId:           r.AssetId,
+			Omega:             g.Omega,
+			Alpha:             g.Alpha,
+			Beta:              g.Beta,
+			LongRunVolatility: statisticalResources.Sigma[i],
+			Fitted:            g.Fitted,
+		}
 
-	return &sm.SimulationResponse{
-		RiskMetrics: riskMetrics,
-		SamplePaths: samplePaths,
-		Summary:     summary,
+		if g.Fitted {
+			res[i].LongRunVolatility = math.Sqrt(g.LongRunVariance() * float64(r.AnnualizationFactor))
+		}
 	}
+
+	return res
 }
 
-func calculateRiskMetrics(results []*SimulationResult) sm.SimulationRiskMetrics {
-	n := len(results)
+// mapJumpSummaries reports the jumps that were simulated, so estimated parameters are visible to the user
+func mapJumpSummaries(seriesReturns []*SeriesReturns, jumps *JumpResources) []sm.JumpSummary {
+	res := make([]sm.JumpSummary, 0, len(seriesReturns)+1)
+	if jumps.Market != nil {
+		res = append(res, sm.JumpSummary{
+			Market:     true,
+			Intensity:  jumps.Market.Intensity,
+			Mean:       jumps.Market.Mean,
+			Volatility: jumps.Market.Volatility,
+		})
+	}
 
-	finalValues := make([]float64, n)
-	totalReturns := make([]float64, n)
-	maxDrawdowns := make([]float64, n)
+	for i, r := range seriesReturns {
+		j := jumps.Assets[i]
+		res = append(res, sm.JumpSummary{
+			AssetId:    r.AssetId,
+			Intensity:  j.Intensity,
+			Mean:       j.Mean,
+			Volatility: j.Volatility,
+		})
+	}
+
+	return res
+}
+
+// calculateRebalanceSummary reports how often paths rebalanced and where the weights drifted to by the end
+func calculateRebalanceSummary(aggregate *SimulationAggregate, policy RebalancePolicy) sm.RebalanceSummary {
+	res := sm.RebalanceSummary{
+		Policy:              sm.GetRebalancePolicy(policy.Policy),
+		AverageRebalances:   aggregate.rebalances / float64(aggregate.paths),
+		AverageFinalWeights: make([]float64, len(aggregate.finalWeights)),
+	}
 
-	for i, res := range results {
-		finalValues[i] = res.FinalValue
-		totalReturns[i] = res.TotalReturn
-		maxDrawdowns[i] = res.MaxDrawdown
+	for i, w := range aggregate.finalWeights {
+		res.AverageFinalWeights[i] = w / float64(max(aggregate.survivors, 1))
 	}
 
-	var95 := stat.Quantile(0.05, stat.Empirical, totalReturns, nil)
-	var99 := stat.Quantile(0.01, stat.Empirical, totalReturns, nil)
-	cvar95 := calculateCVaR(totalReturns, 0.05)
-	cvar99 := calculateCVaR(totalReturns, 0.01)
+	return res
+}
 
-	lossCount := 0
-	for _, r := range totalReturns {
-		if r < 0 {
-			lossCount++
+// calculateRegimeSummaries reports each regime and the average fraction of time paths spent in it
+func calculateRegimeSummaries(aggregate *SimulationAggregate, regimeModel *RegimeModel) []sm.RegimeSummary {
+	res := make([]sm.RegimeSummary, len(regimeModel.Regimes))
+	for k, regime := range regimeModel.Regimes {
+		res[k] = sm.RegimeSummary{
+			Label:                   regime.Label,
+			Mu:                      regime.Mu,
+			Sigma:                   regime.Sigma,
+			TransitionProbabilities: regimeModel.TransitionMatrix[k],
+			AverageTimeSpent:        aggregate.regimeTime[k] / float64(aggregate.paths),
 		}
 	}
-	probabilityOfLoss := float64(lossCount) / float64(n)
 
-	// maxDrawdowns needs to be sorted as its not proportioal to final value
-	slices.Sort(maxDrawdowns)
-	maxDrawdownP95 := stat.Quantile(0.95, stat.Empirical, maxDrawdowns, nil)
+	return res
+}
+
+func calculateRiskMetrics(risk *riskAggregate, initialValue float64) sm.SimulationRiskMetrics {
+	n := float64(risk.paths)
+
+	var95 := risk.totalReturns.Quantile(0.05)
+	var99 := risk.totalReturns.Quantile(0.01)
+	cvar95 := risk.totalReturns.TailMean(0.05)
+	cvar99 := risk.totalReturns.TailMean(0.01)
+
+	probabilityOfLoss := float64(risk.losses) / n
+	maxDrawdownP95 := risk.maxDrawdowns.Quantile(0.95)
+
+	meanFinal := risk.finalValues.Mean()
+	medianFinal := risk.finalValues.Quantile(0.50)
 
-	meanFinal := stat.Mean(finalValues, nil)
-	medianFinal := stat.Quantile(0.50, stat.Empirical, finalValues, nil)
+	// ruin
+	probabilityOfRuin := risk.depletionPeriods.Count() / n
+	medianTimeToDepletion := risk.depletionPeriods.Quantile(0.50) // 0 when no path ran out
 
 	return sm.SimulationRiskMetrics{
 		VaR95:             var95,
 		VaR99:             var99,
 		CVaR95:            cvar95,
 		CVaR99:            cvar99,
+		VaR95Amount:       var95 * initialValue,
+		VaR99Amount:       var99 * initialValue,
+		CVaR95Amount:      cvar95 * initialValue,
+		CVaR99Amount:      cvar99 * initialValue,
 		ProbabilityOfLoss: probabilityOfLoss,
 		MaxDrawdownP95:    maxDrawdownP95,
 		MeanFinalValue:    meanFinal,
 		MedianFinalValue:  medianFinal,
+
+		ProbabilityOfRuin:     probabilityOfRuin,
+		MedianTimeToDepletion: medianTimeToDepletion,
+		SurvivorFinalValue:    calculateFinalValueDistribution(risk.survivorFinalValues),
+	}
+}
+
+// calculateFinalValueDistribution summarizes a sketch of final values, an empty set (every path ran out) is all zeros
+func calculateFinalValueDistribution(finalValues *tDigest) sm.FinalValueDistribution {
+	if finalValues.Count() == 0 {
+		return sm.FinalValueDistribution{}
+	}
+
+	return sm.FinalValueDistribution{
+		Mean: finalValues.Mean(),
+		P5:   finalValues.Quantile(0.05),
+		P25:  finalValues.Quantile(0.25),
+		P50:  finalValues.Quantile(0.50),
+		P75:  finalValues.Quantile(0.75),
+		P95:  finalValues.Quantile(0.95),
 	}
 }
 
-func selectSamplePaths(results []*SimulationResult) []sm.SamplePath {
+// calculateMetricDistribution summarizes a sketch of a per path metric, an empty sketch is all zeros
+func calculateMetricDistribution(sketch *tDigest) sm.MetricDistribution {
+	if sketch.Count() == 0 {
+		return sm.MetricDistribution{}
+	}
+
+	return sm.MetricDistribution{
+		Mean: sketch.Mean(),
+		P5:   sketch.Quantile(0.05),
+		P25:  sketch.Quantile(0.25),
+		P50:  sketch.Quantile(0.50),
+		P75:  sketch.Quantile(0.75),
+		P95:  sketch.Quantile(0.95),
+	}
+}
+
+// selectSamplePaths picks the percentile paths from the reservoir, which is a uniform sample of every path (all of them for small runs).
+// the extreme paths are tracked over every path as they stream in.
+func selectSamplePaths(aggregate *SimulationAggregate) []sm.SamplePath {
+	results := aggregate.reservoir.Results() // sorted by final value
 	n := len(results)
+	if n == 0 {
+		return []sm.SamplePath{}
+	}
 
-	// results are already sorted by FinalValue from buildScenarioResponse
 	percentiles := []struct {
 		percentile float64
 		label      string
@@ -193,45 +415,23 @@ func selectSamplePaths(results []*SimulationResult) []sm.SamplePath {
 		})
 	}
 
-	// below are two metrics that are pre calculated in the monte carlo simulation service
-	// maximum drawdown
-	maxDrawdownIdx := 0
-	maxDrawdownValue := results[0].MaxDrawdown
-
-	// most volatile path
-	maxVolatilityIdx := 0
-	maxVolatilityValue := results[0].AnnualizedVolatility
-
-	for i, res := range results {
-		if res.MaxDrawdown > maxDrawdownValue {
-			maxDrawdownValue = res.MaxDrawdown
-			maxDrawdownIdx = i
-		}
-
-		if res.AnnualizedVolatility > maxVolatilityValue {
-			maxVolatilityValue = res.AnnualizedVolatility
-			maxVolatilityIdx = i
-		}
-	}
-
 	samplePaths = append(samplePaths, sm.SamplePath{
 		Percentile: -1,
-		Values:     results[maxDrawdownIdx].PathValues,
+		Values:     aggregate.maxDrawdownPath.PathValues,
 		Label:      "Maximum Drawdown",
 	})
 
 	samplePaths = append(samplePaths, sm.SamplePath{
 		Percentile: -1,
-		Values:     results[maxVolatilityIdx].PathValues,
+		Values:     aggregate.maxVolatilityPath.PathValues,
 		Label:      "Highest Volatility",
 	})
 
 	return samplePaths
 }
 
-func calculateSummaryStats(results []*SimulationResult) sm.SimulationStats {
-	nResults := len(results)
-	nSteps := len(results[0].PathValues)
+func calculateSummaryStats(aggregate *SimulationAggregate) sm.SimulationStats {
+	nSteps := len(aggregate.steps)
 
 	mean := make([]float64, nSteps)
 	stdDev := make([]float64, nSteps)
@@ -241,23 +441,15 @@ func calculateSummaryStats(results []*SimulationResult) sm.SimulationStats {
 	p75 := make([]float64, nSteps)
 	p95 := make([]float64, nSteps)
 
-	for t := range nSteps {
-		// get values at a point in time
-		values := make([]float64, nResults)
-		for i := range nResults {
-			values[i] = results[i].PathValues[t]
-		}
-
-		// stat.Quantile requires the slice to be sorted in increasing order
-		slices.Sort(values)
-
-		mean[t] = stat.Mean(values, nil)
-		stdDev[t] = stat.StdDev(values, nil)
-		p5[t] = stat.Quantile(0.05, stat.Empirical, values, nil)
-		p25[t] = stat.Quantile(0.25, stat.Empirical, values, nil)
-		p50[t] = stat.Quantile(0.50, stat.Empirical, values, nil)
-		p75[t] = stat.Quantile(0.75, stat.Empirical, values, nil)
-		p95[t] = stat.Quantile(0.95, stat.Empirical, values, nil)
+	// moments are exact, the percentiles come from each step's sketch
+	for t, step := range aggregate.steps {
+		mean[t] = step.moments.mean
+		stdDev[t] = math.Sqrt(step.moments.Variance())
+		p5[t] = step.values.Quantile(0.05)
+		p25[t] = step.values.Quantile(0.25)
+		p50[t] = step.values.Quantile(0.50)
+		p75[t] = step.values.Quantile(0.75)
+		p95[t] = step.values.Quantile(0.95)
 	}
 
 	return sm.SimulationStats{
@@ -270,10 +462,3 @@ func calculateSummaryStats(results []*SimulationResult) sm.SimulationStats {
 		P95:    p95,
 	}
 }
-
-// calculateCVaR calculates the conditional value at risk for a given alpha aka taking the mean of the tail
-func calculateCVaR(sortedReturns []float64, alpha float64) float64 {
-	nReturns := len(sortedReturns)
-	cutoff := int(math.Ceil(alpha * float64(nReturns)))
-	return stat.Mean(sortedReturns[:cutoff], nil)
-}
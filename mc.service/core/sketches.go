@@ -0,0 +1,287 @@
+package core
+
+import (
+	"container/heap"
+	"math"
+	"slices"
+)
+
+const (
+	digestCompression = 200 // more centroids is more accurate quantiles, most of them end up in the tails
+	digestBuffer      = 500 // values held before they are merged into the centroids
+)
+
+// tDigest is a merging t-digest (dunning), a quantile sketch that keeps the tails accurate and merges across workers.
+// centroids near the median hold many values, centroids in the tails hold very few, so var and cvar stay close to exact.
+type tDigest struct {
+	centroids []centroid // sorted by mean
+	buffer    []centroid
+	count     float64
+	sum       float64
+	min       float64
+	max       float64
+}
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+func newTDigest() *tDigest {
+	return &tDigest{min: math.Inf(1), max: math.Inf(-1)}
+}
+
+func (d *tDigest) Add(x float64) {
+	d.buffer = append(d.buffer, centroid{mean: x, weight: 1})
+	d.count++
+	d.sum += x
+	d.min = math.Min(d.min, x)
+	d.max = math.Max(d.max, x)
+
+	if len(d.buffer) >= digestBuffer {
+		d.compress()
+	}
+}
+
+// Merge adds every value of other, other is compressed but otherwise left alone
+func (d *tDigest) Merge(other *tDigest) {
+	if other.count == 0 {
+		return
+	}
+
+	other.compress()
+	d.buffer = append(d.buffer, other.centroids...)
+	d.count += other.count
+	d.sum += other.sum
+	d.min = math.Min(d.min, other.min)
+	d.max = math.Max(d.max, other.max)
+	d.compress()
+}
+
+func (d *tDigest) Count() float64 {
+	return d.count
+}
+
+// Mean is exact, it comes from the running sum rather than the centroids
+func (d *tDigest) Mean() float64 {
+	if d.count == 0 {
+		return 0
+	}
+	return d.sum / d.count
+}
+
+// compress merges the buffer into the centroids, neighbours are merged while they fit inside one unit of the k1 scale function
+func (d *tDigest) compress() {
+	if len(d.buffer) == 0 {
+		return
+	}
+
+	all := append(d.buffer, d.centroids...)
+	slices.SortFunc(all, func(a, b centroid) int {
+		if a.mean < b.mean {
+			return -1
+		}
+		if a.mean > b.mean {
+			return 1
+		}
+		return 0
+	})
+
+	merged := make([]centroid, 0, len(d.centroids)+1)
+	current, before := all[0], 0.0
+	for _, c := range all[1:] {
+		if digestScale((before+current.weight+c.weight)/d.count)-digestScale(before/d.count) <= 1 {
+			current.weight += c.weight
+			current.mean += (c.mean - current.mean) * c.weight / current.weight
+			continue
+		}
+
+		merged = append(merged, current)
+		before += current.weight
+		current = c
+	}
+
+	d.centroids = append(merged, current)
+	d.buffer = all[:0] // reuse the larger of the two backing arrays for the next buffer
+}
+
+// digestScale is the k1 scale function, steep near 0 and 1 so the tails get small centroids
+func digestScale(q float64) float64 {
+	return digestCompression / (2 * math.Pi) * math.Asin(2*math.Min(q, 1)-1)
+}
+
+// Quantile interpolates between centroid centers, and between the outer centroids and the min and max
+func (d *tDigest) Quantile(q float64) float64 {
+	d.compress()
+	if d.count == 0 {
+		return 0
+	}
+	if len(d.centroids) == 1 {
+		return d.centroids[0].mean
+	}
+
+	target := q * d.count
+	first := d.centroids[0]
+	if target < first.weight/2 {
+		return d.min + (first.mean-d.min)*target/(first.weight/2)
+	}
+
+	before := 0.0
+	for i := range len(d.centroids) - 1 {
+		left, right := d.centroids[i], d.centroids[i+1]
+		lower := before + left.weight/2
+		upper := before + left.weight + right.weight/2
+		if target <= upper {
+			return left.mean + (right.mean-left.mean)*(target-lower)/(upper-lower)
+		}
+		before += left.weight
+	}
+
+	last := d.centroids[len(d.centroids)-1]
+	lower := d.count - last.weight/2
+	return last.mean + (d.max-last.mean)*math.Min((target-lower)/(last.weight/2), 1)
+}
+
+// TailMean is the mean of the lowest alpha share of the values, the cvar of returns.
+// it integrates the interpolated quantile function, which follows the tail better than averaging whole centroids.
+func (d *tDigest) TailMean(alpha float64) float64 {
+	if d.count == 0 {
+		return 0
+	}
+
+	const steps = 200
+	sum := 0.0
+	for i := range steps {
+		sum += d.Quantile(alpha * (float64(i) + 0.5) / steps)
+	}
+	return sum / steps
+}
+
+// welford keeps a running mean and variance that can be merged (chan et al)
+type welford struct {
+	n    float64
+	mean float64
+	m2   float64
+}
+
+func (w *welford) Add(x float64) {
+	w.n++
+	delta := x - w.mean
+	w.mean += delta / w.n
+	w.m2 += delta * (x - w.mean)
+}
+
+func (w *welford) Merge(other welford) {
+	n := w.n + other.n
+	if n == 0 {
+		return
+	}
+
+	delta := other.mean - w.mean
+	w.m2 += other.m2 + delta*delta*w.n*other.n/n
+	w.mean += delta * other.n / n
+	w.n = n
+}
+
+// Variance is the sample variance, 0 for fewer than two values
+func (w welford) Variance() float64 {
+	if w.n < 2 {
+		return 0
+	}
+	return w.m2 / (w.n - 1)
+}
+
+// comoments is welford for a pair of values, which adds the covariance between them
+type comoments struct {
+	x, y welford
+	cxy  float64
+}
+
+func (c *comoments) Add(x, y float64) {
+	dx := x - c.x.mean
+	c.x.Add(x)
+	c.y.Add(y)
+	c.cxy += dx * (y - c.y.mean)
+}
+
+func (c *comoments) Merge(other comoments) {
+	n := c.x.n + other.x.n
+	if n == 0 {
+		return
+	}
+
+	dx, dy := other.x.mean-c.x.mean, other.y.mean-c.y.mean
+	c.cxy += other.cxy + dx*dy*c.x.n*other.x.n/n
+	c.x.Merge(other.x)
+	c.y.Merge(other.y)
+}
+
+// Covariance is the sample covariance, 0 for fewer than two pairs
+func (c comoments) Covariance() float64 {
+	if c.x.n < 2 {
+		return 0
+	}
+	return c.cxy / (c.x.n - 1)
+}
+
+// pathReservoir is a bottom k sample, every path gets a key from its index and the k smallest keys are kept.
+// that is a uniform sample of the paths, and merging two reservoirs is just keeping the k smallest keys of both.
+type pathReservoir struct {
+	size    int
+	entries reservoirHeap
+}
+
+type reservoirEntry struct {
+	key    uint64
+	result *SimulationResult
+}
+
+// reservoirHeap is a max heap on the key, so the entry to drop is always on top
+type reservoirHeap []reservoirEntry
+
+func (h reservoirHeap) Len() int           { return len(h) }
+func (h reservoirHeap) Less(i, j int) bool { return h[i].key > h[j].key }
+func (h reservoirHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *reservoirHeap) Push(x any)        { *h = append(*h, x.(reservoirEntry)) }
+func (h *reservoirHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+func (r *pathReservoir) Add(key uint64, result *SimulationResult) {
+	if len(r.entries) < r.size {
+		heap.Push(&r.entries, reservoirEntry{key: key, result: result})
+		return
+	}
+
+	if key < r.entries[0].key {
+		r.entries[0] = reservoirEntry{key: key, result: result}
+		heap.Fix(&r.entries, 0)
+	}
+}
+
+func (r *pathReservoir) Merge(other *pathReservoir) {
+	for _, e := range other.entries {
+		r.Add(e.key, e.result)
+	}
+}
+
+// Results returns the sampled paths sorted by final value
+func (r *pathReservoir) Results() []*SimulationResult {
+	res := make([]*SimulationResult, len(r.entries))
+	for i, e := range r.entries {
+		res[i] = e.result
+	}
+	sortResultsByFinalValue(res)
+	return res
+}
+
+// splitMix64 scrambles a path index into a reservoir key, the same path always gets the same key
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
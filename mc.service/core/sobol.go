@@ -0,0 +1,229 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+
+	sm "mc.service/models"
+)
+
+const (
+	MaxSobolDimension = 40_000 // assets x periods, primitive polynomials get slow to find past this
+	sobolBits         = 32     // binary digits per coordinate, enough for 2^32 points
+)
+
+// SobolSequence is a scrambled sobol low discrepancy sequence, each point covers a whole path (assets x periods coordinates).
+// direction numbers come from primitive polynomials with random initial values, then a random linear scramble and digital shift
+// (matousek) so the points keep their stratification but are not the same for every seed.
+type SobolSequence struct {
+	Dimension  int
+	directions [][sobolBits]uint32 // per dimension, digits are stored most significant first
+	shift      []uint32            // per dimension digital shift
+}
+
+// NewSobolSequence builds a scrambled sequence, the seed drives the initial direction numbers and the scramble
+func NewSobolSequence(dimension int, seed uint64) (*SobolSequence, error) {
+	if dimension < 1 || dimension > MaxSobolDimension {
+		return nil, fmt.Errorf("sobol dimension (assets x periods) must be between 1 and %d, got %d", MaxSobolDimension, dimension)
+	}
+
+	return newSobolSequence(dimension, rand.New(rand.NewPCG(seed, 0)), true), nil
+}
+
+// getSobolSequence builds the sequence for a run, nil unless the random source is sobol.
+// only the normal shocks come from the points, anything else a path draws (mixing variables, regimes, jumps) stays pseudo random.
+func getSobolSequence(nAssets int, settings sm.SimulationRequestSettings) (*SobolSequence, error) {
+	switch settings.RandomSource {
+	case sm.PseudoRandom:
+		return nil, nil
+	case sm.Sobol:
+	default:
+		return nil, fmt.Errorf("unknown random source %d", settings.RandomSource)
+	}
+
+	if settings.DistType == sm.HistoricalBootstrap {
+		return nil, fmt.Errorf("sobol points are not supported with historical bootstrap, there are no normal shocks to replace")
+	}
+
+	return NewSobolSequence(nAssets*settings.SimulationDuration, uint64(settings.Seed))
+}
+
+func newSobolSequence(dimension int, rng *rand.Rand, scramble bool) *SobolSequence {
+	s := &SobolSequence{
+		Dimension:  dimension,
+		directions: make([][sobolBits]uint32, dimension),
+		shift:      make([]uint32, dimension),
+	}
+
+	// the first dimension is the van der corput sequence, the rest each get their own primitive polynomial
+	for k := range sobolBits {
+		s.directions[0][k] = 1 << (sobolBits - 1 - k)
+	}
+
+	polynomials := getPrimitivePolynomials(dimension - 1)
+	for j := 1; j < dimension; j++ {
+		s.directions[j] = getDirectionNumbers(polynomials[j-1], rng)
+	}
+
+	if scramble {
+		for j := range dimension {
+			scrambleDirectionNumbers(&s.directions[j], rng)
+			s.shift[j] = rng.Uint32()
+		}
+	}
+
+	return s
+}
+
+// Point writes the index'th point (gray code order) into point, used to jump to the first path of a job
+func (s *SobolSequence) Point(index uint64, point []uint32) {
+	gray := index ^ (index >> 1)
+	for j := range s.Dimension {
+		x := s.shift[j]
+		for k := 0; gray>>k != 0 && k < sobolBits; k++ {
+			if gray>>k&1 == 1 {
+				x ^= s.directions[j][k]
+			}
+		}
+		point[j] = x
+	}
+}
+
+// Next steps point from index-1 to index, in gray code order consecutive points only differ by one direction number
+func (s *SobolSequence) Next(index uint64, point []uint32) {
+	k := bits.TrailingZeros64(index)
+	for j := range s.Dimension {
+		point[j] ^= s.directions[j][k]
+	}
+}
+
+// sobolUniform maps the digits to the middle of their interval, so the uniform is never exactly 0 or 1
+func sobolUniform(x uint32) float64 {
+	return (float64(x) + 0.5) / (1 << sobolBits)
+}
+
+// getDirectionNumbers runs the sobol recurrence for a primitive polynomial of degree s,
+// m_k = 2 a_1 m_(k-1) ^ 4 a_2 m_(k-2) ^ ... ^ 2^s m_(k-s) ^ m_(k-s), the first s values are random odd m_k < 2^k
+func getDirectionNumbers(polynomial uint32, rng *rand.Rand) [sobolBits]uint32 {
+	degree := bits.Len32(polynomial) - 1
+	m := make([]uint32, sobolBits)
+	for k := range min(degree, sobolBits) {
+		m[k] = uint32(rng.IntN(1<<k))<<1 | 1
+	}
+
+	for k := degree; k < sobolBits; k++ {
+		m[k] = m[k-degree] ^ m[k-degree]<<degree
+		for i := 1; i < degree; i++ {
+			if polynomial>>(degree-i)&1 == 1 {
+				m[k] ^= m[k-i] << i
+			}
+		}
+	}
+
+	var directions [sobolBits]uint32
+	for k := range sobolBits {
+		directions[k] = m[k] << (sobolBits - 1 - k)
+	}
+	return directions
+}
+
+// scrambleDirectionNumbers multiplies every direction number by the same random lower triangular binary matrix with a unit diagonal.
+// output digit r is the parity of row r and the input digits, digits are counted from the most significant.
+func scrambleDirectionNumbers(directions *[sobolBits]uint32, rng *rand.Rand) {
+	var rows [sobolBits]uint32
+	for r := range sobolBits {
+		diagonal := uint32(1) << (sobolBits - 1 - r)
+		below := ^uint32(0) << (sobolBits - r) // digits before r, none for the first row
+		rows[r] = diagonal | rng.Uint32()&below
+	}
+
+	for k, v := range directions {
+		scrambled := uint32(0)
+		for r := range sobolBits {
+			if bits.OnesCount32(rows[r]&v)&1 == 1 {
+				scrambled |= 1 << (sobolBits - 1 - r)
+			}
+		}
+		directions[k] = scrambled
+	}
+}
+
+// getPrimitivePolynomials returns the first n primitive polynomials over GF(2) by degree, as bits with the leading and constant terms set
+func getPrimitivePolynomials(n int) []uint32 {
+	res := make([]uint32, 0, n)
+	for degree := 1; len(res) < n; degree++ {
+		factors := getPrimeFactors(uint64(1)<<degree - 1)
+		for p := uint32(1)<<degree | 1; p < uint32(1)<<(degree+1) && len(res) < n; p += 2 {
+			// an even number of terms means x + 1 divides it, only x + 1 itself gets through
+			if (degree == 1 || bits.OnesCount32(p)%2 == 1) && isPrimitivePolynomial(p, degree, factors) {
+				res = append(res, p)
+			}
+		}
+	}
+	return res
+}
+
+// isPrimitivePolynomial checks x has order 2^d - 1 modulo p, which means p is irreducible and x generates the whole field
+func isPrimitivePolynomial(p uint32, degree int, factors []uint64) bool {
+	order := uint64(1)<<degree - 1
+	if polynomialPowMod(2, order, p, degree) != 1 {
+		return false
+	}
+
+	for _, q := range factors {
+		if polynomialPowMod(2, order/q, p, degree) == 1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// polynomialPowMod is base^e mod p over GF(2)
+func polynomialPowMod(base uint32, e uint64, p uint32, degree int) uint32 {
+	res := uint32(1)
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			res = polynomialMulMod(res, base, p, degree)
+		}
+		base = polynomialMulMod(base, base, p, degree)
+	}
+	return res
+}
+
+func polynomialMulMod(a, b, p uint32, degree int) uint32 {
+	var product uint64
+	for i := 0; b>>i != 0; i++ {
+		if b>>i&1 == 1 {
+			product ^= uint64(a) << i
+		}
+	}
+
+	for i := bits.Len64(product) - 1; i >= degree; i-- {
+		if product>>i&1 == 1 {
+			product ^= uint64(p) << (i - degree)
+		}
+	}
+
+	return uint32(product)
+}
+
+func getPrimeFactors(n uint64) []uint64 {
+	factors := []uint64{}
+	for q := uint64(2); q <= uint64(math.Sqrt(float64(n))); q++ {
+		if n%q == 0 {
+			factors = append(factors, q)
+			for n%q == 0 {
+				n /= q
+			}
+		}
+	}
+
+	if n > 1 {
+		factors = append(factors, n)
+	}
+
+	return factors
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+
+	sm "mc.service/models"
+)
+
+// TestSobolSequence checks the first points of the unscrambled sequence and that scrambling keeps every dimension stratified
+func TestSobolSequence(t *testing.T) {
+	plain := newSobolSequence(4, rand.New(rand.NewPCG(1, 0)), false)
+	point := make([]uint32, plain.Dimension)
+	expected := []float64{0, 0.5, 0.75, 0.25} // van der corput in gray code order
+	for i, e := range expected {
+		if i == 0 {
+			plain.Point(0, point)
+		} else {
+			plain.Next(uint64(i), point)
+		}
+
+		if got := float64(point[0]) / (1 << sobolBits); got != e {
+			t.Errorf("point %d: expected first coordinate %.4f, got %.4f", i, e, got)
+		}
+	}
+
+	nPoints := 1024
+	sobol, err := NewSobolSequence(50, 42)
+	if err != nil {
+		t.Fatalf("NewSobolSequence: %v", err)
+	}
+
+	// the first 2^k points put exactly one point in each of the 2^k bins of every dimension
+	seen := make([][]bool, sobol.Dimension)
+	for j := range seen {
+		seen[j] = make([]bool, nPoints)
+	}
+
+	point = make([]uint32, sobol.Dimension)
+	jumped := make([]uint32, sobol.Dimension)
+	normals := make([]float64, 0, nPoints*sobol.Dimension)
+	for i := range nPoints {
+		if i == 0 {
+			sobol.Point(0, point)
+		} else {
+			sobol.Next(uint64(i), point)
+		}
+
+		sobol.Point(uint64(i), jumped)
+		for j, x := range point {
+			if jumped[j] != x {
+				t.Fatalf("point %d dimension %d: expected stepping and jumping to agree, got %d and %d", i, j, x, jumped[j])
+			}
+
+			bin := x >> (sobolBits - 10)
+			if seen[j][bin] {
+				t.Fatalf("point %d dimension %d: bin %d already has a point", i, j, bin)
+			}
+			seen[j][bin] = true
+			normals = append(normals, distuv.UnitNormal.Quantile(sobolUniform(x)))
+		}
+	}
+
+	mean, std := stat.MeanStdDev(normals, nil)
+	if math.Abs(mean) > 1e-3 || math.Abs(std-1) > 1e-2 {
+		t.Errorf("Expected quasi random normals with mean 0 and std dev 1, got %.5f and %.5f", mean, std)
+	}
+
+	other, _ := NewSobolSequence(50, 43)
+	otherPoint := make([]uint32, other.Dimension)
+	other.Point(1, otherPoint)
+	if otherPoint[0] == point[0] {
+		t.Error("Expected a different seed to scramble the points differently")
+	}
+
+	if _, err := NewSobolSequence(MaxSobolDimension+1, 42); err == nil {
+		t.Error("Expected an error past the max sobol dimension")
+	}
+}
+
+// TestRunMonteCarloSimulation_Sobol makes sure sobol runs are repeatable regardless of how jobs land on workers
+func TestRunMonteCarloSimulation_Sobol(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*100)
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Monthly,
+		SimulationDuration:   60,
+		Iterations:           2*BatchSize + 500,
+		Seed:                 42,
+		RandomSource:         sm.Sobol,
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	sc := &ServiceContext{Context: context.Background()}
+	first, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	second, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	for i := range first {
+		if first[i].FinalValue != second[i].FinalValue {
+			t.Fatalf("result[%d]: expected the same final value across runs, got %.6f and %.6f", i, first[i].FinalValue, second[i].FinalValue)
+		}
+	}
+
+	// buy and hold has a known mean, the quasi random estimate should be well inside the pseudo random error
+	sr.Rebalance = RebalancePolicy{Policy: sm.BuyAndHold}
+	res, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	errors, mean := calculateStandardErrors(aggregateResults(res, sr, settings), sr, settings)
+	expected := getControlVariateExpectation(sr, settings)
+	t.Logf("Sobol mean: %.4f, expected: %.4f, naive standard error: %.4f", mean, expected, errors.MeanFinalValueNaive)
+	if math.Abs(mean-expected) > errors.MeanFinalValueNaive {
+		t.Errorf("Expected the sobol mean %.4f within one naive standard error of %.4f", mean, expected)
+	}
+
+	settings.DistType = sm.HistoricalBootstrap
+	settings.BlockLength = 5
+	if _, err := GetStatisticalResources(seriesReturns, settings); err == nil {
+		t.Error("Expected an error using sobol points with historical bootstrap")
+	}
+}
@@ -2,8 +2,10 @@ package core
 
 import (
 	"fmt"
+	"log"
 	"math"
 	"math/rand/v2"
+	"slices"
 
 	"gonum.org/v1/gonum/mat"
 	"gonum.org/v1/gonum/stat"
@@ -19,43 +21,230 @@ type WorkerResource struct {
 	*StatisticalResources // embed read only shared data
 	normalDist            distuv.Normal
 	tDist                 distuv.StudentsT
+	copulaTDist           distuv.StudentsT  // only used for the cdf in the t copula
+	chiSquaredDist        distuv.ChiSquared // shared mixing variable for multivariate t and t copula
+	rng                   *rand.Rand
+	src                   *rand.PCG // reseeded at the start of every path
+	seed                  uint64
+	stream                uint64    // stream of the current path, the path index or the antithetic pair index
+	antithetic            bool      // true while replaying a path with negated normal shocks
+	bootstrapRow          int       // current row of the historical returns for the bootstrap, -1 when a path starts
+	conditionalVariance   []float64 // per period garch variance for each asset, carried across periods within a path
+	regime                int       // current regime of the path for regime switching
+	regimePeriods         []int     // number of periods the current path has spent in each regime
+	sobolPoint            []uint32  // current sobol point, nil when the random source is pseudo random
+	sobolIndex            int       // index of the current sobol point, -1 before the first
+	sobolCoordinate       int       // next coordinate of the point to use, moves along assets then periods
+	normals               []float64 // independent normals of the current period, the benchmark's own assets load on them
+	mixing                float64   // mixing variable of the current period for multivariate t and t copula
+	marketJump            float64   // market jump of the current period, 0 without market jumps
+	benchmarkSrc          *rand.PCG // the benchmark's own stream, nil unless the benchmark holds assets outside the scenario
+	benchmarkNormal       distuv.Normal
+	benchmarkReturns      []float64
 }
 
 type StatisticalResources struct {
 	CovMatrix     *mat.SymDense // covariance matrix for std normal dist
-	CorrMatrix    *mat.SymDense // correlation matrix for student t dist
+	CorrMatrix    *mat.SymDense // correlation matrix for student t dists
 	CholeskyL     *mat.TriDense // cholesky of covariance (std normal dist)
-	CholeskyCorrL *mat.TriDense // cholesky of correlation (student t dist)
+	CholeskyCorrL *mat.TriDense // cholesky of correlation (student t dists)
 	AssetWeight   []float64
 	Mu            []float64 // annualized
 	Sigma         []float64 // annualized
 	DistType      int
 	Df            int
+	CopulaDf      int // degrees of freedom of the t copula, marginals use Df
+
+	CovarianceShrinkage float64                     // weight the covariance estimator put on its shrinkage target, 0 without shrinkage
+	CorrelationRepair   sm.CorrelationRepairSummary // how far the correlation matrix was moved to make it positive definite
+	Assumptions         []sm.AssumptionSummary      // nil unless capital market assumptions were applied
+	BlackLitterman      *sm.BlackLittermanSummary   // nil unless mu came from black-litterman
+
+	HistoricalReturns [][]float64 // rows are observations, columns are assets (historical bootstrap)
+	BlockLength       int         // mean block length for the stationary bootstrap
+
+	VolatilityModel int
+	Garch           []GarchModel // per asset, assets where the fit failed use constant Sigma
+
+	RegimeModel *RegimeModel   // nil when regime switching is not used
+	Jumps       *JumpResources // nil when jumps are not used
+	Sobol       *SobolSequence // nil when the normal shocks are pseudo random
+
+	Shocks map[int][]float64 // deterministic log returns per asset keyed by the 0 based period, nil without shocks
+
+	Rebalance RebalancePolicy // from the scenario, zero value rebalances every period
+
+	Benchmark *BenchmarkResources // nil unless the run is against a benchmark
 }
 
-// Called in the go routine and have seeds respectively set for each
-func NewWorkerResources(shared *StatisticalResources, seed, iterable uint64) *WorkerResource {
-	rng := new(rand.PCG)
-	if seed != 0 {
-		rng = rand.NewPCG(seed, iterable)
-	}
+// Called in the go routine, the source is reseeded from the seed and the path index for every path
+// so a path draws the same numbers whichever worker, job or round runs it
+func NewWorkerResources(shared *StatisticalResources, seed uint64) *WorkerResource {
+	rng := rand.NewPCG(seed, 0)
 
 	tDist := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: float64(shared.Df), Src: rng}
 	normalDist := distuv.Normal{Mu: 0, Sigma: 1, Src: rng}
+	copulaTDist := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: float64(shared.CopulaDf)}
+
+	// the mixing variable has the degrees of freedom of the joint distribution, which is the copula for the t copula
+	mixingDf := shared.Df
+	if shared.DistType == sm.StudentTCopula {
+		mixingDf = shared.CopulaDf
+	}
+	chiSquaredDist := distuv.ChiSquared{K: float64(mixingDf), Src: rng}
 
-	return &WorkerResource{
+	wr := &WorkerResource{
 		StatisticalResources: shared,
 		tDist:                tDist,
 		normalDist:           normalDist,
+		copulaTDist:          copulaTDist,
+		chiSquaredDist:       chiSquaredDist,
+		rng:                  rand.New(rng),
+		src:                  rng,
+		seed:                 seed,
+		bootstrapRow:         -1,
+		sobolIndex:           -1,
+	}
+
+	if shared.Sobol != nil {
+		wr.sobolPoint = make([]uint32, shared.Sobol.Dimension)
+	}
+
+	if shared.VolatilityModel == sm.Garch {
+		wr.conditionalVariance = make([]float64, len(shared.Garch))
+	}
+
+	if shared.RegimeModel != nil {
+		wr.regimePeriods = make([]int, len(shared.RegimeModel.Regimes))
+	}
+
+	if shared.Benchmark != nil && len(shared.Benchmark.AssetIds) > 0 {
+		wr.benchmarkSrc = rand.NewPCG(seed, 0)
+		wr.benchmarkNormal = distuv.Normal{Mu: 0, Sigma: 1, Src: wr.benchmarkSrc}
+	}
+
+	wr.ResetPath(0)
+	return wr
+}
+
+// ResetPath seeds the source with the path's own stream and clears any state carried between periods, called at the start of every simulated path
+func (wr *WorkerResource) ResetPath(stream uint64) {
+	wr.stream = stream
+	wr.src.Seed(wr.seed, splitMix64(stream)) // scrambled so neighbouring paths do not start from neighbouring pcg states
+	wr.antithetic = false
+	wr.resetPathState()
+}
+
+// ResetAntitheticPath replays the previous path's stream with the normal shocks negated.
+// every other draw (mixing variable, regimes, jumps) repeats exactly, so the pair only differs in the sign of the shocks.
+func (wr *WorkerResource) ResetAntitheticPath() {
+	wr.src.Seed(wr.seed, splitMix64(wr.stream))
+	wr.antithetic = true
+	wr.resetPathState()
+}
+
+func (wr *WorkerResource) resetPathState() {
+	wr.bootstrapRow = -1
+	wr.sobolCoordinate = 0
+
+	// the benchmark's stream is keyed off a scrambled seed, so it never lines up with the stream of any path
+	if wr.benchmarkSrc != nil {
+		wr.benchmarkSrc.Seed(splitMix64(wr.seed), splitMix64(wr.stream))
+	}
+
+	// every path starts garch at the long run variance, then the variance wanders from there
+	for i, g := range wr.Garch {
+		if g.Fitted {
+			wr.conditionalVariance[i] = g.LongRunVariance()
+		}
+	}
+
+	// paths start in a regime drawn from the long run regime probabilities
+	if wr.RegimeModel != nil {
+		wr.regime = wr.drawRegime(wr.RegimeModel.StationaryDistribution)
+		clear(wr.regimePeriods)
 	}
 }
 
+// SetSobolPoint moves to the index'th sobol point, called before the path is reset. points are indexed by path (or pair), not by worker,
+// so every path gets the same point no matter which worker runs it. consecutive indexes step the point, anything else jumps to it.
+func (wr *WorkerResource) SetSobolPoint(index int) {
+	if wr.sobolPoint == nil || index == wr.sobolIndex {
+		return
+	}
+
+	if index > 0 && index == wr.sobolIndex+1 {
+		wr.Sobol.Next(uint64(index), wr.sobolPoint)
+	} else {
+		wr.Sobol.Point(uint64(index), wr.sobolPoint)
+	}
+	wr.sobolIndex = index
+}
+
+// RegimePeriods returns a copy of the number of periods the current path has spent in each regime, nil without regime switching
+func (wr *WorkerResource) RegimePeriods() []int {
+	return slices.Clone(wr.regimePeriods)
+}
+
+// drawRegime picks a regime given the probability of each
+func (wr *WorkerResource) drawRegime(probabilities []float64) int {
+	u := wr.rng.Float64()
+	cumulative := 0.0
+	for k, p := range probabilities {
+		cumulative += p
+		if u < cumulative {
+			return k
+		}
+	}
+	return len(probabilities) - 1
+}
+
+// validateStatisticalSettings checks what GetStatisticalResources needs from the request alone, so a run can check them before it is recorded
+func validateStatisticalSettings(settings sm.SimulationRequestSettings) error {
+	if sm.IsStudentT(settings.DistType) {
+		// student t shocks are rescaled to unit variance, which is only finite for more than 2 degrees of freedom
+		if settings.DegreesOfFreedom <= 2 {
+			return fmt.Errorf("degrees of freedom must be greater than 2 for student t, got %d", settings.DegreesOfFreedom)
+		}
+
+		// the copula defaults to the marginal degrees of freedom
+		if settings.CopulaDegreesOfFreedom < 0 {
+			return fmt.Errorf("copula degrees of freedom must be positive, got %d", settings.CopulaDegreesOfFreedom)
+		}
+	}
+
+	if err := validateAssumptionSettings(settings); err != nil {
+		return err
+	}
+
+	if err := validateBlackLittermanSettings(settings); err != nil {
+		return err
+	}
+
+	if err := validateJumpSettings(settings); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func GetStatisticalResources(seriesReturns []*SeriesReturns, settings sm.SimulationRequestSettings) (*StatisticalResources, error) {
 	var err error
 
 	sr := &StatisticalResources{
-		DistType: settings.DistType,
-		Df:       settings.DegreesOfFreedom,
+		DistType:        settings.DistType,
+		Df:              settings.DegreesOfFreedom,
+		CopulaDf:        settings.CopulaDegreesOfFreedom,
+		BlockLength:     settings.BlockLength,
+		VolatilityModel: settings.VolatilityModel,
+	}
+
+	if err := validateStatisticalSettings(settings); err != nil {
+		return nil, err
+	}
+
+	if sm.IsStudentT(settings.DistType) && sr.CopulaDf == 0 {
+		sr.CopulaDf = sr.Df
 	}
 
 	returns := make([][]float64, len(seriesReturns))
@@ -63,45 +252,187 @@ func GetStatisticalResources(seriesReturns []*SeriesReturns, settings sm.Simulat
 		returns[i] = r.Returns
 	}
 
-	sr.CovMatrix = GetCovarianceMatrix(returns)
-	sr.CholeskyL, err = GetCholeskyDecomposition(sr.CovMatrix)
+	estimate, err := getEstimate(returns, settings)
 	if err != nil {
 		return nil, err
 	}
+	if estimate.Shrinkage > 0 {
+		log.Printf("%s covariance shrunk %.1f%% toward its target", sm.CovarianceEstimatorToString(settings.CovarianceEstimator), estimate.Shrinkage*100)
+	}
+
+	sr.CovMatrix = estimate.Covariance
+	sr.CovarianceShrinkage = estimate.Shrinkage
 
+	// sigma comes from the estimated covariance so the volatilities and the correlations agree
 	sr.AssetWeight = make([]float64, len(returns))
 	sr.Mu = make([]float64, len(returns))
 	sr.Sigma = make([]float64, len(returns))
 	for i, r := range seriesReturns {
 		sr.AssetWeight[i] = r.Weight
-		sr.Mu[i] = stat.Mean(r.Returns, nil) * float64(r.AnnualizationFactor)
-		sr.Sigma[i] = stat.StdDev(r.Returns, nil) * math.Sqrt(float64(r.AnnualizationFactor))
+		sr.Mu[i] = estimate.Mean[i] * float64(r.AnnualizationFactor)
+		sr.Sigma[i] = math.Sqrt(sr.CovMatrix.At(i, i) * float64(r.AnnualizationFactor))
+	}
+
+	// assumptions move mu, sigma and the correlations, a repair only moves the correlations.
+	// black-litterman goes last since it needs a positive definite covariance, it replaces mu and moves everything else
+	sr.CorrMatrix = GetCorrelationMatrix(sr.CovMatrix)
+	if settings.Assumptions != nil {
+		sr.Assumptions = applyCapitalMarketAssumptions(sr, seriesReturns, settings)
+	}
+	if sr.CorrMatrix, sr.CorrelationRepair, err = repairCorrelationMatrix(sr.CorrMatrix, settings); err != nil {
+		return nil, err
+	}
+	if settings.BlackLitterman != nil {
+		if sr.BlackLitterman, err = applyBlackLitterman(sr, seriesReturns, *settings.BlackLitterman); err != nil {
+			return nil, err
+		}
+	}
+	if sr.Assumptions != nil || sr.CorrelationRepair.Repaired || sr.BlackLitterman != nil {
+		sr.CovMatrix = GetCovarianceMatrixFromCorrelation(sr.CorrMatrix, getPeriodStandardDeviations(sr, seriesReturns))
+	}
+
+	sr.CholeskyL, err = GetCholeskyDecomposition(sr.CovMatrix)
+	if err != nil {
+		return nil, err
 	}
 
 	// Correlation Cholesky: used for StandardNormal (correlated N(0,1) then scale by sigma)
-	// and for StudentT (Gaussian copula). Covariance Cholesky is in daily units so would
+	// and for the student t dists (copulas and multivariate t). Covariance Cholesky is in daily units so would
 	// double-scale if used in CalculateLogNormalReturn.
-	sr.CorrMatrix = GetCorrelationMatrix(sr.CovMatrix)
 	sr.CholeskyCorrL, err = GetCholeskyDecomposition(sr.CorrMatrix)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compute correlation Cholesky: %w", err)
 	}
 
-	if settings.DistType != sm.StudentT {
+	if !sm.IsStudentT(settings.DistType) {
 		sr.CorrMatrix = nil // leave nil for StandardNormal for API clarity
 	}
 
+	if settings.DistType == sm.HistoricalBootstrap {
+		if sr.HistoricalReturns, err = getHistoricalReturnRows(seriesReturns, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	if settings.VolatilityModel == sm.Garch {
+		if sr.Garch, err = getGarchModels(seriesReturns, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	if settings.Regimes != nil {
+		if sr.RegimeModel, err = getRegimeModel(seriesReturns, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	if settings.Jumps != nil {
+		if sr.Jumps, err = getJumpResources(seriesReturns, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	if sr.Sobol, err = getSobolSequence(len(seriesReturns), settings); err != nil {
+		return nil, err
+	}
+
+	if len(settings.Shocks) > 0 {
+		if sr.Shocks, err = getShockOverlay(seriesReturns, settings); err != nil {
+			return nil, err
+		}
+	}
+
 	return sr, nil
 }
 
+// getGarchModels fits a garch(1,1) per asset, if a fit fails we log it and that asset falls back to constant volatility
+func getGarchModels(seriesReturns []*SeriesReturns, settings sm.SimulationRequestSettings) ([]GarchModel, error) {
+	if settings.DistType == sm.HistoricalBootstrap {
+		return nil, fmt.Errorf("garch volatility is not supported with historical bootstrap, the bootstrap already draws historical volatility")
+	}
+
+	models := make([]GarchModel, len(seriesReturns))
+	for i, r := range seriesReturns {
+		// the variance recursion is per period of the history, so we can only step it forward in that same unit of time
+		if r.AnnualizationFactor != settings.SimulationUnitOfTime {
+			return nil, fmt.Errorf("garch requires the simulation unit of time (%s) to match the return history (%s)",
+				sm.ConvertFrequencyToString(settings.SimulationUnitOfTime), sm.ConvertFrequencyToString(r.AnnualizationFactor))
+		}
+
+		model, err := FitGarch(r.Returns)
+		if err != nil {
+			log.Printf("garch fit failed for asset %d, falling back to constant volatility: %v", r.AssetId, err)
+			continue
+		}
+
+		models[i] = model
+	}
+
+	return models, nil
+}
+
+// getHistoricalReturnRows transposes the series returns so each row is a single cross section in time.
+// Resampling whole rows keeps the cross asset dependence, so we dont need the correlation matrix at all.
+func getHistoricalReturnRows(seriesReturns []*SeriesReturns, settings sm.SimulationRequestSettings) ([][]float64, error) {
+	if settings.BlockLength < 1 {
+		return nil, fmt.Errorf("block length must be at least 1 for historical bootstrap, got %d", settings.BlockLength)
+	}
+
+	nObservations := len(seriesReturns[0].Returns)
+	for _, r := range seriesReturns {
+		// returns are drawn as is, so the history needs to be in the same unit of time we are simulating
+		if r.AnnualizationFactor != settings.SimulationUnitOfTime {
+			return nil, fmt.Errorf("historical bootstrap requires the simulation unit of time (%s) to match the return history (%s)",
+				sm.ConvertFrequencyToString(settings.SimulationUnitOfTime), sm.ConvertFrequencyToString(r.AnnualizationFactor))
+		}
+
+		if len(r.Returns) != nObservations {
+			return nil, fmt.Errorf("historical bootstrap requires the same number of observations per asset")
+		}
+	}
+
+	rows := make([][]float64, nObservations)
+	for i := range nObservations {
+		rows[i] = make([]float64, len(seriesReturns))
+		for j, r := range seriesReturns {
+			rows[i][j] = r.Returns[i]
+		}
+	}
+
+	return rows, nil
+}
+
 // GetCorrelatedReturns generates one set of correlated returns
 // This is goroutine-safe as long as each goroutine has its own WorkerResources
 func (wr *WorkerResource) GetCorrelatedReturns(simulationUnitOfTime int) []float64 {
+	returns := wr.generateReturns(simulationUnitOfTime)
+
+	wr.marketJump = 0
+	if wr.Jumps != nil {
+		wr.addJumps(returns, simulationUnitOfTime)
+	}
+
+	// returns for this period came from the current regime, then the chain moves for the next period
+	if wr.RegimeModel != nil {
+		wr.regimePeriods[wr.regime]++
+		wr.regime = wr.drawRegime(wr.RegimeModel.TransitionMatrix[wr.regime])
+	}
+
+	return returns
+}
+
+func (wr *WorkerResource) generateReturns(simulationUnitOfTime int) []float64 {
 	switch wr.DistType {
 	case sm.StandardNormal:
 		return wr.generateNormalReturns(simulationUnitOfTime)
 	case sm.StudentT:
 		return wr.generateTReturns(simulationUnitOfTime)
+	case sm.HistoricalBootstrap:
+		return wr.generateBootstrapReturns()
+	case sm.MultivariateStudentT:
+		return wr.generateMultivariateTReturns(simulationUnitOfTime)
+	case sm.StudentTCopula:
+		return wr.generateTCopulaReturns(simulationUnitOfTime)
 	default:
 		return nil
 	}
@@ -117,42 +448,159 @@ func (wr *WorkerResource) generateNormalReturns(simulationUnitOfTime int) []floa
 
 	correlatedReturns := make([]float64, n)
 	for i := range n {
-		correlatedReturns[i] = CalculateLogNormalReturn(wr.Mu[i], wr.Sigma[i], correlatedZ.AtVec(i), simulationUnitOfTime)
+		correlatedReturns[i] = wr.calculateReturn(i, correlatedZ.AtVec(i), simulationUnitOfTime)
 	}
 
 	return correlatedReturns
 }
 
-// generateTReturns generates correlated Student's t returns using Gaussian copula
+// generateTReturns generates correlated Student's t returns using Gaussian copula.
+// Note this has t marginals but no tail dependence, assets do not crash together any more than they would under normal.
 func (wr *WorkerResource) generateTReturns(simulationUnitOfTime int) []float64 {
 	n := len(wr.Mu)
 	correlatedZ := wr.generateCorrelatedRandomVector(n)
+	scale := studentTVarianceScale(wr.Df)
 
 	// gaussian copula transformation
 	// https://colab.research.google.com/github/tensorflow/probability/blob/main/tensorflow_probability/examples/jupyter_notebooks/Gaussian_Copula.ipynb#scrollTo=1kSHqIp0GaRh
 	correlatedReturns := make([]float64, n)
 	for i := range n {
-		u := wr.normalDist.CDF(correlatedZ.AtVec(i)) // transform to uniform [0,1]
-		tValue := wr.tDist.Quantile(u)               // transform to t-distributed
-		correlatedReturns[i] = CalculateLogNormalReturn(wr.Mu[i], wr.Sigma[i], tValue, simulationUnitOfTime)
+		u := clampUniform(wr.normalDist.CDF(correlatedZ.AtVec(i))) // transform to uniform [0,1]
+		tValue := wr.tDist.Quantile(u) * scale                     // transform to t-distributed, rescaled to unit variance
+		correlatedReturns[i] = wr.calculateReturn(i, tValue, simulationUnitOfTime)
 	}
 
 	return correlatedReturns
 }
 
+// generateMultivariateTReturns generates returns from a true multivariate Student's t.
+// every asset is divided by the same chi square mixing variable, so when the draw is small all of the shocks blow up together,
+// which is the tail dependence (joint crashes) the gaussian copula is missing.
+func (wr *WorkerResource) generateMultivariateTReturns(simulationUnitOfTime int) []float64 {
+	n := len(wr.Mu)
+	correlatedZ := wr.generateCorrelatedRandomVector(n)
+	mixing := wr.getMixingVariable()
+	wr.mixing = mixing
+	scale := studentTVarianceScale(wr.Df)
+
+	correlatedReturns := make([]float64, n)
+	for i := range n {
+		tValue := correlatedZ.AtVec(i) / mixing * scale
+		correlatedReturns[i] = wr.calculateReturn(i, tValue, simulationUnitOfTime)
+	}
+
+	return correlatedReturns
+}
+
+// generateTCopulaReturns generates returns using a t copula (dependence from a multivariate t with CopulaDf)
+// and t marginals with Df. When both degrees of freedom match, this is the same as the multivariate t.
+func (wr *WorkerResource) generateTCopulaReturns(simulationUnitOfTime int) []float64 {
+	n := len(wr.Mu)
+	correlatedZ := wr.generateCorrelatedRandomVector(n)
+	mixing := wr.getMixingVariable()
+	wr.mixing = mixing
+	scale := studentTVarianceScale(wr.Df)
+
+	correlatedReturns := make([]float64, n)
+	for i := range n {
+		u := clampUniform(wr.copulaTDist.CDF(correlatedZ.AtVec(i) / mixing)) // transform to uniform [0,1] with the copula
+		tValue := wr.tDist.Quantile(u) * scale                               // transform to the t marginal, rescaled to unit variance
+		correlatedReturns[i] = wr.calculateReturn(i, tValue, simulationUnitOfTime)
+	}
+
+	return correlatedReturns
+}
+
+// getMixingVariable draws sqrt(W/df) where W is chi square, dividing a standard normal by this gives a student t
+func (wr *WorkerResource) getMixingVariable() float64 {
+	return math.Sqrt(wr.chiSquaredDist.Rand() / wr.chiSquaredDist.K)
+}
+
+// studentTVarianceScale rescales a t variate to unit variance, a t with df degrees of freedom has variance df/(df-2).
+// this keeps sigma meaning annualized volatility regardless of dist type.
+func studentTVarianceScale(df int) float64 {
+	return math.Sqrt(float64(df-2) / float64(df))
+}
+
+// clampUniform keeps a uniform draw away from 0 and 1 so quantile functions dont return +/- infinity
+func clampUniform(u float64) float64 {
+	const eps = 1e-12
+	return math.Min(math.Max(u, eps), 1-eps)
+}
+
+// generateBootstrapReturns draws a single period of historical returns using the stationary bootstrap (politis & romano).
+// with probability 1/BlockLength a new block starts at a random row, otherwise we walk forward to the next row (wrapping around),
+// so block lengths are geometric with mean BlockLength and fat tails, skew, and serial/cross dependence come from the data.
+func (wr *WorkerResource) generateBootstrapReturns() []float64 {
+	nObservations := len(wr.HistoricalReturns)
+
+	if wr.bootstrapRow < 0 || wr.rng.Float64() < 1/float64(wr.BlockLength) {
+		wr.bootstrapRow = wr.rng.IntN(nObservations)
+	} else {
+		wr.bootstrapRow = (wr.bootstrapRow + 1) % nObservations
+	}
+
+	// clone so callers can not modify the shared history
+	return slices.Clone(wr.HistoricalReturns[wr.bootstrapRow])
+}
+
 func (wr *WorkerResource) generateCorrelatedRandomVector(n int) *mat.VecDense {
 	z := make([]float64, n)
 	for i := range n {
-		z[i] = wr.normalDist.Rand()
+		z[i] = wr.getStandardNormal()
+		if wr.antithetic {
+			z[i] = -z[i]
+		}
+	}
+
+	// each regime has its own correlation
+	L := wr.CholeskyCorrL
+	if wr.RegimeModel != nil {
+		L = wr.RegimeModel.Regimes[wr.regime].CholeskyCorrL
 	}
 
-	// L can be either correlated or covariance depending on the distribution
+	wr.normals = z
+
 	zVec := mat.NewVecDense(n, z)
 	correlatedZ := mat.NewVecDense(n, nil)
-	correlatedZ.MulVec(wr.CholeskyCorrL, zVec) // correlated z = chol L * rng variables
+	correlatedZ.MulVec(L, zVec) // correlated z = chol L * rng variables
 	return correlatedZ
 }
 
+// getStandardNormal draws the next shock, from the path's sobol point through the inverse normal cdf or from the pseudo random source
+func (wr *WorkerResource) getStandardNormal() float64 {
+	if wr.sobolPoint == nil {
+		return wr.normalDist.Rand()
+	}
+
+	u := sobolUniform(wr.sobolPoint[wr.sobolCoordinate])
+	wr.sobolCoordinate++
+	return distuv.UnitNormal.Quantile(u)
+}
+
+// calculateReturn turns a unit variance shock into a log return for asset i.
+// with regimes the mu and sigma come from the path's current regime, with garch the volatility comes from the path's conditional variance, which is then stepped forward with the shock.
+func (wr *WorkerResource) calculateReturn(i int, shock float64, simulationUnitOfTime int) float64 {
+	if wr.RegimeModel != nil {
+		regime := wr.RegimeModel.Regimes[wr.regime]
+		return CalculateLogNormalReturn(regime.Mu[i], regime.Sigma[i], shock, simulationUnitOfTime)
+	}
+
+	if wr.conditionalVariance == nil || !wr.Garch[i].Fitted {
+		sigma := wr.Sigma[i]
+		if wr.Jumps != nil && wr.Jumps.DiffusionSigma != nil {
+			sigma = wr.Jumps.DiffusionSigma[i] // the jumps add the rest of the variance
+		}
+		return CalculateLogNormalReturn(wr.Mu[i], sigma, shock, simulationUnitOfTime)
+	}
+
+	variance := wr.conditionalVariance[i]
+	sigma := math.Sqrt(variance * float64(simulationUnitOfTime)) // annualize so it lines up with mu
+	wr.conditionalVariance[i] = wr.Garch[i].NextVariance(variance, shock)
+
+	return CalculateLogNormalReturn(wr.Mu[i], sigma, shock, simulationUnitOfTime)
+}
+
 func CalculateLogNormalReturn(mu, sigma, rng float64, annualizationFactor int) float64 {
 	return (mu-0.5*math.Pow(sigma, 2))/float64(annualizationFactor) + (sigma * rng / math.Sqrt(float64(annualizationFactor)))
 }
@@ -180,6 +628,20 @@ func GetCorrelationMatrix(covMatrix *mat.SymDense) *mat.SymDense {
 	return corrMatrix
 }
 
+// GetCovarianceMatrixFromCorrelation scales the correlations by the standard deviations, the covariance is in the units of the standard deviations
+func GetCovarianceMatrixFromCorrelation(corrMatrix *mat.SymDense, stdDevs []float64) *mat.SymDense {
+	n := corrMatrix.SymmetricDim()
+	res := mat.NewSymDense(n, nil)
+
+	for i := range n {
+		for j := range i + 1 {
+			res.SetSym(i, j, corrMatrix.At(i, j)*stdDevs[i]*stdDevs[j])
+		}
+	}
+
+	return res
+}
+
 func GetCholeskyDecomposition(covMatrix *mat.SymDense) (*mat.TriDense, error) {
 	chol := new(mat.Cholesky)
 	if ok := chol.Factorize(covMatrix); !ok {
@@ -3,6 +3,7 @@ package core
 import (
 	"math"
 	"math/rand/v2"
+	"slices"
 	"testing"
 	"time"
 
@@ -161,7 +162,7 @@ func TestStatisticalResourcesWorkerCorrelatedReturnsForStandardNormal(t *testing
 		t.Fatalf("Failed to create StatisticalResources: %v", err)
 	}
 
-	worker := NewWorkerResources(sr, 42, 0)
+	worker := NewWorkerResources(sr, 42)
 
 	allReturns := make([][]float64, nSamples)
 	for i := range nSamples {
@@ -197,11 +198,11 @@ func TestStatisticalResourcesWorkerCorrelatedReturnsForStudentT(t *testing.T) {
 
 	settings_normal := sm.SimulationRequestSettings{DistType: sm.StandardNormal}
 	sr_normal, _ := GetStatisticalResources(returns, settings_normal)
-	worker_normal := NewWorkerResources(sr_normal, 42, 0)
+	worker_normal := NewWorkerResources(sr_normal, 42)
 
 	settings_student_t := sm.SimulationRequestSettings{DistType: sm.StudentT, DegreesOfFreedom: 5}
 	sr_student_t, _ := GetStatisticalResources(returns, settings_student_t)
-	worker_student_t := NewWorkerResources(sr_student_t, 42, 1)
+	worker_student_t := NewWorkerResources(sr_student_t, 42)
 
 	normalReturns := make([]float64, nSamples)
 	tReturns := make([]float64, nSamples)
@@ -213,6 +214,124 @@ func TestStatisticalResourcesWorkerCorrelatedReturnsForStudentT(t *testing.T) {
 	// TODO: need to finish this at some point, but am going to work on the controller and front end to get some tangible results
 }
 
+func TestStatisticalResourcesWorkerCorrelatedReturnsForHistoricalBootstrap(t *testing.T) {
+	nSamples := sm.Daily * 500
+	returns := GenerateMockSeriesReturns(t, nSamples)
+	settings := sm.SimulationRequestSettings{DistType: sm.HistoricalBootstrap, SimulationUnitOfTime: sm.Daily, BlockLength: 10}
+
+	sr, err := GetStatisticalResources(returns, settings)
+	if err != nil {
+		t.Fatalf("Failed to create StatisticalResources: %v", err)
+	}
+
+	if len(sr.HistoricalReturns) != nSamples {
+		t.Fatalf("Expected %d historical rows, got %d", nSamples, len(sr.HistoricalReturns))
+	}
+
+	worker := NewWorkerResources(sr, 42)
+
+	// every draw should be a whole row out of the history, and consecutive rows should mostly be consecutive in time
+	continued := 0
+	previousRow := -1
+	assetReturns := make([]float64, nSamples)
+	for i := range nSamples {
+		draw := worker.GetCorrelatedReturns(sm.Daily)
+		if draw[0] != sr.HistoricalReturns[worker.bootstrapRow][0] || draw[1] != sr.HistoricalReturns[worker.bootstrapRow][1] {
+			t.Fatalf("Draw %d is not a row of the historical returns", i)
+		}
+
+		if previousRow >= 0 && worker.bootstrapRow == (previousRow+1)%nSamples {
+			continued++
+		}
+		previousRow = worker.bootstrapRow
+		assetReturns[i] = draw[0]
+	}
+
+	// stationary bootstrap continues a block with probability 1 - 1/L
+	continuedRatio := float64(continued) / float64(nSamples-1)
+	expectedRatio := 1 - 1/float64(settings.BlockLength)
+	if math.Abs(continuedRatio-expectedRatio) > 0.02 {
+		t.Errorf("Block continuation ratio: expected ~%.4f, got %.4f", expectedRatio, continuedRatio)
+	}
+
+	historicalMu := stat.Mean(returns[0].Returns, nil) * sm.Daily
+	bootstrapMu := stat.Mean(assetReturns, nil) * sm.Daily
+	if math.Abs(historicalMu-bootstrapMu) > 0.05 {
+		t.Errorf("Bootstrap mean differs too much: expected %.4f, got %.4f", historicalMu, bootstrapMu)
+	}
+
+	worker.ResetPath(1)
+	if worker.bootstrapRow != -1 {
+		t.Errorf("Expected bootstrap row to reset at the start of a path, got %d", worker.bootstrapRow)
+	}
+
+	// the history is drawn as is, so it cant be used for a different unit of time
+	settings.SimulationUnitOfTime = sm.Weekly
+	if _, err := GetStatisticalResources(returns, settings); err == nil {
+		t.Error("Expected an error when the simulation unit of time does not match the history")
+	}
+}
+
+func TestStatisticalResourcesWorkerCorrelatedReturnsForMultivariateStudentT(t *testing.T) {
+	nSamples := sm.Daily * 500
+	returns := GenerateMockSeriesReturns(t, nSamples)
+
+	jointTailProbability := func(distType int) float64 {
+		t.Helper()
+		settings := sm.SimulationRequestSettings{DistType: distType, DegreesOfFreedom: 4}
+		sr, err := GetStatisticalResources(returns, settings)
+		if err != nil {
+			t.Fatalf("Failed to create StatisticalResources: %v", err)
+		}
+
+		worker := NewWorkerResources(sr, 42)
+		assetA := make([]float64, nSamples)
+		assetB := make([]float64, nSamples)
+		for i := range nSamples {
+			draw := worker.GetCorrelatedReturns(sm.Daily)
+			assetA[i] = draw[0]
+			assetB[i] = draw[1]
+		}
+
+		// variance is rescaled, so sigma should still be the annualized volatility
+		simulatedSigma := stat.StdDev(assetA, nil) * math.Sqrt(sm.Daily)
+		if math.Abs(simulatedSigma-sr.Sigma[0]) > 0.02 {
+			t.Errorf("%s StdDev differs too much: expected %.4f, got %.4f", sm.DistTypeToString(distType), sr.Sigma[0], simulatedSigma)
+		}
+
+		// probability both assets are in their worst 1% together
+		cutoffA := stat.Quantile(0.01, stat.Empirical, sortedCopy(assetA), nil)
+		cutoffB := stat.Quantile(0.01, stat.Empirical, sortedCopy(assetB), nil)
+		joint := 0
+		for i := range nSamples {
+			if assetA[i] <= cutoffA && assetB[i] <= cutoffB {
+				joint++
+			}
+		}
+
+		return float64(joint) / float64(nSamples)
+	}
+
+	gaussianCopula := jointTailProbability(sm.StudentT)
+	multivariateT := jointTailProbability(sm.MultivariateStudentT)
+	tCopula := jointTailProbability(sm.StudentTCopula)
+
+	t.Logf("Joint 1%% tail probability - gaussian copula: %.5f, multivariate t: %.5f, t copula: %.5f", gaussianCopula, multivariateT, tCopula)
+
+	if multivariateT <= gaussianCopula {
+		t.Errorf("Expected multivariate t to have more tail dependence than the gaussian copula (%.5f <= %.5f)", multivariateT, gaussianCopula)
+	}
+
+	if tCopula <= gaussianCopula {
+		t.Errorf("Expected t copula to have more tail dependence than the gaussian copula (%.5f <= %.5f)", tCopula, gaussianCopula)
+	}
+
+	settings := sm.SimulationRequestSettings{DistType: sm.MultivariateStudentT, DegreesOfFreedom: 2}
+	if _, err := GetStatisticalResources(returns, settings); err == nil {
+		t.Error("Expected an error for 2 degrees of freedom, variance is not finite")
+	}
+}
+
 // Helper: Generate mock series returns
 func GenerateMockSeriesReturns(t *testing.T, n int) []*SeriesReturns {
 	t.Helper()
@@ -312,3 +431,10 @@ func calculateDriftAdjustedMu(t *testing.T, mu, sigma float64) float64 {
 	t.Helper()
 	return mu - 0.5*math.Pow(sigma, 2)
 }
+
+// Helper: Sorted copy of a slice for quantiles
+func sortedCopy(values []float64) []float64 {
+	res := slices.Clone(values)
+	slices.Sort(res)
+	return res
+}
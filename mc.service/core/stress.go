@@ -0,0 +1,292 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	dm "mc.data/models"
+	sm "mc.service/models"
+)
+
+const stressStartTolerance = 7 * 24 * time.Hour // the price history is weekly, so the first period can end up to a week after the window opens
+
+// stressWindow is a crisis window with its dates parsed
+type stressWindow struct {
+	sm.CrisisWindow
+	start time.Time
+	end   time.Time
+}
+
+// stressHistory is the log returns of every asset on the dates all of them have a return for, oldest first
+type stressHistory struct {
+	assetIds   []int32
+	firstDates []time.Time // first return of each asset, zero when the asset has none
+	dates      []time.Time
+	returns    [][]float64 // returns[t][i] is asset i's log return for the period ending dates[t]
+}
+
+// RunStressTest replays crisis windows of realized returns against the scenario's current weights and rebalance policy
+func (sc *ServiceContext) RunStressTest(scenarioID int32, request sm.StressTestRequest) (*sm.StressTestResponse, int, error) {
+	if request.InitialPortfolioValue < 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("initial portfolio value must not be negative, got %.2f", request.InitialPortfolioValue)
+	}
+
+	windows, err := getStressWindows(request, time.Now())
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	scenario, err := sc.PostgresConnection.GetScenarioByID(sc.Context, scenarioID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("error getting scenario: %v", err)
+	}
+
+	policy, err := getRebalancePolicy(scenario.ScenarioConfiguration)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	// sorted on asset id like the simulation, weights line up with the history by index
+	components := slices.Clone(scenario.Components)
+	slices.SortFunc(components, func(a, b dm.ScenarioConfigurationComponent) int {
+		return int(a.AssetId - b.AssetId)
+	})
+
+	assetIds := make([]int32, len(components))
+	weights := make([]float64, len(components))
+	for i, c := range components {
+		assetIds[i] = c.AssetId
+		weights[i] = c.Weight
+	}
+
+	earliest := windows[0].start
+	for _, w := range windows {
+		if w.start.Before(earliest) {
+			earliest = w.start
+		}
+	}
+
+	// everything from the earliest window on, the replays run past the end of their window to find the recovery
+	returns, err := sc.PostgresConnection.GetTimeSeriesReturns(sc.Context, assetIds, earliest.Add(-stressStartTolerance))
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("error getting time series returns: %v", err)
+	}
+
+	history := alignStressHistory(assetIds, returns)
+	res := &sm.StressTestResponse{
+		ScenarioId:            scenario.Id,
+		RebalancePolicy:       policy.Policy,
+		InitialPortfolioValue: request.GetInitialPortfolioValue(),
+		Results:               make([]sm.StressTestResult, len(windows)),
+	}
+
+	for i, w := range windows {
+		res.Results[i] = replayCrisisWindow(w, history, weights, policy, res.InitialPortfolioValue)
+		if res.Results[i].Error != "" {
+			log.Printf("Unable to replay %s for scenario %v: %s", w.Name, scenario.Name, res.Results[i].Error)
+		}
+	}
+
+	return res, http.StatusOK, nil
+}
+
+// getStressWindows resolves the library names and parses the custom windows, no windows at all runs the whole library
+func getStressWindows(request sm.StressTestRequest, now time.Time) ([]stressWindow, error) {
+	library := sm.GetCrisisWindows()
+	requested := make([]sm.CrisisWindow, 0, len(request.Windows)+len(request.CustomWindows))
+	for _, name := range request.Windows {
+		idx := slices.IndexFunc(library, func(w sm.CrisisWindow) bool { return strings.EqualFold(w.Name, name) })
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown crisis window %q", name)
+		}
+		requested = append(requested, library[idx])
+	}
+
+	for _, w := range request.CustomWindows {
+		if strings.TrimSpace(w.Name) == "" {
+			return nil, fmt.Errorf("name is required for every custom window")
+		}
+		requested = append(requested, w)
+	}
+
+	if len(requested) == 0 {
+		requested = library
+	}
+
+	res := make([]stressWindow, len(requested))
+	for i, w := range requested {
+		start, err := time.Parse(time.DateOnly, w.Start)
+		if err != nil {
+			return nil, fmt.Errorf("start of window %s must be a yyyy-mm-dd date, got %q", w.Name, w.Start)
+		}
+
+		end, err := time.Parse(time.DateOnly, w.End)
+		if err != nil {
+			return nil, fmt.Errorf("end of window %s must be a yyyy-mm-dd date, got %q", w.Name, w.End)
+		}
+
+		if !end.After(start) {
+			return nil, fmt.Errorf("window %s must end after it starts", w.Name)
+		}
+
+		if start.After(now) {
+			return nil, fmt.Errorf("window %s starts in the future", w.Name)
+		}
+
+		res[i] = stressWindow{CrisisWindow: w, start: start, end: end}
+	}
+
+	return res, nil
+}
+
+// alignStressHistory keeps the dates every asset has a return for, so the assets move together period by period
+func alignStressHistory(assetIds []int32, returns []*dm.TimeSeriesReturn) stressHistory {
+	index := make(map[int32]int, len(assetIds))
+	for i, id := range assetIds {
+		index[id] = i
+	}
+
+	res := stressHistory{
+		assetIds:   assetIds,
+		firstDates: make([]time.Time, len(assetIds)),
+	}
+
+	byDate := make(map[int64][]float64)
+	counts := make(map[int64]int)
+	for _, ret := range returns {
+		i, ok := index[ret.Id]
+		if !ok {
+			continue
+		}
+
+		if res.firstDates[i].IsZero() || ret.Timestamp.Before(res.firstDates[i]) {
+			res.firstDates[i] = ret.Timestamp
+		}
+
+		key := ret.Timestamp.Unix()
+		if byDate[key] == nil {
+			byDate[key] = make([]float64, len(assetIds))
+		}
+		byDate[key][i] = ret.LogReturn
+		counts[key]++
+	}
+
+	for key, count := range counts {
+		if count == len(assetIds) {
+			res.dates = append(res.dates, time.Unix(key, 0).UTC())
+		}
+	}
+
+	slices.SortFunc(res.dates, func(a, b time.Time) int {
+		return a.Compare(b)
+	})
+
+	res.returns = make([][]float64, len(res.dates))
+	for t, date := range res.dates {
+		res.returns[t] = byDate[date.Unix()]
+	}
+
+	return res
+}
+
+// replayCrisisWindow runs the portfolio through the window, then on through the rest of the history until it is back at the peak it fell from
+func replayCrisisWindow(window stressWindow, history stressHistory, weights []float64, policy RebalancePolicy, initialValue float64) sm.StressTestResult {
+	res := sm.StressTestResult{Window: window.CrisisWindow}
+
+	for i, first := range history.firstDates {
+		if first.IsZero() || first.After(window.start.Add(stressStartTolerance)) {
+			res.Error = fmt.Sprintf("asset %d has no price history at the start of the window", history.assetIds[i])
+			return res
+		}
+	}
+
+	from := slices.IndexFunc(history.dates, func(d time.Time) bool { return d.After(window.start) })
+	if from < 0 || history.dates[from].After(window.end) {
+		res.Error = "no price history inside the window"
+		return res
+	}
+
+	to := slices.IndexFunc(history.dates, func(d time.Time) bool { return d.After(window.end) })
+	if to < 0 {
+		to = len(history.dates) // the window runs to the end of the history, it may still be going
+	}
+
+	portfolio := NewPortfolio(weights, policy)
+	portfolio.Reset(initialValue)
+
+	res.Dates = []time.Time{window.start}
+	res.Values = []float64{initialValue}
+	peak, drawdownPeak, trough := 0, 0, 0
+	for t := from; t < to; t++ {
+		value := portfolio.Step(t-from, history.returns[t])
+		res.Dates = append(res.Dates, history.dates[t])
+		res.Values = append(res.Values, value)
+
+		period := len(res.Values) - 1
+		if value > res.Values[peak] {
+			peak = period
+		} else if drawdown := 1 - value/res.Values[peak]; drawdown > res.MaxDrawdown {
+			res.MaxDrawdown = drawdown
+			drawdownPeak, trough = peak, period
+		}
+	}
+
+	res.TotalReturn = res.Values[len(res.Values)-1]/initialValue - 1
+	res.PeakDate = res.Dates[drawdownPeak]
+	res.TroughDate = res.Dates[trough]
+	res.PeriodsToTrough = trough - drawdownPeak
+	res.Assets = calculateAssetStressResults(history, weights, from, to)
+
+	// a window without a drawdown has nothing to recover from
+	if res.MaxDrawdown == 0 {
+		res.Recovered = true
+		return res
+	}
+
+	target := res.Values[drawdownPeak]
+	for period := trough + 1; period < len(res.Values); period++ {
+		if res.Values[period] >= target {
+			setStressRecovery(&res, res.Dates[period], period-trough)
+			return res
+		}
+	}
+
+	for t := to; t < len(history.dates); t++ {
+		if portfolio.Step(t-from, history.returns[t]) >= target {
+			setStressRecovery(&res, history.dates[t], len(res.Values)-1-trough+t-to+1)
+			return res
+		}
+	}
+
+	return res
+}
+
+func setStressRecovery(res *sm.StressTestResult, date time.Time, periods int) {
+	res.Recovered = true
+	res.RecoveryDate = &date
+	res.PeriodsToRecovery = periods
+}
+
+// calculateAssetStressResults is each asset on its own through the periods [from, to) of the history
+func calculateAssetStressResults(history stressHistory, weights []float64, from, to int) []sm.AssetStressResult {
+	res := make([]sm.AssetStressResult, len(history.assetIds))
+	for i, id := range history.assetIds {
+		cumulative, peak := 0.0, 0.0
+		for t := from; t < to; t++ {
+			cumulative += history.returns[t][i]
+			peak = math.Max(peak, cumulative)
+			res[i].MaxDrawdown = math.Max(res[i].MaxDrawdown, 1-math.Exp(cumulative-peak))
+		}
+
+		res[i].AssetId = id
+		res[i].Weight = weights[i]
+		res[i].TotalReturn = math.Exp(cumulative) - 1
+	}
+
+	return res
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	dm "mc.data/models"
+	sm "mc.service/models"
+)
+
+// getMockStressHistory is two assets with weekly returns from the start of 2008, the first falls 10% a week for 4 weeks
+// then climbs 5% a week, the second is flat
+func getMockStressHistory() stressHistory {
+	start := time.Date(2008, 1, 4, 0, 0, 0, 0, time.UTC)
+	returns := make([]*dm.TimeSeriesReturn, 0)
+	for t := range 30 {
+		r := math.Log(1.05)
+		if t < 4 {
+			r = math.Log(0.9)
+		}
+
+		date := start.AddDate(0, 0, 7*t)
+		returns = append(returns,
+			&dm.TimeSeriesReturn{Id: 1, Timestamp: date, LogReturn: r},
+			&dm.TimeSeriesReturn{Id: 2, Timestamp: date, LogReturn: 0},
+		)
+	}
+
+	// a date only one asset has is dropped
+	returns = append(returns, &dm.TimeSeriesReturn{Id: 1, Timestamp: start.AddDate(0, 0, 1), LogReturn: math.Log(0.5)})
+
+	return alignStressHistory([]int32{1, 2}, returns)
+}
+
+func TestReplayCrisisWindow(t *testing.T) {
+	history := getMockStressHistory()
+	if len(history.dates) != 30 {
+		t.Fatalf("Expected 30 aligned dates, got %d", len(history.dates))
+	}
+
+	windows, err := getStressWindows(sm.StressTestRequest{
+		CustomWindows: []sm.CrisisWindow{{Name: "mock", Start: "2008-01-01", End: "2008-02-20"}},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("getStressWindows: %v", err)
+	}
+
+	weights := []float64{0.5, 0.5}
+	res := replayCrisisWindow(windows[0], history, weights, RebalancePolicy{Policy: sm.BuyAndHold}, 100)
+	if res.Error != "" {
+		t.Fatalf("replayCrisisWindow: %s", res.Error)
+	}
+
+	// buy and hold: half falls to 0.9^4 and the other half stays
+	expectedDrawdown := 0.5 * (1 - math.Pow(0.9, 4))
+	if math.Abs(res.MaxDrawdown-expectedDrawdown) > 1e-12 {
+		t.Errorf("Expected max drawdown %.6f, got %.6f", expectedDrawdown, res.MaxDrawdown)
+	}
+	if res.PeriodsToTrough != 4 || !res.PeakDate.Equal(windows[0].start) || !res.TroughDate.Equal(history.dates[3]) {
+		t.Errorf("Expected the trough 4 periods after the start, got %d periods (%v to %v)", res.PeriodsToTrough, res.PeakDate, res.TroughDate)
+	}
+	if len(res.Values) != 8 || res.Values[0] != 100 {
+		t.Errorf("Expected the start and 7 weekly values, got %v", res.Values)
+	}
+
+	// 0.9^4 needs 9 weeks of 5% to get back, which is past the end of the window
+	if !res.Recovered || res.PeriodsToRecovery != 9 || !res.RecoveryDate.Equal(history.dates[12]) {
+		t.Errorf("Expected a recovery 9 periods after the trough, got %v after %d periods", res.RecoveryDate, res.PeriodsToRecovery)
+	}
+
+	if math.Abs(res.Assets[0].MaxDrawdown-(1-math.Pow(0.9, 4))) > 1e-12 || res.Assets[1].MaxDrawdown != 0 {
+		t.Errorf("Expected the asset drawdowns on their own, got %+v", res.Assets)
+	}
+	if expected := math.Pow(0.9, 4)*math.Pow(1.05, 3) - 1; math.Abs(res.Assets[0].TotalReturn-expected) > 1e-12 {
+		t.Errorf("Expected asset 1 to return %.6f, got %.6f", expected, res.Assets[0].TotalReturn)
+	}
+
+	// rebalancing every period keeps buying into the fall, so the drawdown is 1 - 0.95^4
+	rebalanced := replayCrisisWindow(windows[0], history, weights, RebalancePolicy{Policy: sm.ContinuousRebalance}, 100)
+	if expected := 1 - math.Pow(0.95, 4); math.Abs(rebalanced.MaxDrawdown-expected) > 1e-12 {
+		t.Errorf("Expected a rebalanced max drawdown of %.6f, got %.6f", expected, rebalanced.MaxDrawdown)
+	}
+}
+
+func TestReplayCrisisWindow_MissingHistory(t *testing.T) {
+	history := getMockStressHistory()
+	windows, err := getStressWindows(sm.StressTestRequest{
+		CustomWindows: []sm.CrisisWindow{
+			{Name: "before", Start: "2007-06-01", End: "2007-09-01"},
+			{Name: "after", Start: "2020-02-19", End: "2020-03-23"},
+		},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("getStressWindows: %v", err)
+	}
+
+	for _, w := range windows {
+		if res := replayCrisisWindow(w, history, []float64{0.5, 0.5}, RebalancePolicy{}, 100); res.Error == "" {
+			t.Errorf("%s: expected an error without price history", w.Name)
+		}
+	}
+}
+
+func TestGetStressWindows(t *testing.T) {
+	windows, err := getStressWindows(sm.StressTestRequest{}, time.Now())
+	if err != nil {
+		t.Fatalf("getStressWindows: %v", err)
+	}
+	if len(windows) != len(sm.GetCrisisWindows()) {
+		t.Errorf("Expected the whole library, got %d windows", len(windows))
+	}
+
+	windows, _ = getStressWindows(sm.StressTestRequest{Windows: []string{"lehmanCollapse"}}, time.Now())
+	if len(windows) != 1 || windows[0].start != time.Date(2008, 9, 12, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("Expected the lehman window, got %+v", windows)
+	}
+
+	invalid := map[string]sm.StressTestRequest{
+		"unknown name":  {Windows: []string{"tulipMania"}},
+		"no name":       {CustomWindows: []sm.CrisisWindow{{Start: "2008-01-01", End: "2008-02-01"}}},
+		"bad date":      {CustomWindows: []sm.CrisisWindow{{Name: "x", Start: "01/01/2008", End: "2008-02-01"}}},
+		"ends first":    {CustomWindows: []sm.CrisisWindow{{Name: "x", Start: "2008-02-01", End: "2008-01-01"}}},
+		"in the future": {CustomWindows: []sm.CrisisWindow{{Name: "x", Start: "2999-01-01", End: "2999-02-01"}}},
+	}
+	for name, req := range invalid {
+		if _, err := getStressWindows(req, time.Now()); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/stat"
+
+	sm "mc.service/models"
+)
+
+const (
+	varBatches            = 20   // interleaved batches of paths for the batch standard errors of var and the risk metrics
+	quantileDensityWindow = 0.01 // probability either side of the quantile used to estimate the density for the naive var standard error
+)
+
+func validateVarianceReduction(statisticalResources *StatisticalResources, settings sm.SimulationRequestSettings) error {
+	if settings.Antithetic && statisticalResources.DistType == sm.HistoricalBootstrap {
+		return fmt.Errorf("antithetic paths are not supported with historical bootstrap, there are no shocks to negate")
+	}
+
+	if !settings.ControlVariate {
+		return nil
+	}
+
+	// the control variate needs a known expected value, which we only have for lognormal returns with constant parameters
+	if statisticalResources.DistType != sm.StandardNormal {
+		return fmt.Errorf("the control variate is only supported with the standard normal distribution")
+	}
+	if statisticalResources.VolatilityModel != sm.ConstantVolatility || statisticalResources.RegimeModel != nil {
+		return fmt.Errorf("the control variate is only supported with constant volatility and no regimes")
+	}
+	if len(settings.CashFlows) > 0 {
+		return fmt.Errorf("the control variate is not supported with cash flows")
+	}
+
+	return nil
+}
+
+// getControlVariateExpectation is the expected buy and hold terminal value under gbm, each period's log return has E[exp(r)] = exp(mu/unit).
+// compensated jumps have E[exp(jump)] = 1 so they do not change it.
+func getControlVariateExpectation(statisticalResources *StatisticalResources, settings sm.SimulationRequestSettings) float64 {
+	years := float64(settings.SimulationDuration) / float64(settings.SimulationUnitOfTime)
+	expected := 0.0
+	for i, w := range statisticalResources.AssetWeight {
+		expected += w * math.Exp(statisticalResources.Mu[i]*years)
+	}
+	return settings.GetInitialPortfolioValue() * expected
+}
+
+// getControlValue is the buy and hold terminal value given each asset's summed log returns over the path
+func getControlValue(initialPortfolioValue float64, weights, logReturnSums []float64) float64 {
+	value := 0.0
+	for i, w := range weights {
+		value += w * math.Exp(logReturnSums[i])
+	}
+	return initialPortfolioValue * value
+}
+
+// calculateStandardErrors reports the monte carlo error of the mean final value and var95, with antithetic pairs each pair average is one
+// independent observation and the control variate is then a regression on those observations.
+// also returns the estimate of the mean final value, which is adjusted when the control variate is used.
+func calculateStandardErrors(aggregate *SimulationAggregate, statisticalResources *StatisticalResources, settings sm.SimulationRequestSettings) (sm.StandardErrors, float64) {
+	n := float64(aggregate.paths)
+	res := sm.StandardErrors{
+		Antithetic:          settings.Antithetic,
+		ControlVariate:      settings.ControlVariate,
+		MeanFinalValueNaive: math.Sqrt(aggregate.finalValues.Variance() / n),
+		VaR95Naive:          calculateQuantileStandardError(aggregate.risk().totalReturns, 0.05),
+	}
+
+	observations := aggregate.observations
+	m := observations.x.n
+	mean, variance := observations.x.mean, observations.x.Variance()
+	if settings.ControlVariate {
+		// beta = cov(y, c) / var(c), the adjusted mean removes the part of the error the control explains
+		covariance := observations.Covariance()
+		beta := covariance / observations.y.Variance()
+
+		res.ControlVariateBeta = beta
+		mean -= beta * (observations.y.mean - getControlVariateExpectation(statisticalResources, settings))
+		variance -= beta * covariance // variance of the residuals y - beta c
+	}
+
+	res.MeanFinalValue = math.Sqrt(variance / m)
+	if res.MeanFinalValue > 0 {
+		res.MeanFinalValueVarianceRatio = math.Pow(res.MeanFinalValueNaive/res.MeanFinalValue, 2)
+	}
+
+	res.VaR95 = res.VaR95Naive
+	if settings.Antithetic {
+		res.VaR95 = calculateBatchQuantileStandardError(aggregate, 0.05)
+	}
+
+	return res, mean
+}
+
+// calculateQuantileStandardError is the asymptotic standard error of an empirical quantile for independent draws, sqrt(p(1-p)/n) / f(q).
+// the density at the quantile is estimated from the spread of the quantiles either side of it.
+func calculateQuantileStandardError(values *tDigest, p float64) float64 {
+	lower := math.Max(p-quantileDensityWindow, 0)
+	upper := math.Min(p+quantileDensityWindow, 1)
+	spread := values.Quantile(upper) - values.Quantile(lower)
+	return math.Sqrt(p*(1-p)/values.Count()) * spread / (upper - lower)
+}
+
+// calculateBatchQuantileStandardError uses the spread of the batch quantiles, batches hold whole antithetic pairs.
+// this picks up whatever the pairs do to the quantile without needing a formula for it.
+func calculateBatchQuantileStandardError(aggregate *SimulationAggregate, p float64) float64 {
+	batchQuantiles := make([]float64, len(aggregate.batches))
+	for b, batch := range aggregate.batches {
+		if batch.paths < 2 {
+			return calculateQuantileStandardError(aggregate.risk().totalReturns, p)
+		}
+		batchQuantiles[b] = batch.totalReturns.Quantile(p)
+	}
+
+	return stat.StdDev(batchQuantiles, nil) / math.Sqrt(float64(len(batchQuantiles)))
+}
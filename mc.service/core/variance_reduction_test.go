@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	sm "mc.service/models"
+)
+
+// TestStatisticalResourcesWorkerAntitheticPath makes sure the antithetic path replays the same draws with the shocks negated
+func TestStatisticalResourcesWorkerAntitheticPath(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily)
+	settings := sm.SimulationRequestSettings{DistType: sm.StandardNormal, SimulationUnitOfTime: sm.Weekly}
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+
+	worker := NewWorkerResources(sr, 42)
+	nPeriods := 10
+
+	worker.ResetPath(0)
+	original := make([][]float64, nPeriods)
+	for p := range nPeriods {
+		original[p] = worker.GetCorrelatedReturns(sm.Weekly)
+	}
+
+	worker.ResetAntitheticPath()
+	for p := range nPeriods {
+		antithetic := worker.GetCorrelatedReturns(sm.Weekly)
+		for i := range antithetic {
+			// r = drift + shock, so the pair sums to twice the drift
+			drift := (sr.Mu[i] - 0.5*sr.Sigma[i]*sr.Sigma[i]) / sm.Weekly
+			if math.Abs(original[p][i]+antithetic[i]-2*drift) > 1e-12 {
+				t.Fatalf("period %d asset %d: expected antithetic return %.6f, got %.6f", p, i, 2*drift-original[p][i], antithetic[i])
+			}
+		}
+	}
+
+	// the next path starts fresh rather than replaying again
+	worker.ResetPath(1)
+	if next := worker.GetCorrelatedReturns(sm.Weekly); next[0] == original[0][0] {
+		t.Error("Expected a new path to draw new shocks")
+	}
+}
+
+// TestRunMonteCarloSimulation_VarianceReduction compares the standard errors with and without antithetic paths and the control variate
+func TestRunMonteCarloSimulation_VarianceReduction(t *testing.T) {
+	seriesReturns := GenerateMockSeriesReturns(t, sm.Daily*100)
+	settings := sm.SimulationRequestSettings{
+		DistType:             sm.StandardNormal,
+		SimulationUnitOfTime: sm.Monthly,
+		SimulationDuration:   60,
+		Iterations:           20_000,
+		Seed:                 42,
+	}
+
+	sr, err := GetStatisticalResources(seriesReturns, settings)
+	if err != nil {
+		t.Fatalf("GetStatisticalResources: %v", err)
+	}
+	sr.Rebalance = RebalancePolicy{Policy: sm.BuyAndHold} // buy and hold is the control itself, so it should be all but exact
+
+	sc := &ServiceContext{Context: context.Background()}
+	res, err := sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	plain, plainMean := calculateStandardErrors(aggregateResults(res, sr, settings), sr, settings)
+	if plain.MeanFinalValue != plain.MeanFinalValueNaive || plain.VaR95 != plain.VaR95Naive {
+		t.Errorf("Expected no reduction without antithetic paths or a control variate, got %+v", plain)
+	}
+
+	settings.Antithetic = true
+	res, err = sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	antithetic, antitheticMean := calculateStandardErrors(aggregateResults(res, sr, settings), sr, settings)
+	t.Logf("Antithetic - mean se: %.4f (naive %.4f), var95 se: %.5f (naive %.5f)", antithetic.MeanFinalValue, antithetic.MeanFinalValueNaive, antithetic.VaR95, antithetic.VaR95Naive)
+	if antithetic.MeanFinalValueVarianceRatio < 2 {
+		t.Errorf("Expected antithetic pairs to at least halve the variance of the mean, got ratio %.2f", antithetic.MeanFinalValueVarianceRatio)
+	}
+
+	settings.ControlVariate = true
+	res, err = sc.RunMonteCarloSimulation(sr, settings)
+	if err != nil {
+		t.Fatalf("RunMonteCarloSimulation: %v", err)
+	}
+
+	controlled, controlledMean := calculateStandardErrors(aggregateResults(res, sr, settings), sr, settings)
+	expected := getControlVariateExpectation(sr, settings)
+	t.Logf("Control variate - mean: %.4f (expected %.4f, plain %.4f, antithetic %.4f), se: %.6f, beta: %.4f", controlledMean, expected, plainMean, antitheticMean, controlled.MeanFinalValue, controlled.ControlVariateBeta)
+
+	if controlled.MeanFinalValue >= antithetic.MeanFinalValue/10 {
+		t.Errorf("Expected the control variate to cut the standard error by at least 10x for buy and hold, got %.6f vs %.6f", controlled.MeanFinalValue, antithetic.MeanFinalValue)
+	}
+	if math.Abs(controlledMean-expected) > 1e-6*expected {
+		t.Errorf("Expected the adjusted mean to match the buy and hold expectation %.4f, got %.4f", expected, controlledMean)
+	}
+	if math.Abs(plainMean-expected) > 4*plain.MeanFinalValue {
+		t.Errorf("Expected the plain mean %.4f within 4 standard errors of %.4f", plainMean, expected)
+	}
+
+	settings.DistType = sm.StudentT
+	settings.DegreesOfFreedom = 5
+	sr.DistType = sm.StudentT
+	if _, err := sc.RunMonteCarloSimulation(sr, settings); err == nil {
+		t.Error("Expected an error using the control variate with student t")
+	}
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"time"
+
+	dm "mc.data/models"
+)
+
+// CapitalMarketAssumptions are forward looking annualized returns, volatilities and correlations that replace or blend with history.
+// assets the scenario does not hold are ignored, so one set can serve many scenarios.
+type CapitalMarketAssumptions struct {
+	Assets       []AssetAssumption    `json:"assets"`
+	Correlations []AssumedCorrelation `json:"correlations"` // any subset of the pairs, pairs left out keep the historical correlation
+}
+
+type AssetAssumption struct {
+	AssetId        int32    `json:"assetId"`
+	ExpectedReturn *float64 `json:"expectedReturn,omitempty"` // annualized, nil keeps the historical mean
+	Volatility     *float64 `json:"volatility,omitempty"`     // annualized, nil keeps the historical volatility
+}
+
+type AssumedCorrelation struct {
+	AssetIdA    int32   `json:"assetIdA"`
+	AssetIdB    int32   `json:"assetIdB"`
+	Correlation float64 `json:"correlation"`
+}
+
+type AssumptionSetRequest struct {
+	Name        string `json:"name"` // publishing under an existing name adds the next version
+	Description string `json:"description"`
+	CapitalMarketAssumptions
+}
+
+type AssumptionSetResponse struct {
+	Id          int32     `json:"id"`
+	Name        string    `json:"name"`
+	Version     int       `json:"version"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+	CapitalMarketAssumptions
+}
+
+// AssumptionSummary shows what the assumptions did to an asset, the historical estimate next to what the run used
+type AssumptionSummary struct {
+	AssetId         int32   `json:"assetId"`
+	HistoricalMu    float64 `json:"historicalMu"`
+	HistoricalSigma float64 `json:"historicalSigma"`
+	Mu              float64 `json:"mu"`
+	Sigma           float64 `json:"sigma"`
+}
+
+// MapAssumptionSetToResponse maps a stored set, the assets and correlations are left out when only the configuration was loaded
+func MapAssumptionSetToResponse(set *dm.AssumptionSet) AssumptionSetResponse {
+	return AssumptionSetResponse{
+		Id:                       set.Id,
+		Name:                     set.Name,
+		Version:                  set.Version,
+		Description:              set.Description,
+		CreatedAt:                set.CreatedAt,
+		CapitalMarketAssumptions: MapAssumptionSetToCapitalMarketAssumptions(set),
+	}
+}
+
+func MapAssumptionSetToCapitalMarketAssumptions(set *dm.AssumptionSet) CapitalMarketAssumptions {
+	res := CapitalMarketAssumptions{
+		Assets:       make([]AssetAssumption, len(set.Assets)),
+		Correlations: make([]AssumedCorrelation, len(set.Correlations)),
+	}
+
+	for i, a := range set.Assets {
+		res.Assets[i] = AssetAssumption{
+			AssetId:        a.AssetId,
+			ExpectedReturn: a.ExpectedReturn,
+			Volatility:     a.Volatility,
+		}
+	}
+
+	for i, c := range set.Correlations {
+		res.Correlations[i] = AssumedCorrelation{
+			AssetIdA:    c.AssetIdA,
+			AssetIdB:    c.AssetIdB,
+			Correlation: c.Correlation,
+		}
+	}
+
+	return res
+}
+
+// MapAssumptionSetRequestToDataModel stores every pair with the lower asset id first
+func MapAssumptionSetRequestToDataModel(req AssumptionSetRequest) dm.AssumptionSet {
+	assets := make([]dm.AssumptionSetAsset, len(req.Assets))
+	for i, a := range req.Assets {
+		assets[i] = dm.AssumptionSetAsset{
+			AssetId:        a.AssetId,
+			ExpectedReturn: a.ExpectedReturn,
+			Volatility:     a.Volatility,
+		}
+	}
+
+	correlations := make([]dm.AssumptionSetCorrelation, len(req.Correlations))
+	for i, c := range req.Correlations {
+		correlations[i] = dm.AssumptionSetCorrelation{
+			AssetIdA:    min(c.AssetIdA, c.AssetIdB),
+			AssetIdB:    max(c.AssetIdA, c.AssetIdB),
+			Correlation: c.Correlation,
+		}
+	}
+
+	return dm.AssumptionSet{
+		AssumptionSetConfiguration: dm.AssumptionSetConfiguration{
+			Name:        req.Name,
+			Description: req.Description,
+		},
+		Assets:       assets,
+		Correlations: correlations,
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+// BenchmarkSettings run a benchmark next to the portfolio on the same draws, set either the asset or the scenario.
+// the portfolio is estimated and simulated exactly as it is without a benchmark. benchmark assets the scenario does not hold
+// are estimated on their own history (sample mean and volatility, correlations over the dates they share with each asset),
+// use constant volatility and only take the market wide jumps and shocks. they are not supported with regime switching.
+type BenchmarkSettings struct {
+	AssetId    int32 `json:"assetid"`    // the benchmark is all in one asset
+	ScenarioId int32 `json:"scenarioid"` // the benchmark holds another scenario's weights and follows its rebalance policy
+}
+
+// BenchmarkSummary is how the portfolio did against the benchmark, every metric is per path from the time weighted
+// log returns of both, so cash flows move the portfolio value but not its relative performance
+type BenchmarkSummary struct {
+	AssetId                      int32              `json:"assetId,omitempty"`
+	ScenarioId                   int32              `json:"scenarioId,omitempty"`
+	AssetIds                     []int32            `json:"assetIds"` // order of the weights
+	Weights                      []float64          `json:"weights"`
+	ProbabilityOfUnderperforming float64            `json:"probabilityOfUnderperforming"` // share of paths where the benchmark returned more
+	TrackingError                MetricDistribution `json:"trackingError"`                // annualized volatility of the active returns
+	InformationRatio             MetricDistribution `json:"informationRatio"`             // annualized active return over the tracking error
+	ExcessReturn                 MetricDistribution `json:"excessReturn"`                 // annualized, geometric
+	RelativeDrawdown             MetricDistribution `json:"relativeDrawdown"`             // deepest fall of the portfolio relative to the benchmark
+}
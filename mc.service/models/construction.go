@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// weight construction methods
+const (
+	EqualRiskContribution  = "erc" // every asset contributes the same share of the portfolio variance
+	HierarchicalRiskParity = "hrp" // lopez de prado, inverse variance down a tree of correlation clusters
+)
+
+// linkages for the hierarchical risk parity clusters
+const (
+	SingleLinkage   = "single" // default
+	CompleteLinkage = "complete"
+	AverageLinkage  = "average"
+)
+
+type ConstructWeightsRequest struct {
+	AssetIds    []int32          `json:"assetIds"`
+	Method      string           `json:"method"`      // erc or hrp
+	Linkage     string           `json:"linkage"`     // hrp only, defaults to single
+	MaxLookback time.Duration    `json:"maxLookback"` // how far back the returns go, zero for all of them
+	Scenario    *ScenarioRequest `json:"scenario"`    // creates a scenario with the weights when set, its components are the weights
+}
+
+type ConstructWeightsResponse struct {
+	Method            string            `json:"method"`
+	AssetIds          []int32           `json:"assetIds"` // order of the weights and risk contributions
+	Weights           []float64         `json:"weights"`
+	RiskContributions []float64         `json:"riskContributions"`      // share of the portfolio variance from each asset, sums to 1
+	Volatility        float64           `json:"volatility"`             // annualized
+	ClusterOrder      []int32           `json:"clusterOrder,omitempty"` // hrp only, asset ids with the correlated ones next to each other
+	Scenario          *ScenarioResponse `json:"scenario,omitempty"`
+}
+
+// GetLinkage defaults an empty linkage to single, the linkage of the original hierarchical risk parity
+func GetLinkage(linkage string) string {
+	if linkage == "" {
+		return SingleLinkage
+	}
+	return linkage
+}
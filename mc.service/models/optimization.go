@@ -0,0 +1,71 @@
+package models
+
+// points of the frontier that can be saved as the scenario's weights
+const (
+	MaxSharpePortfolio   = "maxSharpe"
+	MinVariancePortfolio = "minVariance"
+)
+
+type OptimizationRequest struct {
+	Settings       SimulationRequestSettings `json:"settings"`       // only the estimation settings are used: lookback, estimators, correlation repair and assumptions
+	Bounds         []WeightBounds            `json:"bounds"`         // assets left out can take any weight from 0 to 1
+	FrontierPoints int                       `json:"frontierPoints"` // defaults to 20
+	RiskFreeRate   float64                   `json:"riskFreeRate"`   // annualized, for the sharpe ratios
+	Save           string                    `json:"save"`           // maxSharpe or minVariance saves those weights as the scenario's, empty saves nothing
+}
+
+// WeightBounds limits the weight of one asset, long only so the min is at least 0
+type WeightBounds struct {
+	AssetId int32   `json:"assetId"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+}
+
+type OptimizationResponse struct {
+	ScenarioId      int32            `json:"scenarioId"`
+	AssetIds        []int32          `json:"assetIds"`        // order of the expected returns, volatilities and every set of weights
+	ExpectedReturns []float64        `json:"expectedReturns"` // annualized, the mu the simulation would use
+	Volatilities    []float64        `json:"volatilities"`    // annualized
+	Frontier        []PortfolioPoint `json:"frontier"`        // lowest to highest expected return
+	MinVariance     PortfolioPoint   `json:"minVariance"`
+	MaxSharpe       PortfolioPoint   `json:"maxSharpe"`
+	Current         PortfolioPoint   `json:"current"` // the scenario's weights before anything was saved
+	Saved           string           `json:"saved,omitempty"`
+}
+
+type PortfolioPoint struct {
+	ExpectedReturn float64   `json:"expectedReturn"`
+	Volatility     float64   `json:"volatility"`
+	SharpeRatio    float64   `json:"sharpeRatio"`
+	Weights        []float64 `json:"weights"`
+}
+
+// CVaROptimizationRequest minimizes the expected shortfall of the simulated paths instead of the variance
+type CVaROptimizationRequest struct {
+	Settings     SimulationRequestSettings `json:"settings"`     // the simulation that generates the scenarios and evaluates the weights, always buy and hold without cash flows
+	Bounds       []WeightBounds            `json:"bounds"`       // assets left out can take any weight from 0 to 1
+	Confidence   float64                   `json:"confidence"`   // defaults to 0.95
+	TargetReturn *float64                  `json:"targetReturn"` // annualized, the simulated mean has to reach it, nil for the lowest cvar at any return
+	Scenarios    int                       `json:"scenarios"`    // simulated paths the linear program is solved over, defaults to 5000
+	Save         bool                      `json:"save"`         // saves the optimal weights as the scenario's
+}
+
+type CVaROptimizationResponse struct {
+	ScenarioId          int32         `json:"scenarioId"`
+	AssetIds            []int32       `json:"assetIds"` // order of every set of weights
+	Confidence          float64       `json:"confidence"`
+	Scenarios           int           `json:"scenarios"`
+	HorizonTargetReturn *float64      `json:"horizonTargetReturn,omitempty"` // the annualized target over the simulation duration
+	Optimal             CVaRPortfolio `json:"optimal"`
+	Current             CVaRPortfolio `json:"current"` // the scenario's weights before anything was saved
+	Saved               bool          `json:"saved"`
+}
+
+// CVaRPortfolio is a set of weights on the scenarios the optimizer saw and on a full simulation it did not see
+type CVaRPortfolio struct {
+	Weights        []float64             `json:"weights"`
+	ExpectedReturn float64               `json:"expectedReturn"` // mean buy and hold return over the duration across the scenarios
+	VaR            float64               `json:"var"`            // as returns like the risk metrics, negative is a loss
+	CVaR           float64               `json:"cvar"`
+	RiskMetrics    SimulationRiskMetrics `json:"riskMetrics"` // buy and hold without cash flows like the scenarios, whatever the scenario's rebalance policy
+}
@@ -6,19 +6,35 @@ import (
 	dm "mc.data/models"
 )
 
+// rebalance policies, stored on the scenario as strings
+const (
+	ContinuousRebalance = "continuous" // back to target weights every period
+	BuyAndHold          = "buyAndHold" // never rebalance, weights drift with returns
+	CalendarRebalance   = "calendar"   // back to target weights every RebalanceFrequency periods
+	ThresholdRebalance  = "threshold"  // back to target weights when any weight drifts more than RebalanceThreshold
+)
+
 type ScenarioRequest struct {
-	Name          string                     `json:"name"`
-	FloatedWeight bool                       `json:"floatedWeight"`
-	Components    []ScenarioComponentPayload `json:"components"`
+	Name               string                     `json:"name"`
+	FloatedWeight      bool                       `json:"floatedWeight"`
+	RebalancePolicy    string                     `json:"rebalancePolicy"`    // defaults to continuous
+	RebalanceFrequency int                        `json:"rebalanceFrequency"` // periods of the simulation unit of time, calendar only
+	RebalanceThreshold float64                    `json:"rebalanceThreshold"` // absolute weight drift, threshold only
+	AssumptionSetId    *int32                     `json:"assumptionSetId"`    // capital market assumptions every run uses unless the run asks for others
+	Components         []ScenarioComponentPayload `json:"components"`
 }
 
 type ScenarioResponse struct {
-	Id            int32                      `json:"id"`
-	Name          string                     `json:"name"`
-	FloatedWeight bool                       `json:"floatedWeight"`
-	CreatedAt     time.Time                  `json:"createdAt"`
-	UpdatedAt     time.Time                  `json:"updatedAt"`
-	Components    []ScenarioComponentPayload `json:"components"`
+	Id                 int32                      `json:"id"`
+	Name               string                     `json:"name"`
+	FloatedWeight      bool                       `json:"floatedWeight"`
+	RebalancePolicy    string                     `json:"rebalancePolicy"`
+	RebalanceFrequency int                        `json:"rebalanceFrequency"`
+	RebalanceThreshold float64                    `json:"rebalanceThreshold"`
+	AssumptionSetId    *int32                     `json:"assumptionSetId"`
+	CreatedAt          time.Time                  `json:"createdAt"`
+	UpdatedAt          time.Time                  `json:"updatedAt"`
+	Components         []ScenarioComponentPayload `json:"components"`
 }
 
 type ScenarioComponentPayload struct {
@@ -28,12 +44,16 @@ type ScenarioComponentPayload struct {
 
 func MapScenarioToResponse(scenario *dm.Scenario) ScenarioResponse {
 	res := ScenarioResponse{
-		Id:            scenario.Id,
-		Name:          scenario.Name,
-		FloatedWeight: scenario.FloatedWeight,
-		CreatedAt:     scenario.CreatedAt,
-		UpdatedAt:     scenario.UpdatedAt,
-		Components:    make([]ScenarioComponentPayload, len(scenario.Components)),
+		Id:                 scenario.Id,
+		Name:               scenario.Name,
+		FloatedWeight:      scenario.FloatedWeight,
+		RebalancePolicy:    GetRebalancePolicy(scenario.RebalancePolicy),
+		RebalanceFrequency: scenario.RebalanceFrequency,
+		RebalanceThreshold: scenario.RebalanceThreshold,
+		AssumptionSetId:    scenario.AssumptionSetId,
+		CreatedAt:          scenario.CreatedAt,
+		UpdatedAt:          scenario.UpdatedAt,
+		Components:         make([]ScenarioComponentPayload, len(scenario.Components)),
 	}
 
 	for idx, component := range scenario.Components {
@@ -57,9 +77,21 @@ func MapScenarioRequestToDataModel(req ScenarioRequest) dm.Scenario {
 
 	return dm.Scenario{
 		ScenarioConfiguration: dm.ScenarioConfiguration{
-			Name:          req.Name,
-			FloatedWeight: req.FloatedWeight,
+			Name:               req.Name,
+			FloatedWeight:      req.FloatedWeight,
+			RebalancePolicy:    GetRebalancePolicy(req.RebalancePolicy),
+			RebalanceFrequency: req.RebalanceFrequency,
+			RebalanceThreshold: req.RebalanceThreshold,
+			AssumptionSetId:    req.AssumptionSetId,
 		},
 		Components: components,
 	}
 }
+
+// GetRebalancePolicy defaults an empty policy to continuous, which is how scenarios behaved before policies existed
+func GetRebalancePolicy(policy string) string {
+	if policy == "" {
+		return ContinuousRebalance
+	}
+	return policy
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	dm "mc.data/models"
@@ -8,17 +9,28 @@ import (
 
 // SimulationSettingsResources will be the resources for the simulation settings, rest will be simple numbers provided by user
 type SimulationSettingsResources struct {
-	DistType             map[string]int `json:"disttype"`             // standar normal, student t
+	DistType             map[string]int `json:"disttype"`             // standar normal, student t, historical bootstrap, multivariate t, t copula
 	SimulationUnitOfTime map[string]int `json:"simulationunitoftime"` // daily, weekly, monthly, quarterly, yearly
 	SimulationDuration   map[string]int `json:"simulationduration"`   // number of units of time to simulate
+	VolatilityModel      map[string]int `json:"volatilitymodel"`      // constant, garch
+	CashFlowType         map[string]int `json:"cashflowtype"`         // fixed, percent of value, inflation indexed
+	GoalType             map[string]int `json:"goaltype"`             // target, floor
+	RandomSource         map[string]int `json:"randomsource"`         // pseudo random, sobol
+	CovarianceEstimator  map[string]int `json:"covarianceestimator"`  // sample, ledoit wolf, constant correlation, ewma
+	MeanEstimator        map[string]int `json:"meanestimator"`        // sample, ewma, james stein
+	CorrelationRepair    map[string]int `json:"correlationrepair"`    // when needed, always, never
+	RepairMethod         map[string]int `json:"repairmethod"`         // higham, eigenvalue clipping
 }
 
 // GetSimulationSettingsResources will return the simulation settings resources.
 // This approach makes sure everything is mapped correctly so uses a shared resource
 func GetSimulationSettingsResources() SimulationSettingsResources {
 	distType := map[string]int{
-		"standardNormal": StandardNormal,
-		"studentT":       StudentT,
+		"standardNormal":       StandardNormal,
+		"studentT":             StudentT,
+		"historicalBootstrap":  HistoricalBootstrap,
+		"multivariateStudentT": MultivariateStudentT,
+		"studentTCopula":       StudentTCopula,
 	}
 
 	// we just have weekly for now, can test and expand later
@@ -34,10 +46,63 @@ func GetSimulationSettingsResources() SimulationSettingsResources {
 		"years":    Yearly,
 	}
 
+	volatilityModel := map[string]int{
+		"constant": ConstantVolatility,
+		"garch":    Garch,
+	}
+
+	cashFlowType := map[string]int{
+		"fixed":            FixedCashFlow,
+		"percentOfValue":   PercentOfValueCashFlow,
+		"inflationIndexed": InflationIndexedCashFlow,
+	}
+
+	goalType := map[string]int{
+		"target": TargetGoal,
+		"floor":  FloorGoal,
+	}
+
+	randomSource := map[string]int{
+		"pseudoRandom": PseudoRandom,
+		"sobol":        Sobol,
+	}
+
+	covarianceEstimator := map[string]int{
+		"sample":              SampleCovariance,
+		"ledoitWolf":          LedoitWolfCovariance,
+		"constantCorrelation": ConstantCorrelationCovariance,
+		"ewma":                EwmaCovariance,
+	}
+
+	meanEstimator := map[string]int{
+		"sample":     SampleMean,
+		"ewma":       EwmaMean,
+		"jamesStein": JamesSteinMean,
+	}
+
+	correlationRepair := map[string]int{
+		"whenNeeded": RepairWhenNeeded,
+		"always":     AlwaysRepair,
+		"never":      NeverRepair,
+	}
+
+	repairMethod := map[string]int{
+		"higham":             HighamRepair,
+		"eigenvalueClipping": EigenvalueClippingRepair,
+	}
+
 	return SimulationSettingsResources{
 		DistType:             distType,
 		SimulationUnitOfTime: simulationUnitOfTime,
 		SimulationDuration:   simulationDuration,
+		VolatilityModel:      volatilityModel,
+		CashFlowType:         cashFlowType,
+		GoalType:             goalType,
+		RandomSource:         randomSource,
+		CovarianceEstimator:  covarianceEstimator,
+		MeanEstimator:        meanEstimator,
+		CorrelationRepair:    correlationRepair,
+		RepairMethod:         repairMethod,
 	}
 }
 
@@ -48,6 +113,78 @@ func DistTypeToString(code int) string {
 		return "standardNormal"
 	case StudentT:
 		return "studentT"
+	case HistoricalBootstrap:
+		return "historicalBootstrap"
+	case MultivariateStudentT:
+		return "multivariateStudentT"
+	case StudentTCopula:
+		return "studentTCopula"
+	default:
+		return ""
+	}
+}
+
+// VolatilityModelToString returns the string name for storage given the volatility model code
+func VolatilityModelToString(code int) string {
+	switch code {
+	case ConstantVolatility:
+		return "constant"
+	case Garch:
+		return "garch"
+	default:
+		return ""
+	}
+}
+
+// RandomSourceToString returns the string name for storage given the random source code
+func RandomSourceToString(code int) string {
+	switch code {
+	case PseudoRandom:
+		return "pseudoRandom"
+	case Sobol:
+		return "sobol"
+	default:
+		return ""
+	}
+}
+
+// CovarianceEstimatorToString returns the string name for storage given the covariance estimator code
+func CovarianceEstimatorToString(code int) string {
+	switch code {
+	case SampleCovariance:
+		return "sample"
+	case LedoitWolfCovariance:
+		return "ledoitWolf"
+	case ConstantCorrelationCovariance:
+		return "constantCorrelation"
+	case EwmaCovariance:
+		return "ewma"
+	default:
+		return ""
+	}
+}
+
+// MeanEstimatorToString returns the string name for storage given the mean estimator code
+func MeanEstimatorToString(code int) string {
+	switch code {
+	case SampleMean:
+		return "sample"
+	case EwmaMean:
+		return "ewma"
+	case JamesSteinMean:
+		return "jamesStein"
+	default:
+		return ""
+	}
+}
+
+// RepairMethodToString returns the string name given the correlation repair method code
+func RepairMethodToString(code int) string {
+	switch code {
+	case HighamRepair:
+		return "higham"
+	case EigenvalueClippingRepair:
+		return "eigenvalueClipping"
 	default:
 		return ""
 	}
@@ -71,24 +208,290 @@ func SimulationUnitOfTimeToString(code int) string {
 	}
 }
 
+const (
+	DefaultInitialPortfolioValue = 100.0 // index level, used when the request does not set starting capital
+	DefaultCurrency              = "USD"
+)
+
 // SimulationRequestSettings will be the request from the front end to the simulation controller
 type SimulationRequestSettings struct {
-	DistType             int `json:"disttype"`             // standar normal, student t
+	DistType             int `json:"disttype"`             // standar normal, student t, historical bootstrap, multivariate t, t copula
 	SimulationUnitOfTime int `json:"simulationunitoftime"` // daily, weekly, monthly, quarterly, yearly
 	SimulationDuration   int `json:"simulationduration"`   // number of units of time to simulate
+	VolatilityModel      int `json:"volatilitymodel"`      // constant, garch
 
 	MaxLookback time.Duration `json:"maxlookback"`
 	Iterations  int           `json:"iterations"`
-	Seed        int64         `json:"seed"`
+	Seed        int64         `json:"seed"` // 0 draws a random seed, the effective seed is returned and stored with the run
+
+	DegreesOfFreedom       int `json:"degreesoffreedom"`       // degrees of freedom for student t distribution (marginals for the t copula)
+	CopulaDegreesOfFreedom int `json:"copuladegreesoffreedom"` // degrees of freedom for the t copula, defaults to degrees of freedom
+	BlockLength            int `json:"blocklength"`            // mean block length (in periods) for historical bootstrap
+
+	CovarianceEstimator int `json:"covarianceestimator"` // sample, ledoit wolf, constant correlation, ewma
+	MeanEstimator       int `json:"meanestimator"`       // sample, ewma, james stein
+	HalfLife            int `json:"halflife"`            // periods of the return history for the ewma estimators, 63 daily returns is about a quarter
+	CorrelationRepair   int `json:"correlationrepair"`   // when needed, always, never
+	RepairMethod        int `json:"repairmethod"`        // higham, eigenvalue clipping
+
+	Regimes *RegimeSettings `json:"regimes"` // optional markov regime switching, nil runs a single regime
+	Jumps   *JumpSettings   `json:"jumps"`   // optional merton jump diffusion, nil runs without jumps
+
+	CashFlows []CashFlow `json:"cashflows"` // optional contributions and withdrawals applied at the end of each period
+	Shocks    []Shock    `json:"shocks"`    // optional deterministic shocks on every path, reported against an unshocked baseline
+	Goals     []Goal     `json:"goals"`     // optional values to reach or floors to stay above, reported as the share of paths that meet them
+
+	InitialPortfolioValue float64 `json:"initialportfoliovalue"` // starting capital, defaults to 100
+	Currency              string  `json:"currency"`              // iso code the money amounts are reported in, defaults to USD
+
+	Antithetic     bool `json:"antithetic"`     // run paths in pairs, the second path of a pair negates the normal shocks of the first
+	ControlVariate bool `json:"controlvariate"` // adjust the mean final value with the buy and hold terminal value, which has a known expectation under gbm
+	RandomSource   int  `json:"randomsource"`   // pseudo random or sobol, sobol only replaces the normal shocks
+
+	Convergence *ConvergenceSettings `json:"convergence"` // optional, keeps adding paths until var95 is precise enough, nil runs exactly Iterations paths
+
+	Assumptions      *CapitalMarketAssumptions `json:"assumptions"`      // optional inline assumptions, take precedence over any stored set
+	AssumptionSetId  int32                     `json:"assumptionsetid"`  // stored set, 0 uses the scenario's set when it has one
+	AssumptionWeight float64                   `json:"assumptionweight"` // weight on the assumptions when blending with history, defaults to 1 (replace)
+
+	BlackLitterman *BlackLittermanSettings `json:"blacklitterman"` // optional, equilibrium returns tilted by views replace the estimated mu
+
+	Benchmark *BenchmarkSettings `json:"benchmark"` // optional, simulated on the same draws and reported as active risk
+
+	RiskFreeRate float64 `json:"riskfreerate"` // annualized, the sharpe and sortino ratios are over it and omega splits gains from losses at it
+}
+
+// GetAssumptionWeight defaults to replacing history with the assumptions
+func (s SimulationRequestSettings) GetAssumptionWeight() float64 {
+	if s.AssumptionWeight == 0 {
+		return 1
+	}
+	return s.AssumptionWeight
+}
+
+// ConvergenceSettings runs Iterations paths first, then more batches until the var95 standard error hits the target or the budget runs out
+type ConvergenceSettings struct {
+	TargetVaR95StandardError float64 `json:"targetvar95standarderror"` // in return units, 0.002 is 0.2%
+	MaxIterations            int     `json:"maxiterations"`            // most paths the run may use
+}
+
+// CashFlow is a periodic contribution (positive amount) or withdrawal (negative amount)
+type CashFlow struct {
+	Type          int     `json:"type"`          // fixed, percent of value, inflation indexed
+	Amount        float64 `json:"amount"`        // money per occurrence, or a fraction of value for percent of value (-0.01 withdraws 1%)
+	Frequency     int     `json:"frequency"`     // periods between cash flows, defaults to every period
+	StartPeriod   int     `json:"startperiod"`   // first period (1 based) the cash flow applies, defaults to the first period
+	EndPeriod     int     `json:"endperiod"`     // last period the cash flow applies, 0 runs to the end of the simulation
+	InflationRate float64 `json:"inflationrate"` // annual, only used for inflation indexed
+}
+
+// Shock is a deterministic return applied to every path on top of its random return for the period,
+// it moves the value only and does not feed the garch variance of the periods after it
+type Shock struct {
+	AssetId int32   `json:"assetid"` // 0 shocks every asset in the scenario
+	Period  int     `json:"period"`  // period (1 based) the shock lands in
+	Return  float64 `json:"return"`  // simple return, -0.3 is a 30% fall
+}
 
-	DegreesOfFreedom int `json:"degreesoffreedom"` // degrees of freedom for student t distribution
+// Goal is a value the portfolio should reach by a period, or a floor it should never fall below up to a period
+type Goal struct {
+	Name   string  `json:"name"`
+	Type   int     `json:"type"`   // target, floor
+	Value  float64 `json:"value"`  // money in the currency of the run, cash flows included
+	Period int     `json:"period"` // period (1 based) the target is due or the floor applies until, 0 is the end of the simulation
+}
+
+// JumpSettings will layer a merton jump diffusion on top of the returns.
+// Asset jumps are either estimated from outliers in the return history or supplied per asset, the market jump is always supplied.
+type JumpSettings struct {
+	Estimate         bool             `json:"estimate"`
+	ThresholdStdDevs float64          `json:"thresholdstddevs"` // returns further than this from the mean are jumps when estimating, defaults to 3
+	Assets           []JumpParameters `json:"assets"`           // user specified jumps, assets not listed do not jump
+	MarketJump       *JumpParameters  `json:"marketjump"`       // optional, when it fires every asset gets the same jump
+}
+
+// JumpParameters describe a compound poisson jump in log returns
+type JumpParameters struct {
+	AssetId    int32   `json:"assetid"`    // not used for the market jump
+	Intensity  float64 `json:"intensity"`  // expected number of jumps per year
+	Mean       float64 `json:"mean"`       // mean log jump size
+	Volatility float64 `json:"volatility"` // std dev of the log jump size
+}
+
+// RegimeSettings will configure a markov regime switching model.
+// If regimes are not supplied, NumberOfRegimes are estimated from the return history.
+type RegimeSettings struct {
+	NumberOfRegimes  int                `json:"numberofregimes"`
+	Regimes          []RegimeParameters `json:"regimes"`          // optional, manually supplied regimes
+	TransitionMatrix [][]float64        `json:"transitionmatrix"` // required with manual regimes, row i is the chance of moving from regime i to each regime per period
+}
+
+// RegimeParameters is a manually supplied regime, assets are in the same order as the scenario's asset ids (ascending)
+type RegimeParameters struct {
+	Label      string      `json:"label"`
+	Mu         []float64   `json:"mu"`         // annualized
+	Covariance [][]float64 `json:"covariance"` // annualized
+}
+
+// GetNumberOfRegimes returns the number of regimes simulated, 1 when regime switching is not used
+func (settings SimulationRequestSettings) GetNumberOfRegimes() int {
+	if settings.Regimes == nil {
+		return 1
+	}
+
+	if len(settings.Regimes.Regimes) > 0 {
+		return len(settings.Regimes.Regimes)
+	}
+
+	return settings.Regimes.NumberOfRegimes
+}
+
+// GetMaxIterations returns the most paths the run may use, Iterations unless it converges adaptively
+func (settings SimulationRequestSettings) GetMaxIterations() int {
+	if settings.Convergence == nil {
+		return settings.Iterations
+	}
+	return settings.Convergence.MaxIterations
+}
+
+// GetInitialPortfolioValue returns the starting capital, the default index level of 100 when not set
+func (settings SimulationRequestSettings) GetInitialPortfolioValue() float64 {
+	if settings.InitialPortfolioValue == 0 {
+		return DefaultInitialPortfolioValue
+	}
+	return settings.InitialPortfolioValue
+}
+
+// GetCurrency returns the reporting currency, USD when not set
+func (settings SimulationRequestSettings) GetCurrency() string {
+	if settings.Currency == "" {
+		return DefaultCurrency
+	}
+	return strings.ToUpper(settings.Currency)
 }
 
 // SimulationResponse will be the response from the simulation controller and what is sent to the front end
 type SimulationResponse struct {
-	RiskMetrics SimulationRiskMetrics `json:"riskMetrics"`
-	SamplePaths []SamplePath          `json:"samplePaths"`
-	Summary     SimulationStats       `json:"simulationStats"`
+	InitialPortfolioValue float64                  `json:"initialPortfolioValue"`
+	Currency              string                   `json:"currency"` // every money amount in the response is in this currency
+	Seed                  int64                    `json:"seed"`     // the seed the run used, drawn at random when the request did not set one
+	RiskMetrics           SimulationRiskMetrics    `json:"riskMetrics"`
+	Goals                 []GoalSummary            `json:"goals,omitempty"` // only populated when the request set goals
+	Performance           []PerformanceSummary     `json:"performance"`     // risk adjusted metrics of every path
+	SamplePaths           []SamplePath             `json:"samplePaths"`
+	Summary               SimulationStats          `json:"simulationStats"`
+	GarchParameters       []GarchParameters        `json:"garchParameters,omitempty"` // only populated for the garch volatility model
+	Regimes               []RegimeSummary          `json:"regimes,omitempty"`         // only populated for regime switching
+	Jumps                 []JumpSummary            `json:"jumps,omitempty"`           // only populated for jumps, includes estimated parameters
+	Rebalancing           RebalanceSummary         `json:"rebalancing"`
+	CorrelationRepair     CorrelationRepairSummary `json:"correlationRepair"`
+	Assumptions           []AssumptionSummary      `json:"assumptions,omitempty"`    // only populated when capital market assumptions were used
+	BlackLitterman        *BlackLittermanSummary   `json:"blackLitterman,omitempty"` // only populated when the returns came from black-litterman
+	StandardErrors        StandardErrors           `json:"standardErrors"`
+	PathsRun              int                      `json:"pathsRun"`              // number of paths behind every estimate, can be more than iterations when converging
+	Convergence           *ConvergenceSummary      `json:"convergence,omitempty"` // only populated when converging adaptively
+	Shock                 *ShockSummary            `json:"shock,omitempty"`       // only populated when shocks were overlaid
+	Benchmark             *BenchmarkSummary        `json:"benchmark,omitempty"`   // only populated when run against a benchmark
+
+	// 95% confidence interval for each risk metric, keyed by the risk metric's json name (survivor values are survivorFinalValue.p50 etc.)
+	ConfidenceIntervals map[string]ConfidenceInterval `json:"confidenceIntervals"`
+}
+
+// GoalSummary is how a goal fared across every path
+type GoalSummary struct {
+	Goal
+	SuccessProbability float64  `json:"successProbability"`          // share of paths that met the goal
+	ExpectedShortfall  float64  `json:"expectedShortfall"`           // mean amount the paths that missed fell short by, the deepest breach for a floor, 0 when none missed
+	MedianFirstPeriod  *float64 `json:"medianFirstPeriod,omitempty"` // median period the target is first reached (the floor first breached) by the goal's period, over the paths where that happens
+}
+
+// PerformanceSummary is the distribution of a risk adjusted metric over the paths it is defined on
+type PerformanceSummary struct {
+	Name  string `json:"name"`
+	Paths int    `json:"paths"` // a ratio is not defined on a path without any risk, those paths are left out
+	MetricDistribution
+}
+
+// ShockSummary is the same paths run without the shocks, both runs draw the same random numbers so the impact is only the shocks
+type ShockSummary struct {
+	Shocks          []Shock               `json:"shocks"`
+	Baseline        SimulationRiskMetrics `json:"baseline"`
+	BaselineSummary SimulationStats       `json:"baselineSummary"`
+	Impact          ShockImpact           `json:"impact"`
+}
+
+// ShockImpact is the shocked run minus the baseline, returns are in return units and values in the currency of the run
+type ShockImpact struct {
+	MeanFinalValue    float64 `json:"meanFinalValue"`
+	MedianFinalValue  float64 `json:"medianFinalValue"`
+	VaR95             float64 `json:"var95"`
+	CVaR95            float64 `json:"cvar95"`
+	ProbabilityOfLoss float64 `json:"probabilityOfLoss"`
+	MaxDrawdownP95    float64 `json:"maxDrawdownP95"`
+	ProbabilityOfRuin float64 `json:"probabilityOfRuin"`
+}
+
+// CorrelationRepairSummary reports how far the correlation matrix was moved to make it positive definite, the eigenvalues are of the correlation matrix
+type CorrelationRepairSummary struct {
+	Repaired            bool    `json:"repaired"`
+	Method              string  `json:"method"` // empty when the matrix was not repaired
+	MinEigenvalueBefore float64 `json:"minEigenvalueBefore"`
+	MinEigenvalueAfter  float64 `json:"minEigenvalueAfter"`
+	FrobeniusDistance   float64 `json:"frobeniusDistance"` // between the correlation matrices before and after
+	Iterations          int     `json:"iterations"`        // alternating projections for higham, 0 otherwise
+}
+
+// ConfidenceInterval is the monte carlo uncertainty of an estimate, not the uncertainty in the inputs
+type ConfidenceInterval struct {
+	StandardError float64 `json:"standardError"`
+	Lower         float64 `json:"lower"`
+	Upper         float64 `json:"upper"`
+}
+
+// ConvergenceSummary describes how an adaptive run stopped
+type ConvergenceSummary struct {
+	TargetVaR95StandardError float64 `json:"targetVaR95StandardError"`
+	VaR95StandardError       float64 `json:"var95StandardError"` // achieved with every path run
+	MaxIterations            int     `json:"maxIterations"`
+	Rounds                   int     `json:"rounds"`    // times the engine dispatched batches
+	Converged                bool    `json:"converged"` // false when the budget ran out first
+}
+
+// StandardErrors are the monte carlo error of the estimates, naive values assume independent paths (what the run would have without variance reduction)
+type StandardErrors struct {
+	Antithetic                  bool    `json:"antithetic"`
+	ControlVariate              bool    `json:"controlVariate"`
+	ControlVariateBeta          float64 `json:"controlVariateBeta"` // 0 without the control variate
+	MeanFinalValue              float64 `json:"meanFinalValue"`     // with the variance reduction applied
+	MeanFinalValueNaive         float64 `json:"meanFinalValueNaive"`
+	VaR95                       float64 `json:"var95"` // with antithetic pairs, the control variate does not apply to quantiles
+	VaR95Naive                  float64 `json:"var95Naive"`
+	MeanFinalValueVarianceRatio float64 `json:"meanFinalValueVarianceRatio"` // naive variance over reduced variance, roughly how many times more paths it would take without reduction
+}
+
+// RebalanceSummary describes how the scenario's rebalance policy played out across paths
+type RebalanceSummary struct {
+	Policy              string    `json:"policy"`
+	AverageRebalances   float64   `json:"averageRebalances"`   // average number of rebalances per path
+	AverageFinalWeights []float64 `json:"averageFinalWeights"` // average weights at the end of the paths, in the scenario's asset order (ascending)
+}
+
+// RegimeSummary describes a regime that was simulated and how much time paths spent in it
+type RegimeSummary struct {
+	Label                   string    `json:"label"`
+	Mu                      []float64 `json:"mu"`                      // annualized
+	Sigma                   []float64 `json:"sigma"`                   // annualized
+	TransitionProbabilities []float64 `json:"transitionProbabilities"` // chance of moving from this regime to each regime per period
+	AverageTimeSpent        float64   `json:"averageTimeSpent"`        // average fraction of periods spent in this regime across paths
+}
+
+// JumpSummary is a jump that was simulated, the market jump has no asset id
+type JumpSummary struct {
+	AssetId    int32   `json:"assetId"`
+	Market     bool    `json:"market"`
+	Intensity  float64 `json:"intensity"`  // expected number of jumps per year
+	Mean       float64 `json:"mean"`       // mean log jump size
+	Volatility float64 `json:"volatility"` // std dev of the log jump size
 }
 
 // ScarioRunRiskMetrics will be numbers on the page when looking at scenario results
@@ -97,10 +500,48 @@ type SimulationRiskMetrics struct {
 	VaR99             float64 `json:"var99"`
 	CVaR95            float64 `json:"cvar95"`
 	CVaR99            float64 `json:"cvar99"`
+	VaR95Amount       float64 `json:"var95Amount"` // var and cvar as money, negative is a loss
+	VaR99Amount       float64 `json:"var99Amount"`
+	CVaR95Amount      float64 `json:"cvar95Amount"`
+	CVaR99Amount      float64 `json:"cvar99Amount"`
 	ProbabilityOfLoss float64 `json:"probabilityOfLoss"`
-	MaxDrawdownP95    float64 `json:"maxDrawdownP95"`
+	MaxDrawdownP95    float64 `json:"maxDrawdownP95"` // time weighted, cash flows are not drawdowns
 	MeanFinalValue    float64 `json:"meanFinalValue"`
 	MedianFinalValue  float64 `json:"medianFinalValue"`
+
+	ProbabilityOfRuin     float64                `json:"probabilityOfRuin"`     // share of paths that ran out of money
+	MedianTimeToDepletion float64                `json:"medianTimeToDepletion"` // in periods, across the paths that ran out, 0 when none did
+	SurvivorFinalValue    FinalValueDistribution `json:"survivorFinalValue"`    // terminal wealth of the paths that did not run out
+}
+
+// FinalValueDistribution summarizes a set of final portfolio values
+type FinalValueDistribution struct {
+	Mean float64 `json:"mean"`
+	P5   float64 `json:"p5"`
+	P25  float64 `json:"p25"`
+	P50  float64 `json:"p50"`
+	P75  float64 `json:"p75"`
+	P95  float64 `json:"p95"`
+}
+
+// MetricDistribution summarizes a per path metric across every path
+type MetricDistribution struct {
+	Mean float64 `json:"mean"`
+	P5   float64 `json:"p5"`
+	P25  float64 `json:"p25"`
+	P50  float64 `json:"p50"`
+	P75  float64 `json:"p75"`
+	P95  float64 `json:"p95"`
+}
+
+// GarchParameters are the fitted garch(1,1) parameters for an asset, omega is in per period variance units
+type GarchParameters struct {
+	AssetId           int32   `json:"assetId"`
+	Omega             float64 `json:"omega"`
+	Alpha             float64 `json:"alpha"`
+	Beta              float64 `json:"beta"`
+	LongRunVolatility float64 `json:"longRunVolatility"` // annualized
+	Fitted            bool    `json:"fitted"`            // false when the fit failed and the asset used constant volatility
 }
 
 // SamplePath will show the user a few of the paths the portfolio took
@@ -122,13 +563,51 @@ type SimulationStats struct {
 }
 
 func MapSimulationRequestSettingsToSimulationRunHistory(settings SimulationRequestSettings, maxLookback time.Time) dm.SimulationRunHistory {
-	return dm.SimulationRunHistory{
-		DistributionType:     DistTypeToString(settings.DistType),
-		SimulationUnitOfTime: SimulationUnitOfTimeToString(settings.SimulationUnitOfTime),
-		SimulationDuration:   settings.SimulationDuration,
-		MaxLookback:          maxLookback,
-		Iterations:           settings.Iterations,
-		Seed:                 settings.Seed,
-		DegreesOfFreedom:     settings.DegreesOfFreedom,
+	res := dm.SimulationRunHistory{
+		DistributionType:       DistTypeToString(settings.DistType),
+		SimulationUnitOfTime:   SimulationUnitOfTimeToString(settings.SimulationUnitOfTime),
+		VolatilityModel:        VolatilityModelToString(settings.VolatilityModel),
+		SimulationDuration:     settings.SimulationDuration,
+		MaxLookback:            maxLookback,
+		Iterations:             settings.Iterations,
+		Seed:                   settings.Seed,
+		DegreesOfFreedom:       settings.DegreesOfFreedom,
+		CopulaDegreesOfFreedom: settings.CopulaDegreesOfFreedom,
+		BlockLength:            settings.BlockLength,
+		NumberOfRegimes:        settings.GetNumberOfRegimes(),
+		Jumps:                  settings.Jumps != nil,
+		Shocks:                 len(settings.Shocks),
+		InitialPortfolioValue:  settings.GetInitialPortfolioValue(),
+		Currency:               settings.GetCurrency(),
+		Antithetic:             settings.Antithetic,
+		ControlVariate:         settings.ControlVariate,
+		RandomSource:           RandomSourceToString(settings.RandomSource),
+		CovarianceEstimator:    CovarianceEstimatorToString(settings.CovarianceEstimator),
+		MeanEstimator:          MeanEstimatorToString(settings.MeanEstimator),
+		HalfLife:               settings.HalfLife,
+		AssumptionSetId:        settings.AssumptionSetId,
+		RiskFreeRate:           settings.RiskFreeRate,
+		MaxIterations:          settings.GetMaxIterations(),
 	}
+
+	if settings.Convergence != nil {
+		res.TargetVaR95StandardError = settings.Convergence.TargetVaR95StandardError
+	}
+
+	if settings.Assumptions != nil {
+		res.AssumptionWeight = settings.GetAssumptionWeight()
+	}
+
+	if settings.Benchmark != nil {
+		res.BenchmarkAssetId = settings.Benchmark.AssetId
+		res.BenchmarkScenarioId = settings.Benchmark.ScenarioId
+	}
+
+	if settings.BlackLitterman != nil {
+		res.ViewSetId = settings.BlackLitterman.ViewSetId
+		res.Views = len(settings.BlackLitterman.Views)
+		res.Tau = settings.BlackLitterman.GetTau()
+	}
+
+	return res
 }
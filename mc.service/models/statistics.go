@@ -3,6 +3,59 @@ package models
 const (
 	StandardNormal = iota
 	StudentT
+	HistoricalBootstrap
+	MultivariateStudentT
+	StudentTCopula
+)
+
+// IsStudentT returns true for every dist type that draws student t shocks
+func IsStudentT(distType int) bool {
+	return distType == StudentT || distType == MultivariateStudentT || distType == StudentTCopula
+}
+
+const (
+	ConstantVolatility = iota
+	Garch
+)
+
+const (
+	PseudoRandom = iota // pcg draws
+	Sobol               // scrambled sobol quasi random points for the normal shocks
+)
+
+const (
+	SampleCovariance              = iota
+	LedoitWolfCovariance          // shrunk toward a scaled identity
+	ConstantCorrelationCovariance // shrunk toward the sample variances with one average correlation
+	EwmaCovariance                // exponentially weighted with a half life, recent returns count more
+)
+
+const (
+	SampleMean     = iota
+	EwmaMean       // exponentially weighted with the same half life as the ewma covariance
+	JamesSteinMean // shrunk toward the average of the asset means
+)
+
+const (
+	RepairWhenNeeded = iota // repair the correlation matrix only when its cholesky fails
+	AlwaysRepair            // always floor the eigenvalues, even when the matrix is already positive definite
+	NeverRepair             // fail the run when the matrix is not positive definite
+)
+
+const (
+	HighamRepair             = iota // nearest correlation matrix by alternating projections
+	EigenvalueClippingRepair        // raise small eigenvalues to a floor and rescale the diagonal back to 1
+)
+
+const (
+	FixedCashFlow            = iota // fixed amount every occurrence
+	PercentOfValueCashFlow          // fraction of the portfolio value at the time of the cash flow
+	InflationIndexedCashFlow        // fixed amount in today's money, grown by the inflation rate
+)
+
+const (
+	TargetGoal = iota // value at or above the target at the goal's period
+	FloorGoal         // value never below the floor up to the goal's period
 )
 
 const (
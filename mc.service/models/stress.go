@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// CrisisWindow is a stretch of history to replay, dates are yyyy-mm-dd
+type CrisisWindow struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+}
+
+// GetCrisisWindows is the library of predefined windows, each runs from the market peak (or the day before the shock) to the trough
+func GetCrisisWindows() []CrisisWindow {
+	return []CrisisWindow{
+		{Name: "dotComBust", Description: "Dot-com bust", Start: "2000-03-24", End: "2002-10-09"},
+		{Name: "september11", Description: "September 11 attacks and market closure", Start: "2001-09-10", End: "2001-09-21"},
+		{Name: "globalFinancialCrisis", Description: "Global financial crisis, peak to trough", Start: "2007-10-09", End: "2009-03-09"},
+		{Name: "lehmanCollapse", Description: "Lehman collapse through the 2009 low", Start: "2008-09-12", End: "2009-03-09"},
+		{Name: "euroDebtCrisis", Description: "European debt crisis and the US downgrade", Start: "2011-07-22", End: "2011-10-03"},
+		{Name: "taperTantrum", Description: "Taper tantrum", Start: "2013-05-22", End: "2013-06-24"},
+		{Name: "chinaDevaluation", Description: "Yuan devaluation and the oil collapse", Start: "2015-08-10", End: "2016-02-11"},
+		{Name: "q4Selloff2018", Description: "Fourth quarter 2018 rate selloff", Start: "2018-09-20", End: "2018-12-24"},
+		{Name: "covidCrash", Description: "COVID-19 crash", Start: "2020-02-19", End: "2020-03-23"},
+		{Name: "inflationBear2022", Description: "2022 inflation bear market, stocks and bonds together", Start: "2022-01-03", End: "2022-10-12"},
+	}
+}
+
+type StressTestRequest struct {
+	Windows               []string       `json:"windows"`               // names from the library, every library window when both lists are empty
+	CustomWindows         []CrisisWindow `json:"customWindows"`         // windows outside the library
+	InitialPortfolioValue float64        `json:"initialPortfolioValue"` // defaults to the index level of 100
+}
+
+// GetInitialPortfolioValue returns the starting capital, the default index level of 100 when not set
+func (req StressTestRequest) GetInitialPortfolioValue() float64 {
+	if req.InitialPortfolioValue == 0 {
+		return DefaultInitialPortfolioValue
+	}
+	return req.InitialPortfolioValue
+}
+
+type StressTestResponse struct {
+	ScenarioId            int32              `json:"scenarioId"`
+	RebalancePolicy       string             `json:"rebalancePolicy"`
+	InitialPortfolioValue float64            `json:"initialPortfolioValue"`
+	Results               []StressTestResult `json:"results"`
+}
+
+// StressTestResult is one window replayed with the scenario's current weights. drawdowns are positive fractions of the peak,
+// periods are periods of the price history (weeks). the replay keeps going after the window ends to find the recovery.
+type StressTestResult struct {
+	Window            CrisisWindow        `json:"window"`
+	Error             string              `json:"error,omitempty"` // why the window could not be replayed, the other windows still run
+	TotalReturn       float64             `json:"totalReturn"`     // start to end of the window
+	MaxDrawdown       float64             `json:"maxDrawdown"`
+	PeakDate          time.Time           `json:"peakDate"`
+	TroughDate        time.Time           `json:"troughDate"`
+	PeriodsToTrough   int                 `json:"periodsToTrough"` // from the peak
+	Recovered         bool                `json:"recovered"`
+	RecoveryDate      *time.Time          `json:"recoveryDate,omitempty"`
+	PeriodsToRecovery int                 `json:"periodsToRecovery"` // from the trough back to the peak, 0 when it has not recovered
+	Dates             []time.Time         `json:"dates"`             // the start of the window then the end of every period in it
+	Values            []float64           `json:"values"`
+	Assets            []AssetStressResult `json:"assets"`
+}
+
+type AssetStressResult struct {
+	AssetId     int32   `json:"assetId"`
+	Weight      float64 `json:"weight"`
+	TotalReturn float64 `json:"totalReturn"`
+	MaxDrawdown float64 `json:"maxDrawdown"`
+}
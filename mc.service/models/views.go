@@ -0,0 +1,133 @@
+package models
+
+import (
+	"time"
+
+	dm "mc.data/models"
+)
+
+const (
+	DefaultTau          = 0.05 // uncertainty of the equilibrium returns relative to the covariance
+	DefaultRiskAversion = 2.5  // market price of variance used to back out the equilibrium returns
+)
+
+// BlackLittermanSettings replace the estimated mu with equilibrium returns implied by the scenario weights, tilted by views.
+// views only move the returns of the assets they touch and the ones correlated with them.
+type BlackLittermanSettings struct {
+	ViewSetId    int32   `json:"viewsetid"`    // stored set of the scenario, ignored when views are given inline
+	Views        []View  `json:"views"`        // optional inline views, no views at all runs on the equilibrium returns
+	Tau          float64 `json:"tau"`          // defaults to 0.05
+	RiskAversion float64 `json:"riskaversion"` // defaults to 2.5
+}
+
+// GetTau defaults to the usual 0.05
+func (s BlackLittermanSettings) GetTau() float64 {
+	if s.Tau == 0 {
+		return DefaultTau
+	}
+	return s.Tau
+}
+
+// GetRiskAversion defaults to 2.5, 0 can not be told apart from unset
+func (s BlackLittermanSettings) GetRiskAversion() float64 {
+	if s.RiskAversion == 0 {
+		return DefaultRiskAversion
+	}
+	return s.RiskAversion
+}
+
+// View is an absolute view on an asset's return, or a relative one that the asset beats the versus asset by the expected return
+type View struct {
+	AssetId        int32   `json:"assetId"`
+	VersusAssetId  *int32  `json:"versusAssetId,omitempty"` // nil for an absolute view
+	ExpectedReturn float64 `json:"expectedReturn"`          // annualized, the outperformance for a relative view
+	Confidence     float64 `json:"confidence"`              // greater than 0 up to 1, 1 makes the posterior match the view
+}
+
+type ViewSetRequest struct {
+	Name        string `json:"name"` // publishing under an existing name for the scenario adds the next version
+	Description string `json:"description"`
+	Views       []View `json:"views"`
+}
+
+type ViewSetResponse struct {
+	Id          int32     `json:"id"`
+	ScenarioId  int32     `json:"scenarioId"`
+	Name        string    `json:"name"`
+	Version     int       `json:"version"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Views       []View    `json:"views"`
+}
+
+// BlackLittermanSummary shows where the returns of a run came from, the equilibrium next to the posterior
+type BlackLittermanSummary struct {
+	ViewSetId    int32                 `json:"viewSetId,omitempty"` // 0 for inline views
+	Tau          float64               `json:"tau"`
+	RiskAversion float64               `json:"riskAversion"`
+	Assets       []BlackLittermanAsset `json:"assets"`
+	Views        []ViewSummary         `json:"views"`
+}
+
+type BlackLittermanAsset struct {
+	AssetId         int32   `json:"assetId"`
+	Weight          float64 `json:"weight"`
+	ImpliedReturn   float64 `json:"impliedReturn"` // equilibrium, annualized
+	PosteriorReturn float64 `json:"posteriorReturn"`
+	PriorSigma      float64 `json:"priorSigma"`
+	PosteriorSigma  float64 `json:"posteriorSigma"`
+}
+
+// ViewSummary is a view next to what the equilibrium and the posterior expect for it
+type ViewSummary struct {
+	View
+	ImpliedReturn   float64 `json:"impliedReturn"`
+	PosteriorReturn float64 `json:"posteriorReturn"`
+}
+
+// MapViewSetToResponse maps a stored set, the views are left out when only the configuration was loaded
+func MapViewSetToResponse(set *dm.ViewSet) ViewSetResponse {
+	return ViewSetResponse{
+		Id:          set.Id,
+		ScenarioId:  set.ScenarioId,
+		Name:        set.Name,
+		Version:     set.Version,
+		Description: set.Description,
+		CreatedAt:   set.CreatedAt,
+		Views:       MapViewSetToViews(set),
+	}
+}
+
+func MapViewSetToViews(set *dm.ViewSet) []View {
+	res := make([]View, len(set.Views))
+	for i, v := range set.Views {
+		res[i] = View{
+			AssetId:        v.AssetId,
+			VersusAssetId:  v.VersusAssetId,
+			ExpectedReturn: v.ExpectedReturn,
+			Confidence:     v.Confidence,
+		}
+	}
+	return res
+}
+
+func MapViewSetRequestToDataModel(scenarioId int32, req ViewSetRequest) dm.ViewSet {
+	views := make([]dm.ViewSetView, len(req.Views))
+	for i, v := range req.Views {
+		views[i] = dm.ViewSetView{
+			AssetId:        v.AssetId,
+			VersusAssetId:  v.VersusAssetId,
+			ExpectedReturn: v.ExpectedReturn,
+			Confidence:     v.Confidence,
+		}
+	}
+
+	return dm.ViewSet{
+		ViewSetConfiguration: dm.ViewSetConfiguration{
+			ScenarioId:  scenarioId,
+			Name:        req.Name,
+			Description: req.Description,
+		},
+		Views: views,
+	}
+}
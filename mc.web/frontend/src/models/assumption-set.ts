@@ -0,0 +1,14 @@
+import { CapitalMarketAssumptions } from "./simulation-request-settings";
+
+export type AssumptionSet = CapitalMarketAssumptions & {
+  id: number;
+  name: string;
+  version: number;
+  description: string;
+  createdAt: string;
+};
+
+export type NewAssumptionSetRequest = CapitalMarketAssumptions & {
+  name: string;
+  description: string;
+};
\ No newline at end of file
@@ -0,0 +1,65 @@
+import { SimulationRequestSettings } from "./simulation-request-settings";
+import { RiskMetrics } from "./simulation-response";
+
+export type PortfolioSave = "maxSharpe" | "minVariance";
+
+export type WeightBounds = {
+  assetId: number;
+  min: number;
+  max: number;
+};
+
+export type OptimizationRequest = {
+  settings: SimulationRequestSettings;
+  bounds?: WeightBounds[];
+  frontierPoints?: number;
+  riskFreeRate?: number;
+  save?: PortfolioSave;
+};
+
+export type PortfolioPoint = {
+  expectedReturn: number;
+  volatility: number;
+  sharpeRatio: number;
+  weights: number[];
+};
+
+export type OptimizationResponse = {
+  scenarioId: number;
+  assetIds: number[];
+  expectedReturns: number[];
+  volatilities: number[];
+  frontier: PortfolioPoint[];
+  minVariance: PortfolioPoint;
+  maxSharpe: PortfolioPoint;
+  current: PortfolioPoint;
+  saved?: PortfolioSave;
+};
+
+export type CVaROptimizationRequest = {
+  settings: SimulationRequestSettings;
+  bounds?: WeightBounds[];
+  confidence?: number;
+  targetReturn?: number;
+  scenarios?: number;
+  save?: boolean;
+};
+
+export type CVaROptimizationResponse = {
+  scenarioId: number;
+  assetIds: number[];
+  confidence: number;
+  scenarios: number;
+  horizonTargetReturn?: number;
+  optimal: CVaRPortfolio;
+  current: CVaRPortfolio;
+  saved: boolean;
+};
+
+export type CVaRPortfolio = {
+  weights: number[];
+  expectedReturn: number;
+  var: number;
+  cvar: number;
+  riskMetrics: RiskMetrics;
+};
\ No newline at end of file
@@ -3,10 +3,16 @@ export type ScenarioComponent = {
   weight: number;
 };
 
+export type RebalancePolicy = "continuous" | "buyAndHold" | "calendar" | "threshold";
+
 export type Scenario = {
   id: number;
   name: string;
   floatedWeight: boolean;
+  rebalancePolicy: RebalancePolicy;
+  rebalanceFrequency: number;
+  rebalanceThreshold: number;
+  assumptionSetId?: number;
   createdAt: string;
   updatedAt: string;
   components: ScenarioComponent[];
@@ -15,5 +21,9 @@ export type Scenario = {
 export type NewScenarioRequest = {
   name: string;
   floatedWeight: boolean;
+  rebalancePolicy?: RebalancePolicy;
+  rebalanceFrequency?: number;
+  rebalanceThreshold?: number;
+  assumptionSetId?: number;
   components: ScenarioComponent[];
 };
\ No newline at end of file
@@ -1,9 +1,111 @@
+import { BlackLittermanSettings } from "./views";
+
 export type SimulationRequestSettings = {
     distType: number;
     simulationUnitOfTime: number;
     simulationDuration: number;
+    volatilityModel: number;
     maxLookback: number;
     iterations: number;
     seed: number;
     degreesOfFreedom: number;
+    copulaDegreesOfFreedom: number;
+    blockLength: number;
+    regimes?: RegimeSettings;
+    jumps?: JumpSettings;
+    cashFlows?: CashFlow[];
+    shocks?: Shock[];
+    goals?: Goal[];
+    initialPortfolioValue?: number;
+    currency?: string;
+    antithetic?: boolean;
+    controlVariate?: boolean;
+    randomSource?: number;
+    covarianceEstimator?: number;
+    meanEstimator?: number;
+    halfLife?: number;
+    correlationRepair?: number;
+    repairMethod?: number;
+    convergence?: ConvergenceSettings;
+    assumptions?: CapitalMarketAssumptions;
+    assumptionsetid?: number;
+    assumptionweight?: number;
+    blacklitterman?: BlackLittermanSettings;
+    benchmark?: BenchmarkSettings;
+    riskfreerate?: number;
+};
+
+export type ConvergenceSettings = {
+    targetVaR95StandardError: number;
+    maxIterations: number;
+};
+
+export type CashFlow = {
+    type: number;
+    amount: number;
+    frequency: number;
+    startPeriod: number;
+    endPeriod: number;
+    inflationRate: number;
+};
+
+export type RegimeSettings = {
+    numberOfRegimes: number;
+    regimes?: RegimeParameters[];
+    transitionMatrix?: number[][];
+};
+
+export type RegimeParameters = {
+    label: string;
+    mu: number[];
+    covariance: number[][];
+};
+
+export type JumpSettings = {
+    estimate: boolean;
+    thresholdStdDevs: number;
+    assets?: JumpParameters[];
+    marketJump?: JumpParameters;
+};
+
+export type JumpParameters = {
+    assetId: number;
+    intensity: number;
+    mean: number;
+    volatility: number;
+};
+
+export type CapitalMarketAssumptions = {
+    assets: AssetAssumption[];
+    correlations: AssumedCorrelation[];
+};
+
+export type AssetAssumption = {
+    assetId: number;
+    expectedReturn?: number;
+    volatility?: number;
+};
+
+export type AssumedCorrelation = {
+    assetIdA: number;
+    assetIdB: number;
+    correlation: number;
+};
+
+export type Shock = {
+    assetid: number;
+    period: number;
+    return: number;
+};
+
+export type Goal = {
+    name: string;
+    type: number;
+    value: number;
+    period: number;
+};
+
+export type BenchmarkSettings = {
+    assetid?: number;
+    scenarioid?: number;
 };
\ No newline at end of file
@@ -2,4 +2,11 @@ export type SimulationResources = {
     distType: Map<string, number>;
     simulationUnitOfTime: Map<string, number>;
     simulationDuration: Map<string, number>;
+    volatilityModel: Map<string, number>;
+    cashFlowType: Map<string, number>;
+    randomSource: Map<string, number>;
+    covarianceEstimator: Map<string, number>;
+    meanEstimator: Map<string, number>;
+    correlationRepair: Map<string, number>;
+    repairMethod: Map<string, number>;
 };
\ No newline at end of file
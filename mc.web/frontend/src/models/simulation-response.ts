@@ -1,7 +1,28 @@
+import { Goal, Shock } from "./simulation-request-settings";
+import { BlackLittermanSummary } from "./views";
+
 export type SimulationResponse = {
+    initialPortfolioValue: number;
+    currency: string;
+    seed: number;
     riskMetrics: RiskMetrics;
+    goals?: GoalSummary[];
+    performance: PerformanceSummary[];
     samplePaths: SamplePath[];
     simulationStats: SimulationStats;
+    garchParameters?: GarchParameters[];
+    regimes?: RegimeSummary[];
+    jumps?: JumpSummary[];
+    rebalancing: RebalanceSummary;
+    correlationRepair: CorrelationRepairSummary;
+    standardErrors: StandardErrors;
+    pathsRun: number;
+    convergence?: ConvergenceSummary;
+    confidenceIntervals?: Record<string, ConfidenceInterval>;
+    assumptions?: AssumptionSummary[];
+    blackLitterman?: BlackLittermanSummary;
+    shock?: ShockSummary;
+    benchmark?: BenchmarkSummary;
 };
 
 export type RiskMetrics = {
@@ -9,10 +30,35 @@ export type RiskMetrics = {
     var99: number;
     cvar95: number;
     cvar99: number;
+    var95Amount: number;
+    var99Amount: number;
+    cvar95Amount: number;
+    cvar99Amount: number;
     probabilityOfLoss: number;
     maxDrawdownP95: number;
     meanFinalValue: number;
     medianFinalValue: number;
+    probabilityOfRuin: number;
+    medianTimeToDepletion: number;
+    survivorFinalValue: FinalValueDistribution;
+};
+
+export type FinalValueDistribution = {
+    mean: number;
+    p5: number;
+    p25: number;
+    p50: number;
+    p75: number;
+    p95: number;
+};
+
+export type MetricDistribution = {
+    mean: number;
+    p5: number;
+    p25: number;
+    p50: number;
+    p75: number;
+    p95: number;
 };
 
 export type SamplePath = {
@@ -29,4 +75,117 @@ export type SimulationStats = {
     p50: number[];
     p75: number[];
     p95: number[];
+};
+
+export type GarchParameters = {
+    assetId: number;
+    omega: number;
+    alpha: number;
+    beta: number;
+    longRunVolatility: number;
+    fitted: boolean;
+};
+
+export type RegimeSummary = {
+    label: string;
+    mu: number[];
+    sigma: number[];
+    transitionProbabilities: number[];
+    averageTimeSpent: number;
+};
+
+export type JumpSummary = {
+    assetId: number;
+    market: boolean;
+    intensity: number;
+    mean: number;
+    volatility: number;
+};
+
+export type RebalanceSummary = {
+    policy: string;
+    averageRebalances: number;
+    averageFinalWeights: number[];
+};
+
+export type StandardErrors = {
+    antithetic: boolean;
+    controlVariate: boolean;
+    controlVariateBeta: number;
+    meanFinalValue: number;
+    meanFinalValueNaive: number;
+    var95: number;
+    var95Naive: number;
+    meanFinalValueVarianceRatio: number;
+};
+
+export type ConfidenceInterval = {
+    standardError: number;
+    lower: number;
+    upper: number;
+};
+
+export type ConvergenceSummary = {
+    targetVaR95StandardError: number;
+    var95StandardError: number;
+    maxIterations: number;
+    rounds: number;
+    converged: boolean;
+};
+
+export type CorrelationRepairSummary = {
+    repaired: boolean;
+    method: string;
+    minEigenvalueBefore: number;
+    minEigenvalueAfter: number;
+    frobeniusDistance: number;
+    iterations: number;
+};
+
+export type GoalSummary = Goal & {
+    successProbability: number;
+    expectedShortfall: number;
+    medianFirstPeriod?: number;
+};
+
+export type PerformanceSummary = MetricDistribution & {
+    name: string;
+    paths: number;
+};
+
+export type AssumptionSummary = {
+    assetId: number;
+    historicalMu: number;
+    historicalSigma: number;
+    mu: number;
+    sigma: number;
+};
+
+export type ShockSummary = {
+    shocks: Shock[];
+    baseline: RiskMetrics;
+    baselineSummary: SimulationStats;
+    impact: ShockImpact;
+};
+
+export type ShockImpact = {
+    meanFinalValue: number;
+    medianFinalValue: number;
+    var95: number;
+    cvar95: number;
+    probabilityOfLoss: number;
+    maxDrawdownP95: number;
+    probabilityOfRuin: number;
+};
+
+export type BenchmarkSummary = {
+    assetId: number;
+    scenarioId: number;
+    assetIds: number[];
+    weights: number[];
+    probabilityOfUnderperforming: number;
+    trackingError: MetricDistribution;
+    informationRatio: MetricDistribution;
+    excessReturn: MetricDistribution;
+    relativeDrawdown: MetricDistribution;
 };
\ No newline at end of file
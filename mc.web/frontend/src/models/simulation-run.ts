@@ -2,13 +2,41 @@ export type SimulationRun = {
     id: number;
     name: string;
     floatedWeight: boolean;
+    rebalancePolicy: string;
+    rebalanceFrequency: number;
+    rebalanceThreshold: number;
     distributionType: string;
     simulationUnitOfTime: string;
     simulationDuration: number;
+    volatilityModel: string;
     maxLookback: number;
     iterations: number;
     seed: number;
     degreesOfFreedom: number;
+    copulaDegreesOfFreedom: number;
+    blockLength: number;
+    numberOfRegimes: number;
+    jumps: boolean;
+    shocks: number;
+    initialPortfolioValue: number;
+    currency: string;
+    antithetic: boolean;
+    controlVariate: boolean;
+    randomSource: string;
+    covarianceEstimator: string;
+    meanEstimator: string;
+    halfLife: number;
+    targetVaR95StandardError: number;
+    maxIterations: number;
+    pathsRun: number;
+    assumptionSetId: number;
+    assumptionWeight: number;
+    viewSetId: number;
+    views: number;
+    tau: number;
+    benchmarkAssetId: number;
+    benchmarkScenarioId: number;
+    riskFreeRate: number;
     errorMessage: string;
     startTimeUtc: Date;
     endTimeUtc: Date;
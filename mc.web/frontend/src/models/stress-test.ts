@@ -0,0 +1,42 @@
+export type CrisisWindow = {
+  name: string;
+  description: string;
+  start: string;
+  end: string;
+};
+
+export type StressTestRequest = {
+  windows?: string[];
+  customWindows?: CrisisWindow[];
+  initialPortfolioValue?: number;
+};
+
+export type StressTestResponse = {
+  scenarioId: number;
+  rebalancePolicy: string;
+  initialPortfolioValue: number;
+  results: StressTestResult[];
+};
+
+export type StressTestResult = {
+  window: CrisisWindow;
+  error?: string;
+  totalReturn: number;
+  maxDrawdown: number;
+  peakDate: string;
+  troughDate: string;
+  periodsToTrough: number;
+  recovered: boolean;
+  recoveryDate?: string;
+  periodsToRecovery: number;
+  dates: string[];
+  values: number[];
+  assets: AssetStressResult[];
+};
+
+export type AssetStressResult = {
+  assetId: number;
+  weight: number;
+  totalReturn: number;
+  maxDrawdown: number;
+};
\ No newline at end of file
@@ -0,0 +1,51 @@
+export type View = {
+  assetId: number;
+  versusAssetId?: number;
+  expectedReturn: number;
+  confidence: number;
+};
+
+export type BlackLittermanSettings = {
+  viewsetid?: number;
+  views?: View[];
+  tau?: number;
+  riskaversion?: number;
+};
+
+export type ViewSet = {
+  id: number;
+  scenarioId: number;
+  name: string;
+  version: number;
+  description: string;
+  createdAt: string;
+  views: View[];
+};
+
+export type NewViewSetRequest = {
+  name: string;
+  description: string;
+  views: View[];
+};
+
+export type BlackLittermanSummary = {
+  viewSetId?: number;
+  tau: number;
+  riskAversion: number;
+  assets: BlackLittermanAsset[];
+  views: ViewSummary[];
+};
+
+export type BlackLittermanAsset = {
+  assetId: number;
+  weight: number;
+  impliedReturn: number;
+  posteriorReturn: number;
+  priorSigma: number;
+  posteriorSigma: number;
+};
+
+export type ViewSummary = View & {
+  impliedReturn: number;
+  posteriorReturn: number;
+};
\ No newline at end of file
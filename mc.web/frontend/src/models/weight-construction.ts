@@ -0,0 +1,23 @@
+import { NewScenarioRequest, Scenario } from "./scenario";
+
+export type WeightConstructionMethod = "erc" | "hrp";
+
+export type ClusterLinkage = "single" | "complete" | "average";
+
+export type ConstructWeightsRequest = {
+  assetIds: number[];
+  method: WeightConstructionMethod;
+  linkage?: ClusterLinkage;
+  maxLookback?: number;
+  scenario?: Omit<NewScenarioRequest, "components">;
+};
+
+export type ConstructWeightsResponse = {
+  method: WeightConstructionMethod;
+  assetIds: number[];
+  weights: number[];
+  riskContributions: number[];
+  volatility: number;
+  clusterOrder?: number[];
+  scenario?: Scenario;
+};
\ No newline at end of file